@@ -8,18 +8,105 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/user"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/slurm"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/jm"
 )
 
+func durationToStr(d time.Duration) string {
+	if d == slurm.InfiniteTime {
+		return "UNLIMITED"
+	}
+	if d == 0 {
+		return "NONE"
+	}
+	return d.String()
+}
+
+func countToStr(n int) string {
+	if n == -1 {
+		return "UNLIMITED"
+	}
+	return strconv.Itoa(n)
+}
+
+// statusDetails returns the details of a job status that are known, e.g., why the job is queued and when it is
+// expected to start
+func statusDetails(s *jm.JobStatus) string {
+	var details []string
+	if s.Reason != "" {
+		details = append(details, "reason: "+s.Reason)
+	}
+	if !s.EstimatedStart.IsZero() {
+		details = append(details, "estimated start: "+s.EstimatedStart.Format(time.RFC1123))
+	}
+	if s.Priority > 0 {
+		details = append(details, "priority: "+strconv.Itoa(s.Priority))
+	}
+	if s.QueuePosition > 0 {
+		details = append(details, "queue position: "+strconv.Itoa(s.QueuePosition))
+	}
+	if len(details) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(details, ", ") + ")"
+}
+
+func displayPartitions(jobmgr *jm.JM) error {
+	info, err := jobmgr.ClusterInfo()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PARTITION\tSTATE\tNODES\tIDLE NODES\tCPUS\tIDLE CPUS\tMAX NODES\tDEFAULT TIME\tMAX TIME")
+	for _, p := range info.Partitions {
+		name := p.Name
+		if p.Default {
+			name += "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%s\t%s\t%s\n", name, p.State, p.TotalNodes, p.Availability.IdleNodes,
+			p.TotalCPUs, p.Availability.IdleCPUs, countToStr(p.MaxNodes), durationToStr(p.DefaultTime), durationToStr(p.MaxTime))
+	}
+	return w.Flush()
+}
+
+// tailJob displays the output of a job as it runs, until it completes
+func tailJob(jobmgr *jm.JM, jobIDStr string) error {
+	jobID, err := strconv.Atoi(jobIDStr)
+	if err != nil {
+		return fmt.Errorf("invalid job ID: %s", jobIDStr)
+	}
+	output, err := jobmgr.Tail(jobID)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		io.Copy(os.Stderr, output.Stderr)
+	}()
+	_, err = io.Copy(os.Stdout, output.Stdout)
+	wg.Wait()
+	return err
+}
+
 func main() {
 	statusFlag := flag.String("job-status", "", "Display the status of various jobs; comma-separated list of job IDs")
 	runningJobsFlag := flag.String("running-jobs", "", "Display how many jobs are already running on the target (e.g., a Slurm partition)")
+	partitionsFlag := flag.Bool("partitions", false, "Display the partitions of the job manager, their limits and current availability")
 	help := flag.Bool("h", false, "Help message")
 
 	flag.Parse()
@@ -29,10 +116,23 @@ func main() {
 		fmt.Printf("%s is a command line tool to query any supported job manager", cmdName)
 		fmt.Println("\nUsage:")
 		flag.PrintDefaults()
+		fmt.Printf("  tail <job ID>\n\tDisplay the output of a job as it runs\n")
 		os.Exit(0)
 	}
 
 	jobmgr := jm.Detect()
+	if flag.Arg(0) == "tail" {
+		if flag.NArg() != 2 {
+			fmt.Printf("ERROR: usage: %s tail <job ID>\n", cmdName)
+			os.Exit(1)
+		}
+		err := tailJob(&jobmgr, flag.Arg(1))
+		if err != nil {
+			fmt.Printf("ERROR: unable to follow the output of job %s: %s\n", flag.Arg(1), err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 	if *statusFlag != "" {
 		jobIDsStr := strings.Split(*statusFlag, ",")
 		if len(jobIDsStr) == 0 {
@@ -55,7 +155,13 @@ func main() {
 			os.Exit(1)
 		}
 		for idx := range jobIDs {
-			fmt.Printf("%d: %s\n", jobIDs[idx], statuses[idx].Str)
+			if statuses[idx].Code == jm.JOB_STATUS_QUEUED {
+				err = jobmgr.PendingDetails(jobIDs[idx], &statuses[idx])
+				if err != nil {
+					fmt.Printf("WARNING: unable to get the details of pending job %d: %s\n", jobIDs[idx], err)
+				}
+			}
+			fmt.Printf("%d: %s%s\n", jobIDs[idx], statuses[idx].Str, statusDetails(&statuses[idx]))
 		}
 	}
 
@@ -72,4 +178,12 @@ func main() {
 		}
 		fmt.Printf("Number of running jobs: %d\n", num)
 	}
+
+	if *partitionsFlag {
+		err := displayPartitions(&jobmgr)
+		if err != nil {
+			fmt.Printf("ERROR: unable to retrieve the partitions: %s\n", err)
+			os.Exit(1)
+		}
+	}
 }
@@ -6,16 +6,49 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/app"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/implem"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/mpi"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/spack"
 )
 
+func scan(roots string, opts *mpi.ScanOptions) {
+	for _, root := range strings.Split(roots, ",") {
+		if root != "" {
+			opts.Roots = append(opts.Roots, root)
+		}
+	}
+	opts.UsePath = true
+	inventory, err := mpi.Scan(opts)
+	if err != nil {
+		fmt.Printf("unable to scan %s: %s\n", roots, err)
+		os.Exit(1)
+	}
+	if inventory == nil {
+		inventory = []implem.Info{}
+	}
+	output, err := json.MarshalIndent(inventory, "", "  ")
+	if err != nil {
+		fmt.Printf("unable to generate the inventory: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(output))
+}
+
 func main() {
-	dirFlag := flag.String("dir", "", "Path to the install directory where the MPI is installed")
+	dirFlag := flag.String("dir", "", "Path to the install directory where the MPI is installed (by default, look for a MPI provided by the environment)")
+	scanFlag := flag.String("scan", "", "Comma-separated list of directories to scan for MPI installations, in addition to PATH; the inventory is displayed in JSON")
+	timeoutFlag := flag.Duration("timeout", mpi.DefaultProbeTimeout, "Maximum time to detect the MPI implementation of a directory when scanning")
+	jobsFlag := flag.Int("j", 0, "Number of directories to probe concurrently when scanning (by default, the number of CPUs)")
+	spackFlag := flag.String("spack", "", "Spack spec of the MPI implementation to inspect (e.g., openmpi@4.1.5 %gcc)")
+	appFlag := flag.String("app", "", "Path to an application binary to check against the detected MPI ABI")
 	help := flag.Bool("h", false, "Help message")
 
 	flag.Parse()
@@ -28,10 +61,77 @@ func main() {
 		os.Exit(0)
 	}
 
-	i, err := mpi.DetectFromDir(*dirFlag)
-	if err != nil {
-		fmt.Printf("unable to detect the MPI implementation installed in %s: %s\n", *dirFlag, err)
-		os.Exit(1)
+	if *scanFlag != "" {
+		scan(*scanFlag, &mpi.ScanOptions{Concurrency: *jobsFlag, Timeout: *timeoutFlag})
+		return
 	}
+
+	var i implem.Info
+	var err error
+	switch {
+	case *spackFlag != "":
+		var s *spack.Spack
+		var spackMPI *implem.Info
+		s, err = spack.Detect()
+		if err == nil {
+			spackMPI, err = s.MPI(*spackFlag)
+		}
+		if err != nil {
+			fmt.Printf("unable to find the MPI implementation matching %s: %s\n", *spackFlag, err)
+			os.Exit(1)
+		}
+		i = *spackMPI
+	case *dirFlag == "":
+		// Without a directory, we look for a MPI provided by the environment (e.g., a Cray MPICH module)
+		err = i.Load(nil)
+		if err == nil && i.ID == "" {
+			err = fmt.Errorf("no MPI found")
+		}
+		if err != nil {
+			fmt.Printf("unable to detect the MPI implementation from the environment: %s\n", err)
+			os.Exit(1)
+		}
+	default:
+		i, err = mpi.DetectFromDir(*dirFlag)
+		if err != nil {
+			fmt.Printf("unable to detect the MPI implementation installed in %s: %s\n", *dirFlag, err)
+			os.Exit(1)
+		}
+	}
+	mpi.Inspect(&i)
 	fmt.Printf("Detected MPI: %s\nVersion: %s\n", i.ID, i.Version)
+	if i.LibDir != "" {
+		fmt.Printf("Library directory: %s\n", i.LibDir)
+	}
+	if i.Launcher != "" {
+		fmt.Printf("Launcher: %s\n", i.Launcher)
+	}
+	if i.Build != nil {
+		if i.Build.Compiler != "" {
+			fmt.Printf("Compiler: %s\n", i.Build.Compiler)
+		}
+		if i.Build.Device != "" {
+			fmt.Printf("Device: %s\n", i.Build.Device)
+		}
+		if features := i.Build.Features(); len(features) > 0 {
+			fmt.Printf("Features: %s\n", strings.Join(features, " "))
+		}
+	}
+	if len(i.ConfigureOptions) > 0 {
+		fmt.Printf("Configure options: %s\n", strings.Join(i.ConfigureOptions, " "))
+	}
+	if len(i.NetworkLibs) > 0 {
+		fmt.Printf("Network libraries: %s\n", strings.Join(i.NetworkLibs, " "))
+	}
+	if *appFlag != "" {
+		r, err := mpi.CheckABI(&app.Info{BinPath: *appFlag}, &i)
+		if err != nil {
+			fmt.Printf("unable to check the MPI ABI of %s: %s\n", *appFlag, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Application MPI library: %s\nABI: %s\n", r.App.Soname, r)
+		if r.Compatibility == mpi.ABIIncompatible {
+			os.Exit(2)
+		}
+	}
 }
@@ -0,0 +1,60 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/mpi"
+)
+
+func main() {
+	dirFlag := flag.String("dir", "", "Path to the install directory of the MPI implementation")
+	generateFlag := flag.Bool("generate", false, "Generate the manifest of the MPI installation")
+	verifyFlag := flag.Bool("verify", false, "Verify the MPI installation against its manifest")
+	help := flag.Bool("h", false, "Help message")
+
+	flag.Parse()
+
+	cmdName := filepath.Base(os.Args[0])
+	if *help || *dirFlag == "" || *generateFlag == *verifyFlag {
+		fmt.Printf("%s is a command line tool to generate and verify the manifest of a MPI installation\n", cmdName)
+		fmt.Println("\nUsage:")
+		flag.PrintDefaults()
+		if *help {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
+
+	if *generateFlag {
+		i, err := mpi.DetectFromDir(*dirFlag)
+		if err != nil {
+			fmt.Printf("unable to detect the MPI implementation installed in %s: %s\n", *dirFlag, err)
+			os.Exit(1)
+		}
+		m, err := mpi.GenerateManifest(&i)
+		if err != nil {
+			fmt.Printf("unable to generate the manifest of %s: %s\n", *dirFlag, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Manifest of %s %s generated with %d files\n", m.ID, m.Version, len(m.Files))
+		return
+	}
+
+	r, err := mpi.VerifyManifest(*dirFlag)
+	if err != nil {
+		fmt.Printf("unable to verify %s: %s\n", *dirFlag, err)
+		os.Exit(1)
+	}
+	fmt.Println(r)
+	if !r.OK() {
+		os.Exit(2)
+	}
+}
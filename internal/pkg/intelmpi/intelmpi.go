@@ -0,0 +1,157 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package intelmpi
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/network"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/sys"
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+const (
+	// ID is the internal ID for Intel MPI
+	ID = "intelmpi"
+
+	// VersionPrefix is the prefix of the version in the output of 'mpirun --version' and impi_info
+	VersionPrefix = "Intel(R) MPI Library"
+
+	// DefaultFabrics is the default value of I_MPI_FABRICS
+	DefaultFabrics = "shm:ofi"
+
+	// DefaultPin is the default value of I_MPI_PIN
+	DefaultPin = "1"
+)
+
+// hasGenv checks whether an environment variable is already set with -genv in a list of arguments
+func hasGenv(args []string, name string) bool {
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == "-genv" && args[i+1] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// GetExtraMpirunArgs returns the set of arguments required for the mpirun command for the target platform.
+// Variables the user already set with -genv are not overwritten.
+func GetExtraMpirunArgs(sys *sys.Config, netCfg *network.Config, extraArgs []string) []string {
+	userArgs := extraArgs
+	setGenv := func(name string, value string) {
+		if !hasGenv(userArgs, name) {
+			extraArgs = append(extraArgs, "-genv", name, value)
+		}
+	}
+
+	setGenv("I_MPI_FABRICS", DefaultFabrics)
+	setGenv("I_MPI_PIN", DefaultPin)
+	if netCfg == nil {
+		return extraArgs
+	}
+	switch netCfg.Transport {
+	case network.TCPTransport:
+		setGenv("I_MPI_OFI_PROVIDER", "tcp")
+		if netCfg.Device != "" {
+			setGenv("FI_TCP_IFACE", netCfg.Device)
+		}
+	case network.OFITransport:
+		if netCfg.Provider != "" {
+			setGenv("I_MPI_OFI_PROVIDER", netCfg.Provider)
+		}
+	case network.UCXTransport:
+		// Intel MPI relies on UCX through the mlx provider
+		setGenv("I_MPI_OFI_PROVIDER", "mlx")
+		fallthrough
+	default:
+		if netCfg.Device != "" {
+			setGenv("UCX_NET_DEVICES", netCfg.Device)
+		}
+	}
+	return extraArgs
+}
+
+// parseIntelMPIVersionOutput parses the output of 'mpirun --version' or impi_info, for instance
+// "Intel(R) MPI Library for Linux* OS, Version 2021.9 Build 20230307 (id: d82b3071db)" gives 2021.9 and
+// "Intel(R) MPI Library for Linux* OS, Version 2019 Update 12 Build 20210429 (id: 1b5e3d5e6)" gives 2019.12
+func parseIntelMPIVersionOutput(output string) (string, error) {
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, VersionPrefix) {
+			continue
+		}
+		idx := strings.Index(line, "Version ")
+		if idx == -1 {
+			return "", fmt.Errorf("invalid format: %s", line)
+		}
+		tokens := strings.Fields(line[idx+len("Version "):])
+		if len(tokens) == 0 {
+			return "", fmt.Errorf("invalid format: %s", line)
+		}
+		version := strings.TrimSuffix(tokens[0], ",")
+		if len(tokens) > 2 && tokens[1] == "Update" {
+			version += "." + tokens[2]
+		}
+		return version, nil
+	}
+	return "", fmt.Errorf("not an Intel MPI output")
+}
+
+func runVersionCmd(dir string, bin string, args []string, env []string) (string, error) {
+	var versionCmd advexec.Advcmd
+	versionCmd.BinPath = bin
+	versionCmd.CmdArgs = args
+	versionCmd.ExecDir = filepath.Join(dir, "bin")
+	versionCmd.Env = env
+	if env == nil {
+		newLDPath := filepath.Join(dir, "lib") + ":$LD_LIBRARY_PATH"
+		newPath := filepath.Join(dir, "bin") + ":$PATH"
+		versionCmd.Env = append(versionCmd.Env, "LD_LIBRARY_PATH="+newLDPath)
+		versionCmd.Env = append(versionCmd.Env, "PATH="+newPath)
+		versionCmd.Env = append(versionCmd.Env, "I_MPI_ROOT="+dir)
+	}
+	res := versionCmd.Run()
+	if res.Err != nil {
+		return "", fmt.Errorf("unable to execute %s: %w", bin, res.Err)
+	}
+	return res.Stdout + res.Stderr, nil
+}
+
+// DetectFromDir tries to figure out which version of Intel MPI is installed in a given directory
+func DetectFromDir(dir string, env []string) (string, string, error) {
+	mpirunBin := filepath.Join(dir, "bin", "mpirun")
+	impiInfoBin := filepath.Join(dir, "bin", "impi_info")
+	if !util.FileExists(impiInfoBin) {
+		return "", "", fmt.Errorf("%s does not exist, not an Intel MPI implementation", impiInfoBin)
+	}
+
+	var errs []string
+	if util.FileExists(mpirunBin) {
+		output, err := runVersionCmd(dir, mpirunBin, []string{"--version"}, env)
+		if err == nil {
+			var version string
+			version, err = parseIntelMPIVersionOutput(output)
+			if err == nil {
+				return ID, version, nil
+			}
+		}
+		errs = append(errs, err.Error())
+	}
+
+	output, err := runVersionCmd(dir, impiInfoBin, []string{"-v"}, env)
+	if err != nil {
+		errs = append(errs, err.Error())
+		return "", "", fmt.Errorf("unable to get the version of Intel MPI: %s", strings.Join(errs, "; "))
+	}
+	version, err := parseIntelMPIVersionOutput(output)
+	if err != nil {
+		return "", "", fmt.Errorf("parseIntelMPIVersionOutput() failed - %w", err)
+	}
+
+	return ID, version, nil
+}
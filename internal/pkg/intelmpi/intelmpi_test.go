@@ -0,0 +1,79 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package intelmpi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/network"
+)
+
+func TestParseIntelMPIVersionOutput(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		expectedOutput string
+	}{
+		{
+			name:           "2021.9",
+			input:          "Intel(R) MPI Library for Linux* OS, Version 2021.9 Build 20230307 (id: d82b3071db)\nCopyright 2003-2023, Intel Corporation.\n",
+			expectedOutput: "2021.9",
+		},
+		{
+			name:           "2021.10",
+			input:          "Intel(R) MPI Library for Linux* OS, Version 2021.10 Build 20230619 (id: c2e19c2f3e)\nCopyright 2003-2023, Intel Corporation.\n",
+			expectedOutput: "2021.10",
+		},
+		{
+			name:           "2019 Update 12",
+			input:          "Intel(R) MPI Library for Linux* OS, Version 2019 Update 12 Build 20210429 (id: 1b5e3d5e6)\nCopyright 2003-2021, Intel Corporation.\n",
+			expectedOutput: "2019.12",
+		},
+		{
+			name:           "2018 Update 5",
+			input:          "Intel(R) MPI Library for Linux* OS, Version 2018 Update 5 Build 20190404 (id: 18839)\nCopyright 2003-2019 Intel Corporation.\n",
+			expectedOutput: "2018.5",
+		},
+	}
+
+	for _, tt := range tests {
+		version, err := parseIntelMPIVersionOutput(tt.input)
+		if err != nil {
+			t.Fatalf("%s: parseIntelMPIVersionOutput() failed: %s", tt.name, err)
+		}
+		if version != tt.expectedOutput {
+			t.Fatalf("%s: parseIntelMPIVersionOutput() returned %s instead of %s", tt.name, version, tt.expectedOutput)
+		}
+	}
+
+	_, err := parseIntelMPIVersionOutput("HYDRA build details:\n    Version:                                 3.4.2\n")
+	if err == nil {
+		t.Fatalf("parseIntelMPIVersionOutput() succeeded with MPICH output")
+	}
+}
+
+func TestGetExtraMpirunArgs(t *testing.T) {
+	netCfg := network.Config{Device: "mlx5_0:1"}
+	args := GetExtraMpirunArgs(nil, &netCfg, nil)
+	expected := "-genv I_MPI_FABRICS shm:ofi -genv I_MPI_PIN 1 -genv UCX_NET_DEVICES mlx5_0:1"
+	if strings.Join(args, " ") != expected {
+		t.Fatalf("GetExtraMpirunArgs() returned %s instead of %s", strings.Join(args, " "), expected)
+	}
+
+	args = GetExtraMpirunArgs(nil, nil, []string{"-genv", "I_MPI_FABRICS", "shm"})
+	expected = "-genv I_MPI_FABRICS shm -genv I_MPI_PIN 1"
+	if strings.Join(args, " ") != expected {
+		t.Fatalf("GetExtraMpirunArgs() returned %s instead of %s", strings.Join(args, " "), expected)
+	}
+
+	netCfg = network.Config{Transport: network.OFITransport, Provider: "psm3"}
+	args = GetExtraMpirunArgs(nil, &netCfg, []string{"-genv", "I_MPI_PIN", "0"})
+	expected = "-genv I_MPI_PIN 0 -genv I_MPI_FABRICS shm:ofi -genv I_MPI_OFI_PROVIDER psm3"
+	if strings.Join(args, " ") != expected {
+		t.Fatalf("GetExtraMpirunArgs() returned %s instead of %s", strings.Join(args, " "), expected)
+	}
+}
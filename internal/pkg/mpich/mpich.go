@@ -8,9 +8,15 @@ package mpich
 import (
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/network"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/buildinfo"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/placement"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/rankoutput"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/sys"
 	"github.com/BTMichalowicz/go_util/pkg/util"
 )
 
@@ -25,12 +31,120 @@ const (
 	ID = "mpich"
 )
 
-// GetExtraMpirunArgs returns the extra mpirun arguments required by MPICH for a specific configuration
-func GetExtraMpirunArgs() []string {
-	var extraArgs []string
+// GetExtraMpirunArgs returns the extra mpirun arguments required by MPICH for a specific configuration. Since MPICH
+// may be built with either UCX or libfabric, TCP is requested from both.
+func GetExtraMpirunArgs(sys *sys.Config, netCfg *network.Config, extraArgs []string) []string {
+	if netCfg == nil {
+		return extraArgs
+	}
+
+	switch netCfg.Transport {
+	case network.TCPTransport:
+		if netCfg.Device != "" {
+			extraArgs = append(extraArgs, "-iface", netCfg.Device)
+			extraArgs = append(extraArgs, "-genv", "UCX_NET_DEVICES", netCfg.Device)
+		}
+		extraArgs = append(extraArgs, "-genv", "UCX_TLS", "tcp,self,sm")
+		extraArgs = append(extraArgs, "-genv", "FI_PROVIDER", "tcp")
+	case network.OFITransport:
+		if netCfg.Provider != "" {
+			extraArgs = append(extraArgs, "-genv", "FI_PROVIDER", netCfg.Provider)
+		}
+	default:
+		if netCfg.Device != "" {
+			extraArgs = append(extraArgs, "-genv", "UCX_NET_DEVICES", netCfg.Device)
+		}
+		if netCfg.Provider != "" {
+			extraArgs = append(extraArgs, "-genv", "FI_PROVIDER", netCfg.Provider)
+		}
+	}
 	return extraArgs
 }
 
+// GetBindingArgs returns the Hydra arguments to bind and map processes, e.g., "core" and "socket"
+func GetBindingArgs(bindTo string, mapBy string) []string {
+	var args []string
+	if bindTo != "" {
+		args = append(args, "-bind-to", bindTo)
+	}
+	if mapBy != "" {
+		args = append(args, "-map-by", mapBy)
+	}
+	return args
+}
+
+// GetPlacementArgs returns the Hydra arguments to map and bind processes according to a placement spec
+func GetPlacementArgs(s *placement.Spec) ([]string, error) {
+	if s.IsEmpty() {
+		return nil, nil
+	}
+	if s.RanksPerSocket > 0 {
+		return nil, fmt.Errorf("Hydra cannot start a given number of ranks per socket")
+	}
+
+	var args []string
+	if s.RanksPerNode > 0 {
+		args = append(args, "-ppn", strconv.Itoa(s.RanksPerNode))
+	}
+	bindTo := string(s.BindTo)
+	if s.ThreadsPerRank > 1 {
+		if bindTo == "" {
+			bindTo = string(placement.Core)
+		}
+		// Each rank is bound to as many cores (or hardware threads) as it has threads
+		if s.BindTo == "" || s.BindTo == placement.Core || s.BindTo == placement.HWThread {
+			bindTo += ":" + strconv.Itoa(s.ThreadsPerRank)
+		}
+	}
+	if bindTo != "" {
+		args = append(args, "-bind-to", bindTo)
+	}
+	// Hydra does not prevent oversubscription, nothing to do for s.Oversubscribe
+	return args, nil
+}
+
+// GetHostfileArgs returns the Hydra arguments to use a hostfile
+func GetHostfileArgs(hostfile string) []string {
+	if hostfile == "" {
+		return nil
+	}
+	return []string{"-f", hostfile}
+}
+
+// GetRankOutputArgs returns the Hydra arguments to label the output of each rank or to write it to a directory
+func GetRankOutputArgs(mode string, dir string) []string {
+	switch mode {
+	case rankoutput.Label:
+		return []string{"-prepend-rank"}
+	case rankoutput.Files:
+		return []string{
+			"-outfile-pattern", rankoutput.FilePattern(dir, "%r", rankoutput.StdoutSuffix),
+			"-errfile-pattern", rankoutput.FilePattern(dir, "%r", rankoutput.StderrSuffix),
+		}
+	}
+	return nil
+}
+
+// GetEnvArgs returns the Hydra arguments to propagate environment variables to all ranks. Variables specified as
+// KEY=VALUE are set with -genv, variables specified only by their name are propagated from the environment of
+// mpirun with -genvlist.
+func GetEnvArgs(env []string) []string {
+	var args []string
+	var names []string
+	for _, e := range env {
+		idx := strings.Index(e, "=")
+		if idx == -1 {
+			names = append(names, e)
+			continue
+		}
+		args = append(args, "-genv", e[:idx], e[idx+1:])
+	}
+	if len(names) > 0 {
+		args = append(args, "-genvlist", strings.Join(names, ","))
+	}
+	return args
+}
+
 // GetConfigureExtraArgs returns the extra arguments required to configure MPICH
 func GetConfigureExtraArgs() []string {
 	var extraArgs []string
@@ -40,7 +154,7 @@ func GetConfigureExtraArgs() []string {
 func parseMPICHInfoOutputForVersion(output string) (string, error) {
 	targetLineIdx := 1
 	lines := strings.Split(output, "\n")
-	if !strings.Contains(lines[targetLineIdx], "Version:") {
+	if len(lines) <= targetLineIdx || !strings.Contains(lines[targetLineIdx], "Version:") {
 		return "", fmt.Errorf("invalid output format")
 	}
 	tokens := strings.Split(lines[targetLineIdx], "Version:")
@@ -54,11 +168,11 @@ func parseMPICHInfoOutputForVersion(output string) (string, error) {
 	return version, nil
 }
 
-// DetectFromDir tries to figure out which version of MPICH is installed in a given directory
-func DetectFromDir(dir string, env []string) (string, string, error) {
+// runMpirunVersion executes 'mpirun --version' from a given installation and returns its output
+func runMpirunVersion(dir string, env []string) (string, error) {
 	targetBin := filepath.Join(dir, "bin", "mpirun")
 	if !util.FileExists(targetBin) {
-		return "", "", fmt.Errorf("%s does not exist, not an MPICH implementation", targetBin)
+		return "", fmt.Errorf("%s does not exist, not an MPICH implementation", targetBin)
 	}
 
 	var versionCmd advexec.Advcmd
@@ -74,12 +188,99 @@ func DetectFromDir(dir string, env []string) (string, string, error) {
 	}
 	res := versionCmd.Run()
 	if res.Err != nil {
-		return "", "", fmt.Errorf("unable to execute %s --version: %w", targetBin, res.Err)
+		return "", fmt.Errorf("unable to execute %s --version: %w", targetBin, res.Err)
+	}
+	return res.Stdout, nil
+}
+
+// DetectFromDir tries to figure out which version of MPICH is installed in a given directory
+func DetectFromDir(dir string, env []string) (string, string, error) {
+	output, err := runMpirunVersion(dir, env)
+	if err != nil {
+		return "", "", err
 	}
-	version, err := parseMPICHInfoOutputForVersion(res.Stdout)
+	version, err := parseMPICHInfoOutputForVersion(output)
 	if err != nil {
 		return "", "", fmt.Errorf("parseOmpiInfoOutputForVersion() failed - %w", err)
 	}
 
 	return ID, version, nil
 }
+
+// parseMPICHBuildOutput extracts the build details from the output of mpichversion ("MPICH Device: ch4:ofi",
+// "MPICH configure: ...", "MPICH CC: gcc -O2") or 'mpirun --version' ("Configure options: ...", "CC: gcc").
+// The output of the tools of MPICH-derived implementations (e.g., "MVAPICH2 Device: ...") is also supported.
+func parseMPICHBuildOutput(output string) (*buildinfo.Info, error) {
+	info := new(buildinfo.Info)
+	found := false
+	for _, line := range strings.Split(output, "\n") {
+		idx := strings.Index(line, ":")
+		if idx == -1 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:idx]))
+		value := strings.TrimSpace(line[idx+1:])
+		switch {
+		case key == "device" || strings.HasSuffix(key, " device"):
+			info.SetDevice(value)
+			found = true
+		case key == "configure options" || strings.HasSuffix(key, " configure"):
+			info.AddConfigureFlags(buildinfo.SplitConfigureFlags(value))
+			found = true
+		case key == "cc" || strings.HasSuffix(key, " cc"):
+			fields := strings.Fields(value)
+			if len(fields) > 0 {
+				info.Compiler = fields[0]
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no build details found")
+	}
+
+	// Without a device, the network module is given by the preprocessor flags of ch4 builds
+	if info.Device == "" {
+		for _, f := range info.ConfigureFlags {
+			idx := strings.Index(f, "-DNETMOD_INLINE=__netmod_inline_")
+			if idx == -1 {
+				continue
+			}
+			fields := strings.Fields(strings.TrimPrefix(f[idx:], "-DNETMOD_INLINE=__netmod_inline_"))
+			if len(fields) == 0 || strings.TrimSuffix(fields[0], "__") == "" {
+				continue
+			}
+			info.SetDevice("ch4:" + strings.TrimSuffix(fields[0], "__"))
+			break
+		}
+	}
+	return info, nil
+}
+
+// GetBuildInfo returns the details about how the MPICH installed in a given directory was built, from mpichversion
+// or, if not available, from 'mpirun --version'
+func GetBuildInfo(dir string, env []string) (*buildinfo.Info, error) {
+	mpichversionBin := filepath.Join(dir, "bin", "mpichversion")
+	if util.FileExists(mpichversionBin) {
+		var cmd advexec.Advcmd
+		cmd.BinPath = mpichversionBin
+		cmd.ExecDir = filepath.Join(dir, "bin")
+		cmd.Env = env
+		if env == nil {
+			newLDPath := filepath.Join(dir, "lib") + ":$LD_LIBRARY_PATH"
+			cmd.Env = append(cmd.Env, "LD_LIBRARY_PATH="+newLDPath)
+		}
+		res := cmd.Run()
+		if res.Err == nil {
+			info, err := parseMPICHBuildOutput(res.Stdout)
+			if err == nil {
+				return info, nil
+			}
+		}
+	}
+
+	output, err := runMpirunVersion(dir, env)
+	if err != nil {
+		return nil, err
+	}
+	return parseMPICHBuildOutput(output)
+}
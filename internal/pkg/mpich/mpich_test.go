@@ -5,7 +5,12 @@
 
 package mpich
 
-import "testing"
+import (
+	"strings"
+	"testing"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/network"
+)
 
 func TestParseMPICHInfoOutputForVersion(t *testing.T) {
 	tests := []struct {
@@ -53,3 +58,130 @@ func TestParseMPICHInfoOutputForVersion(t *testing.T) {
 		}
 	}
 }
+
+func TestGetExtraMpirunArgs(t *testing.T) {
+	netCfg := network.Config{
+		Device:   "mlx5_0:1",
+		Provider: "verbs",
+	}
+	args := GetExtraMpirunArgs(nil, &netCfg, []string{"-l"})
+	expected := "-l -genv UCX_NET_DEVICES mlx5_0:1 -genv FI_PROVIDER verbs"
+	if strings.Join(args, " ") != expected {
+		t.Fatalf("GetExtraMpirunArgs() returned %s instead of %s", strings.Join(args, " "), expected)
+	}
+
+	args = GetExtraMpirunArgs(nil, nil, nil)
+	if len(args) != 0 {
+		t.Fatalf("GetExtraMpirunArgs() returned %s without network configuration", strings.Join(args, " "))
+	}
+
+	args = GetExtraMpirunArgs(nil, &network.Config{Transport: network.TCPTransport, Device: "eth0"}, nil)
+	expected = "-iface eth0 -genv UCX_NET_DEVICES eth0 -genv UCX_TLS tcp,self,sm -genv FI_PROVIDER tcp"
+	if strings.Join(args, " ") != expected {
+		t.Fatalf("GetExtraMpirunArgs() returned %s instead of %s", strings.Join(args, " "), expected)
+	}
+}
+
+func TestGetLaunchOptionsArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name:     "binding",
+			args:     GetBindingArgs("core", "socket"),
+			expected: "-bind-to core -map-by socket",
+		},
+		{
+			name:     "binding only",
+			args:     GetBindingArgs("numa", ""),
+			expected: "-bind-to numa",
+		},
+		{
+			name:     "hostfile",
+			args:     GetHostfileArgs("/tmp/hosts"),
+			expected: "-f /tmp/hosts",
+		},
+		{
+			name:     "env",
+			args:     GetEnvArgs([]string{"OMP_NUM_THREADS=4", "HOME", "UCX_TLS=rc,sm", "PATH"}),
+			expected: "-genv OMP_NUM_THREADS 4 -genv UCX_TLS rc,sm -genvlist HOME,PATH",
+		},
+	}
+
+	for _, tt := range tests {
+		if strings.Join(tt.args, " ") != tt.expected {
+			t.Fatalf("%s: invalid arguments %s instead of %s", tt.name, strings.Join(tt.args, " "), tt.expected)
+		}
+	}
+
+	if len(GetHostfileArgs("")) != 0 || len(GetBindingArgs("", "")) != 0 || len(GetEnvArgs(nil)) != 0 {
+		t.Fatalf("arguments generated without options")
+	}
+}
+
+func TestParseMPICHBuildOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		compiler string
+		device   string
+		flags    string
+		features string
+	}{
+		{
+			name: "mpichversion",
+			output: `MPICH Version:      4.1.2
+MPICH Release date: Wed Jun  7 15:22:45 CDT 2023
+MPICH ABI:          15:1:3
+MPICH Device:       ch4:ucx
+MPICH configure:    --prefix=/opt/mpich-4.1.2 --with-device=ch4:ucx --with-cuda=/usr/local/cuda
+MPICH CC:           gcc    -O2
+MPICH CXX:          g++   -O2`,
+			compiler: "gcc",
+			device:   "ch4:ucx",
+			flags:    "--prefix=/opt/mpich-4.1.2|--with-device=ch4:ucx|--with-cuda=/usr/local/cuda",
+			features: "UCX CUDA",
+		},
+		{
+			name: "hydra",
+			output: `HYDRA build details:
+    Version:                                 4.1.2
+    CC:                              gcc
+    Configure options:                       '--prefix=/opt/mpich-4.1.2' 'CPPFLAGS=-DNETMOD_INLINE=__netmod_inline_ofi__ -D_REENTRANT'
+    Process Manager:                         pmi`,
+			compiler: "gcc",
+			device:   "ch4:ofi",
+			flags:    "--prefix=/opt/mpich-4.1.2|CPPFLAGS=-DNETMOD_INLINE=__netmod_inline_ofi__ -D_REENTRANT",
+			features: "libfabric",
+		},
+		{
+			name:     "truncatedNetmod",
+			output:   "MPICH configure: CPPFLAGS=-DNETMOD_INLINE=__netmod_inline_\n",
+			flags:    "CPPFLAGS=-DNETMOD_INLINE=__netmod_inline_",
+			features: "",
+		},
+	}
+
+	for _, tt := range tests {
+		info, err := parseMPICHBuildOutput(tt.output)
+		if err != nil {
+			t.Fatalf("%s: parseMPICHBuildOutput() failed: %s", tt.name, err)
+		}
+		if info.Compiler != tt.compiler || info.Device != tt.device {
+			t.Fatalf("%s: parseMPICHBuildOutput() returned compiler %s and device %s instead of %s and %s", tt.name, info.Compiler, info.Device, tt.compiler, tt.device)
+		}
+		if strings.Join(info.ConfigureFlags, "|") != tt.flags {
+			t.Fatalf("%s: parseMPICHBuildOutput() returned %s instead of %s", tt.name, strings.Join(info.ConfigureFlags, "|"), tt.flags)
+		}
+		if strings.Join(info.Features(), " ") != tt.features {
+			t.Fatalf("%s: parseMPICHBuildOutput() returned features %s instead of %s", tt.name, strings.Join(info.Features(), " "), tt.features)
+		}
+	}
+
+	_, err := parseMPICHBuildOutput("mpirun: unknown option\n")
+	if err == nil {
+		t.Fatalf("parseMPICHBuildOutput() succeeded with an invalid output")
+	}
+}
@@ -8,10 +8,13 @@ package mvapich2
 import (
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/BTMichalowicz/go_exec/pkg/advexec"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/network"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/buildinfo"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/placement"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/sys"
 	"github.com/BTMichalowicz/go_util/pkg/util"
 )
@@ -32,16 +35,126 @@ const (
 	VersionPrefix1 = "MVAPICH2 Version: "
 
 	VersionPrefix2 = "Version: "
+
+	// RshLauncher is the default launcher of MVAPICH2
+	RshLauncher = "mpirun_rsh"
+
+	// HydraLauncher is the Hydra launcher that some MVAPICH2 builds provide
+	HydraLauncher = "mpiexec.hydra"
 )
 
-// GetExtraMpirunArgs returns the set of arguments required for the mpirun command for the target platform
+// DefaultEnv is the default MVAPICH2 configuration, users can override any of these values
+var DefaultEnv = []string{
+	"MV2_HOMOGENEOUS_CLUSTER=1",
+	"MV2_USE_RDMA_CM=0",
+	"MV2_CPU_BINDING_POLICY=hybrid",
+	"MV2_HYBRID_BINDING_POLICY=spread",
+}
+
+// envName returns the name of a variable specified as KEY=VALUE or KEY
+func envName(e string) string {
+	idx := strings.Index(e, "=")
+	if idx == -1 {
+		return e
+	}
+	return e[:idx]
+}
+
+// mergeEnv removes duplicated variables from a list of variables: the last value of a variable is kept but the
+// variable stays at the position where it first appears
+func mergeEnv(env []string) []string {
+	var merged []string
+	index := make(map[string]int)
+	for _, e := range env {
+		name := envName(e)
+		if idx, ok := index[name]; ok {
+			merged[idx] = e
+			continue
+		}
+		index[name] = len(merged)
+		merged = append(merged, e)
+	}
+	return merged
+}
+
+// GetEnv returns the environment of the ranks of a job: the default configuration, the network settings and
+// finally the variables specified by the user, which override the previous ones
+func GetEnv(netCfg *network.Config, env []string) []string {
+	all := append([]string{}, DefaultEnv...)
+	if netCfg != nil && netCfg.Device != "" && netCfg.Transport != network.TCPTransport {
+		// MVAPICH2 expects the name of the HCA, without the port (e.g., mlx5_0 for mlx5_0:1)
+		all = append(all, "MV2_IBA_HCA="+strings.Split(netCfg.Device, ":")[0])
+	}
+	all = append(all, env...)
+	return mergeEnv(all)
+}
+
+// GetBindingEnv returns the variables to bind and map processes, e.g., "core" and "scatter"
+func GetBindingEnv(bindTo string, mapBy string) []string {
+	var env []string
+	if bindTo != "" {
+		env = append(env, "MV2_CPU_BINDING_LEVEL="+bindTo)
+	}
+	if mapBy != "" {
+		env = append(env, "MV2_CPU_BINDING_POLICY="+mapBy)
+	}
+	return env
+}
+
+// GetPlacementEnv returns the variables to map and bind processes according to a placement spec. The number of ranks
+// per node is not handled through the environment but through the hostfile (mpirun_rsh) or -ppn (Hydra).
+func GetPlacementEnv(s *placement.Spec) ([]string, error) {
+	if s.IsEmpty() {
+		return nil, nil
+	}
+	if s.RanksPerSocket > 0 {
+		return nil, fmt.Errorf("MVAPICH2 cannot start a given number of ranks per socket")
+	}
+
+	var env []string
+	switch s.BindTo {
+	case "":
+	case placement.None:
+		env = append(env, "MV2_ENABLE_AFFINITY=0")
+	case placement.Core, placement.Socket:
+		env = append(env, "MV2_CPU_BINDING_LEVEL="+string(s.BindTo))
+	case placement.NUMA:
+		env = append(env, "MV2_CPU_BINDING_LEVEL=numanode")
+	default:
+		return nil, fmt.Errorf("MVAPICH2 cannot bind processes to %s", s.BindTo)
+	}
+	if s.ThreadsPerRank > 0 {
+		env = append(env, "MV2_THREADS_PER_PROCESS="+strconv.Itoa(s.ThreadsPerRank))
+	}
+	return env, nil
+}
+
+// GetRshEnvArgs returns the mpirun_rsh arguments to set environment variables. mpirun_rsh only accepts KEY=VALUE
+// assignments so variables specified only by their name require -export, which propagates the entire environment.
+func GetRshEnvArgs(env []string) []string {
+	var args []string
+	export := false
+	for _, e := range env {
+		if !strings.Contains(e, "=") {
+			export = true
+			continue
+		}
+		args = append(args, e)
+	}
+	if export {
+		args = append([]string{"-export"}, args...)
+	}
+	return args
+}
+
+// GetExtraMpirunArgs returns the set of arguments required for the mpirun_rsh command for the target platform
 func GetExtraMpirunArgs(sys *sys.Config, netCfg *network.Config, extraArgs []string) []string {
-	//extraArgs = append(extraArgs, "-genv")
-	extraArgs = append(extraArgs, "MV2_HOMOGENEOUS_CLUSTER=1")
-	extraArgs = append(extraArgs, "MV2_USE_RDMA_CM=0")
-	extraArgs = append(extraArgs, "MV2_CPU_BINDING_POLICY=hybrid")
-	extraArgs = append(extraArgs, "MV2_HYBRID_BINDING_POLICY=spread")
-	return extraArgs
+	return append(extraArgs, GetRshEnvArgs(GetEnv(netCfg, nil))...)
+}
+
+// HasHydra checks whether a MVAPICH2 installation provides the Hydra launcher in addition to mpirun_rsh
+func HasHydra(dir string) bool {
+	return util.FileExists(filepath.Join(dir, "bin", HydraLauncher))
 }
 
 func parseMVAPICH2InfoOutputForVersion(output string) (string, error) {
@@ -53,12 +166,12 @@ func parseMVAPICH2InfoOutputForVersion(output string) (string, error) {
 	version = strings.TrimPrefix(version, VersionPrefix1)
 	version = strings.TrimPrefix(version, VersionPrefix2)
 	version = strings.TrimPrefix(version, ID+"-")
-	return version, nil
+	return strings.TrimSpace(version), nil
 }
 
 // DetectFromDir tries to figure out which version of OpenMPI is installed in a given directory
 func DetectFromDir(dir string, env []string) (string, string, error) {
-	targetBin := filepath.Join(dir, "bin", "mpirun_rsh")
+	targetBin := filepath.Join(dir, "bin", RshLauncher)
 	if !util.FileExists(targetBin) {
 		return "", "", fmt.Errorf("%s does not exist, not an MVAPICH2 implementation", targetBin)
 	}
@@ -85,3 +198,74 @@ func DetectFromDir(dir string, env []string) (string, string, error) {
 
 	return ID, version, nil
 }
+
+// parseMpinameOutput extracts the build details from the output of 'mpiname -a', e.g.:
+//
+//	MVAPICH2 2.3.7 Wed March 02 22:00:00 EST 2022 ch3:mrail
+//
+//	Compilation
+//	CC: gcc    -DNDEBUG -DNVALGRIND -O2
+//	...
+//
+//	Configuration
+//	--prefix=/opt/mvapich2 --with-device=ch3:mrail
+func parseMpinameOutput(output string) (*buildinfo.Info, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	header := strings.Fields(lines[0])
+	if len(header) == 0 || !strings.HasPrefix(header[0], "MVAPICH2") {
+		return nil, fmt.Errorf("invalid output format")
+	}
+
+	info := new(buildinfo.Info)
+	// MVAPICH2-GDR is the CUDA-aware flavor of MVAPICH2
+	if strings.HasPrefix(header[0], "MVAPICH2-GDR") {
+		info.CUDA = true
+	}
+	if last := header[len(header)-1]; strings.Contains(last, ":") {
+		info.SetDevice(last)
+	}
+
+	section := ""
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			section = ""
+		case line == "Compilation" || line == "Configuration":
+			section = line
+		case section == "Compilation" && strings.HasPrefix(line, "CC:"):
+			fields := strings.Fields(strings.TrimPrefix(line, "CC:"))
+			if len(fields) > 0 {
+				info.Compiler = fields[0]
+			}
+		case section == "Configuration":
+			info.AddConfigureFlags(buildinfo.SplitConfigureFlags(line))
+		}
+	}
+	return info, nil
+}
+
+// GetBuildInfo returns the details about how the MVAPICH2 installed in a given directory was built
+func GetBuildInfo(dir string, env []string) (*buildinfo.Info, error) {
+	targetBin := filepath.Join(dir, "bin", "mpiname")
+	if !util.FileExists(targetBin) {
+		return nil, fmt.Errorf("%s does not exist", targetBin)
+	}
+
+	var cmd advexec.Advcmd
+	cmd.BinPath = targetBin
+	cmd.CmdArgs = append(cmd.CmdArgs, "-a")
+	cmd.ExecDir = filepath.Join(dir, "bin")
+	cmd.Env = env
+	if env == nil {
+		newLDPath := filepath.Join(dir, "lib") + ":$LD_LIBRARY_PATH"
+		newPath := filepath.Join(dir, "bin") + ":$PATH"
+		cmd.Env = append(cmd.Env, "LD_LIBRARY_PATH="+newLDPath)
+		cmd.Env = append(cmd.Env, "PATH="+newPath)
+	}
+	res := cmd.Run()
+	if res.Err != nil {
+		return nil, fmt.Errorf("unable to execute %s: %w", targetBin, res.Err)
+	}
+	return parseMpinameOutput(res.Stdout)
+}
@@ -5,7 +5,12 @@
 
 package mvapich2
 
-import "testing"
+import (
+	"strings"
+	"testing"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/network"
+)
 
 func TestParseMVAPICH2InfoOutputForVersion(t *testing.T) {
 	output := `MVAPICH2 Version:       2.3.7
@@ -26,3 +31,68 @@ func TestParseMVAPICH2InfoOutputForVersion(t *testing.T) {
 		t.Fatalf("parseMVAPICH2InfoOutputForVersion() returned %s instead of %s", version, expectedResult)
 	}
 }
+
+func TestGetRshEnvArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		netCfg   *network.Config
+		env      []string
+		expected string
+	}{
+		{
+			name:     "defaults",
+			expected: "MV2_HOMOGENEOUS_CLUSTER=1 MV2_USE_RDMA_CM=0 MV2_CPU_BINDING_POLICY=hybrid MV2_HYBRID_BINDING_POLICY=spread",
+		},
+		{
+			name:     "overrides",
+			netCfg:   &network.Config{Device: "mlx5_0:1"},
+			env:      append(GetBindingEnv("core", "scatter"), "MV2_USE_RDMA_CM=1", "OMP_NUM_THREADS=4"),
+			expected: "MV2_HOMOGENEOUS_CLUSTER=1 MV2_USE_RDMA_CM=1 MV2_CPU_BINDING_POLICY=scatter MV2_HYBRID_BINDING_POLICY=spread MV2_IBA_HCA=mlx5_0 MV2_CPU_BINDING_LEVEL=core OMP_NUM_THREADS=4",
+		},
+		{
+			name:     "export",
+			env:      []string{"HOME", "MV2_HOMOGENEOUS_CLUSTER=0"},
+			expected: "-export MV2_HOMOGENEOUS_CLUSTER=0 MV2_USE_RDMA_CM=0 MV2_CPU_BINDING_POLICY=hybrid MV2_HYBRID_BINDING_POLICY=spread",
+		},
+	}
+
+	for _, tt := range tests {
+		args := strings.Join(GetRshEnvArgs(GetEnv(tt.netCfg, tt.env)), " ")
+		if args != tt.expected {
+			t.Fatalf("%s: GetRshEnvArgs() returned %s instead of %s", tt.name, args, tt.expected)
+		}
+	}
+}
+
+func TestParseMpinameOutput(t *testing.T) {
+	output := `MVAPICH2 2.3.7 Wed March 02 22:00:00 EST 2022 ch3:mrail
+
+Compilation
+CC: gcc    -DNDEBUG -DNVALGRIND -O2
+CXX: g++   -DNDEBUG -DNVALGRIND -O2
+
+Configuration
+--prefix=/opt/mvapich2-2.3.7 --with-device=ch3:mrail --with-rdma=gen2 --with-pmi=pmix --disable-fortran
+`
+	info, err := parseMpinameOutput(output)
+	if err != nil {
+		t.Fatalf("parseMpinameOutput() failed: %s", err)
+	}
+	expected := "--prefix=/opt/mvapich2-2.3.7 --with-device=ch3:mrail --with-rdma=gen2 --with-pmi=pmix --disable-fortran"
+	if strings.Join(info.ConfigureFlags, " ") != expected {
+		t.Fatalf("parseMpinameOutput() returned %s instead of %s", strings.Join(info.ConfigureFlags, " "), expected)
+	}
+	if info.Compiler != "gcc" || info.Device != "ch3:mrail" || info.Netmod != "mrail" || !info.PMIx || info.CUDA {
+		t.Fatalf("parseMpinameOutput() returned invalid build details: %+v", info)
+	}
+
+	info, err = parseMpinameOutput("MVAPICH2-GDR 2.3.7 Thu March 03 22:00:00 EST 2022 ch3:mrail\n")
+	if err != nil || !info.CUDA {
+		t.Fatalf("MVAPICH2-GDR is not detected as CUDA-aware")
+	}
+
+	_, err = parseMpinameOutput("Open MPI v4.1.5\n")
+	if err == nil {
+		t.Fatalf("parseMpinameOutput() succeeded with an invalid output")
+	}
+}
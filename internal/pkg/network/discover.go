@@ -0,0 +1,248 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package network
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultSysfsRoot is the directory under which sysfs is expected, i.e., devices are discovered from
+	// <root>/sys/class
+	DefaultSysfsRoot = "/"
+
+	// PortActive is the state of a RDMA port that can be used
+	PortActive = "ACTIVE"
+
+	// InterfaceUp is the operational state of a network interface that can be used
+	InterfaceUp = "up"
+
+	// InfiniBandLinkLayer is the link layer of InfiniBand ports
+	InfiniBandLinkLayer = "InfiniBand"
+
+	// EthernetLinkLayer is the link layer of Ethernet ports, e.g., RoCE
+	EthernetLinkLayer = "Ethernet"
+
+	// ARP hardware types of network interfaces, from /sys/class/net/<interface>/type
+	arpEther      = 1
+	arpInfiniBand = 32
+	arpLoopback   = 772
+)
+
+// Port is a port of a RDMA device
+type Port struct {
+	// Number is the number of the port, starting at 1
+	Number int
+
+	// State is the state of the port (e.g., ACTIVE, DOWN)
+	State string
+
+	// LinkLayer is the link layer of the port (InfiniBand or Ethernet)
+	LinkLayer string
+
+	// Speed is the rate of the port in Gb/s, 0 when unknown
+	Speed int
+}
+
+// RDMADevice is a RDMA device (e.g., mlx5_0) from /sys/class/infiniband
+type RDMADevice struct {
+	// Name is the name of the device
+	Name string
+
+	// Ports is the list of ports of the device
+	Ports []Port
+
+	// Interfaces is the list of network interfaces associated to the device (e.g., ib0)
+	Interfaces []string
+}
+
+// Interface is a network interface from /sys/class/net
+type Interface struct {
+	// Name is the name of the interface
+	Name string
+
+	// State is the operational state of the interface (e.g., up, down)
+	State string
+
+	// LinkLayer is the link layer of the interface (InfiniBand, Ethernet or empty for others, e.g., loopback)
+	LinkLayer string
+
+	// Speed is the speed of the interface in Mb/s, 0 when unknown
+	Speed int
+
+	// Loopback specifies whether the interface is a loopback interface
+	Loopback bool
+}
+
+// Devices gathers the network devices available on a host
+type Devices struct {
+	RDMA       []RDMADevice
+	Interfaces []Interface
+}
+
+// readSysfsFile returns the trimmed content of a sysfs file, empty if the file cannot be read
+func readSysfsFile(path string) string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// listDir returns the sorted names of the entries of a directory, nil if the directory cannot be read
+func listDir(dir string) []string {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parsePortState parses the state of a RDMA port, e.g., "4: ACTIVE" gives ACTIVE
+func parsePortState(state string) string {
+	idx := strings.Index(state, ":")
+	if idx == -1 {
+		return state
+	}
+	return strings.TrimSpace(state[idx+1:])
+}
+
+// parsePortRate parses the rate of a RDMA port, e.g., "100 Gb/sec (4X EDR)" gives 100
+func parsePortRate(rate string) int {
+	tokens := strings.Fields(rate)
+	if len(tokens) == 0 {
+		return 0
+	}
+	speed, err := strconv.ParseFloat(tokens[0], 64)
+	if err != nil {
+		return 0
+	}
+	return int(speed)
+}
+
+func discoverRDMADevices(root string) []RDMADevice {
+	classDir := filepath.Join(root, "sys", "class", "infiniband")
+	var devices []RDMADevice
+	for _, name := range listDir(classDir) {
+		dev := RDMADevice{Name: name}
+		devDir := filepath.Join(classDir, name)
+		for _, p := range listDir(filepath.Join(devDir, "ports")) {
+			number, err := strconv.Atoi(p)
+			if err != nil {
+				continue
+			}
+			portDir := filepath.Join(devDir, "ports", p)
+			dev.Ports = append(dev.Ports, Port{
+				Number:    number,
+				State:     parsePortState(readSysfsFile(filepath.Join(portDir, "state"))),
+				LinkLayer: readSysfsFile(filepath.Join(portDir, "link_layer")),
+				Speed:     parsePortRate(readSysfsFile(filepath.Join(portDir, "rate"))),
+			})
+		}
+		sort.Slice(dev.Ports, func(i, j int) bool { return dev.Ports[i].Number < dev.Ports[j].Number })
+		dev.Interfaces = listDir(filepath.Join(devDir, "device", "net"))
+		devices = append(devices, dev)
+	}
+	return devices
+}
+
+func discoverInterfaces(root string) []Interface {
+	classDir := filepath.Join(root, "sys", "class", "net")
+	var interfaces []Interface
+	for _, name := range listDir(classDir) {
+		ifDir := filepath.Join(classDir, name)
+		i := Interface{
+			Name:  name,
+			State: readSysfsFile(filepath.Join(ifDir, "operstate")),
+		}
+		// Reading the speed fails or gives -1 for interfaces that are down or virtual
+		speed, err := strconv.Atoi(readSysfsFile(filepath.Join(ifDir, "speed")))
+		if err == nil && speed > 0 {
+			i.Speed = speed
+		}
+		arpType, _ := strconv.Atoi(readSysfsFile(filepath.Join(ifDir, "type")))
+		switch arpType {
+		case arpEther:
+			i.LinkLayer = EthernetLinkLayer
+		case arpInfiniBand:
+			i.LinkLayer = InfiniBandLinkLayer
+		case arpLoopback:
+			i.Loopback = true
+		}
+		interfaces = append(interfaces, i)
+	}
+	return interfaces
+}
+
+// Discover returns the network devices available from the sysfs found under a given root directory
+// (DefaultSysfsRoot on a real system)
+func Discover(root string) (*Devices, error) {
+	if root == "" {
+		root = DefaultSysfsRoot
+	}
+	classDir := filepath.Join(root, "sys", "class")
+	if _, err := os.Stat(classDir); err != nil {
+		return nil, fmt.Errorf("unable to access %s: %w", classDir, err)
+	}
+
+	d := new(Devices)
+	d.RDMA = discoverRDMADevices(root)
+	d.Interfaces = discoverInterfaces(root)
+	return d, nil
+}
+
+// DefaultRDMADevice returns the fastest active RDMA port, e.g., mlx5_0:1, preferring InfiniBand to Ethernet (RoCE).
+// It returns an empty string when no RDMA port is active.
+func (d *Devices) DefaultRDMADevice() string {
+	best := ""
+	var bestPort *Port
+	for _, dev := range d.RDMA {
+		for idx := range dev.Ports {
+			p := &dev.Ports[idx]
+			if p.State != PortActive {
+				continue
+			}
+			if bestPort != nil {
+				bestIB := bestPort.LinkLayer == InfiniBandLinkLayer
+				ib := p.LinkLayer == InfiniBandLinkLayer
+				if bestIB && !ib || bestIB == ib && p.Speed <= bestPort.Speed {
+					continue
+				}
+			}
+			best = fmt.Sprintf("%s:%d", dev.Name, p.Number)
+			bestPort = p
+		}
+	}
+	return best
+}
+
+// DefaultInterface returns the fastest network interface that is up, loopback interfaces excluded. It returns an
+// empty string when no interface is up.
+func (d *Devices) DefaultInterface() string {
+	best := ""
+	bestSpeed := -1
+	for _, i := range d.Interfaces {
+		if i.Loopback || i.State != InterfaceUp {
+			continue
+		}
+		if i.Speed > bestSpeed {
+			best = i.Name
+			bestSpeed = i.Speed
+		}
+	}
+	return best
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package network
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/testutil"
+)
+
+func TestDiscover(t *testing.T) {
+	root, err := ioutil.TempDir("", "network-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	testutil.WriteFiles(t, root, map[string]string{
+		"sys/class/infiniband/mlx5_0/ports/1/state":      "4: ACTIVE",
+		"sys/class/infiniband/mlx5_0/ports/1/link_layer": "InfiniBand",
+		"sys/class/infiniband/mlx5_0/ports/1/rate":       "100 Gb/sec (4X EDR)",
+		"sys/class/infiniband/mlx5_0/device/net/ib0/mtu": "2044",
+		"sys/class/infiniband/mlx5_1/ports/1/state":      "1: DOWN",
+		"sys/class/infiniband/mlx5_1/ports/1/link_layer": "InfiniBand",
+		"sys/class/infiniband/mlx5_1/ports/1/rate":       "200 Gb/sec (4X HDR)",
+		"sys/class/infiniband/mlx5_2/ports/1/state":      "4: ACTIVE",
+		"sys/class/infiniband/mlx5_2/ports/1/link_layer": "Ethernet",
+		"sys/class/infiniband/mlx5_2/ports/1/rate":       "200 Gb/sec (4X HDR)",
+		"sys/class/net/lo/operstate":                     "unknown",
+		"sys/class/net/lo/type":                          "772",
+		"sys/class/net/eth0/operstate":                   "up",
+		"sys/class/net/eth0/type":                        "1",
+		"sys/class/net/eth0/speed":                       "1000",
+		"sys/class/net/eth1/operstate":                   "up",
+		"sys/class/net/eth1/type":                        "1",
+		"sys/class/net/eth1/speed":                       "25000",
+		"sys/class/net/eth2/operstate":                   "down",
+		"sys/class/net/eth2/type":                        "1",
+		"sys/class/net/eth2/speed":                       "-1",
+		"sys/class/net/ib0/operstate":                    "up",
+		"sys/class/net/ib0/type":                         "32",
+	}, 0644)
+
+	d, err := Discover(root)
+	if err != nil {
+		t.Fatalf("Discover() failed: %s", err)
+	}
+	if len(d.RDMA) != 3 || len(d.Interfaces) != 5 {
+		t.Fatalf("Discover() found %d RDMA devices and %d interfaces instead of 3 and 5", len(d.RDMA), len(d.Interfaces))
+	}
+	dev := d.RDMA[0]
+	if dev.Name != "mlx5_0" || len(dev.Ports) != 1 || dev.Ports[0].State != PortActive || dev.Ports[0].Speed != 100 || dev.Ports[0].LinkLayer != InfiniBandLinkLayer {
+		t.Fatalf("Discover() returned an invalid device: %+v", dev)
+	}
+	if len(dev.Interfaces) != 1 || dev.Interfaces[0] != "ib0" {
+		t.Fatalf("Discover() returned invalid interfaces for %s: %v", dev.Name, dev.Interfaces)
+	}
+	if !d.Interfaces[4].Loopback || d.Interfaces[3].LinkLayer != InfiniBandLinkLayer || d.Interfaces[2].Speed != 0 {
+		t.Fatalf("Discover() returned invalid interfaces: %+v", d.Interfaces)
+	}
+
+	// InfiniBand is preferred to RoCE, even if slower
+	if d.DefaultRDMADevice() != "mlx5_0:1" {
+		t.Fatalf("DefaultRDMADevice() returned %s instead of mlx5_0:1", d.DefaultRDMADevice())
+	}
+	if d.DefaultInterface() != "eth1" {
+		t.Fatalf("DefaultInterface() returned %s instead of eth1", d.DefaultInterface())
+	}
+
+	_, err = Discover(filepath.Join(root, "missing"))
+	if err == nil {
+		t.Fatalf("Discover() succeeded with an invalid root")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	rdma := &Devices{
+		RDMA:       []RDMADevice{{Name: "mlx5_0", Ports: []Port{{Number: 1, State: PortActive, LinkLayer: InfiniBandLinkLayer}}}},
+		Interfaces: []Interface{{Name: "eth0", State: InterfaceUp}},
+	}
+	tcpOnly := &Devices{
+		Interfaces: []Interface{{Name: "lo", State: InterfaceUp, Loopback: true}, {Name: "eth0", State: InterfaceUp}},
+	}
+
+	tests := []struct {
+		name              string
+		cfg               Config
+		devices           *Devices
+		expectedTransport string
+		expectedDevice    string
+		expectedErr       bool
+	}{
+		{
+			name:              "default",
+			cfg:               Config{Device: "mlx5_1:1"},
+			expectedTransport: DefaultTransport,
+			expectedDevice:    "mlx5_1:1",
+		},
+		{
+			name:              "autoRDMA",
+			cfg:               Config{Transport: AutoTransport},
+			devices:           rdma,
+			expectedTransport: UCXTransport,
+			expectedDevice:    "mlx5_0:1",
+		},
+		{
+			name:              "autoTCP",
+			cfg:               Config{Transport: AutoTransport},
+			devices:           tcpOnly,
+			expectedTransport: TCPTransport,
+			expectedDevice:    "eth0",
+		},
+		{
+			name:              "tcp",
+			cfg:               Config{Transport: TCPTransport},
+			devices:           rdma,
+			expectedTransport: TCPTransport,
+			expectedDevice:    "eth0",
+		},
+		{
+			name:              "ofiWithoutRDMA",
+			cfg:               Config{Transport: OFITransport, Provider: "tcp"},
+			devices:           tcpOnly,
+			expectedTransport: OFITransport,
+		},
+		{
+			name:        "ucxWithoutRDMA",
+			cfg:         Config{Transport: UCXTransport},
+			devices:     tcpOnly,
+			expectedErr: true,
+		},
+		{
+			name:        "tcpWithRDMADevice",
+			cfg:         Config{Transport: TCPTransport, Device: "mlx5_0:1"},
+			expectedErr: true,
+		},
+		{
+			name:        "providerWithUCX",
+			cfg:         Config{Transport: UCXTransport, Provider: "verbs"},
+			expectedErr: true,
+		},
+		{
+			name:        "unknownTransport",
+			cfg:         Config{Transport: "psm2"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			err := cfg.Resolve(tt.devices)
+			if tt.expectedErr {
+				if err == nil {
+					t.Fatalf("Resolve() succeeded but was expected to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() failed: %s", err)
+			}
+			if cfg.Transport != tt.expectedTransport || cfg.Device != tt.expectedDevice {
+				t.Fatalf("Resolve() gave %s/%s instead of %s/%s", cfg.Transport, cfg.Device, tt.expectedTransport, tt.expectedDevice)
+			}
+		})
+	}
+}
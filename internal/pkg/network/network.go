@@ -5,8 +5,96 @@
 
 package network
 
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// DefaultTransport lets the MPI implementation pick the transport
+	DefaultTransport = ""
+
+	// UCXTransport runs applications over UCX, Device being a RDMA device and port (e.g., mlx5_0:1)
+	UCXTransport = "ucx"
+
+	// OFITransport runs applications over libfabric, with the provider from Provider (e.g., verbs, cxi)
+	OFITransport = "ofi"
+
+	// TCPTransport runs applications over TCP, Device being a network interface (e.g., eth0)
+	TCPTransport = "tcp"
+
+	// AutoTransport picks the transport and device based on the devices discovered on the host
+	AutoTransport = "auto"
+)
+
 // Config is the network configuration to use
 type Config struct {
-	// Device is the network ID to use to run application
+	// Device is the network ID to use to run application: a RDMA device and port (e.g., mlx5_0:1) with UCX and
+	// libfabric, a network interface (e.g., eth0) with TCP
 	Device string
+
+	// Provider is the libfabric provider to use to run application (optional)
+	Provider string
+
+	// Transport is the transport to use to run application (optional, DefaultTransport by default)
+	Transport string
+}
+
+// Validate checks that a network configuration is valid
+func (c *Config) Validate() error {
+	switch c.Transport {
+	case DefaultTransport, UCXTransport, OFITransport, TCPTransport, AutoTransport:
+	default:
+		return fmt.Errorf("unknown transport %s", c.Transport)
+	}
+	if c.Transport == TCPTransport && strings.Contains(c.Device, ":") {
+		return fmt.Errorf("%s is not a network interface", c.Device)
+	}
+	if c.Provider != "" && c.Transport != DefaultTransport && c.Transport != OFITransport {
+		return fmt.Errorf("libfabric provider %s cannot be used with transport %s", c.Provider, c.Transport)
+	}
+	return nil
+}
+
+// Resolve replaces AutoTransport and, when no device is specified, selects the default device of the transport
+// based on the devices available. Configurations that do not need the devices are returned as is.
+func (c *Config) Resolve(d *Devices) error {
+	err := c.Validate()
+	if err != nil {
+		return err
+	}
+	if c.Transport == DefaultTransport || c.Device != "" && c.Transport != AutoTransport {
+		return nil
+	}
+	if d == nil {
+		return fmt.Errorf("no network device information")
+	}
+
+	if c.Transport == AutoTransport {
+		c.Transport = UCXTransport
+		if c.Provider != "" {
+			c.Transport = OFITransport
+		}
+		if c.Device != "" {
+			return nil
+		}
+		if d.DefaultRDMADevice() == "" {
+			c.Transport = TCPTransport
+		}
+	}
+
+	switch c.Transport {
+	case OFITransport:
+		// libfabric providers such as tcp do not need a RDMA device
+		c.Device = d.DefaultRDMADevice()
+		return nil
+	case UCXTransport:
+		c.Device = d.DefaultRDMADevice()
+	case TCPTransport:
+		c.Device = d.DefaultInterface()
+	}
+	if c.Device == "" {
+		return fmt.Errorf("no usable device for transport %s", c.Transport)
+	}
+	return nil
 }
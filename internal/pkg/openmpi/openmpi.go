@@ -14,6 +14,9 @@ import (
 
 	"github.com/BTMichalowicz/go_exec/pkg/advexec"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/network"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/buildinfo"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/placement"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/rankoutput"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/sys"
 	"github.com/BTMichalowicz/go_util/pkg/util"
 )
@@ -34,19 +37,89 @@ const (
 
 // GetExtraMpirunArgs returns the set of arguments required for the mpirun command for the target platform
 func GetExtraMpirunArgs(sys *sys.Config, netCfg *network.Config, extraArgs []string) []string {
-	// By default we always prefer UCX rather than openib
-	extraArgs = append(extraArgs, "--mca")
-	extraArgs = append(extraArgs, "btl")
-	extraArgs = append(extraArgs, "^openib")
-	extraArgs = append(extraArgs, "--mca")
-	extraArgs = append(extraArgs, "pml")
-	extraArgs = append(extraArgs, "ucx")
-	if netCfg != nil && netCfg.Device != "" {
-		extraArgs = append(extraArgs, "-x UCX_NET_DEVICES="+netCfg.Device)
+	transport := network.DefaultTransport
+	if netCfg != nil {
+		transport = netCfg.Transport
+	}
+
+	switch transport {
+	case network.OFITransport:
+		extraArgs = append(extraArgs, "--mca", "pml", "cm", "--mca", "mtl", "ofi")
+		if netCfg.Provider != "" {
+			extraArgs = append(extraArgs, "--mca", "mtl_ofi_provider_include", netCfg.Provider)
+		}
+	case network.TCPTransport:
+		extraArgs = append(extraArgs, "--mca", "pml", "ob1", "--mca", "btl", "tcp,self,vader")
+		if netCfg.Device != "" {
+			extraArgs = append(extraArgs, "--mca", "btl_tcp_if_include", netCfg.Device)
+		}
+	default:
+		// By default we always prefer UCX rather than openib
+		extraArgs = append(extraArgs, "--mca")
+		extraArgs = append(extraArgs, "btl")
+		extraArgs = append(extraArgs, "^openib")
+		extraArgs = append(extraArgs, "--mca")
+		extraArgs = append(extraArgs, "pml")
+		extraArgs = append(extraArgs, "ucx")
+		if netCfg != nil && netCfg.Device != "" {
+			extraArgs = append(extraArgs, "-x", "UCX_NET_DEVICES="+netCfg.Device)
+		}
 	}
 	return extraArgs
 }
 
+// GetEnvArgs returns the mpirun arguments to set environment variables for all the ranks, either as KEY=VALUE or KEY
+// to pass the value of the environment of mpirun
+func GetEnvArgs(env []string) []string {
+	var args []string
+	for _, e := range env {
+		args = append(args, "-x", e)
+	}
+	return args
+}
+
+// GetRankOutputArgs returns the mpirun arguments to label the output of each rank or to write it to a directory
+func GetRankOutputArgs(mode string, dir string) []string {
+	switch mode {
+	case rankoutput.Label:
+		return []string{"--tag-output"}
+	case rankoutput.Files:
+		return []string{"--output-filename", dir}
+	}
+	return nil
+}
+
+// GetPlacementArgs returns the mpirun arguments to map and bind processes according to a placement spec
+func GetPlacementArgs(s *placement.Spec) []string {
+	if s.IsEmpty() {
+		return nil
+	}
+
+	var args []string
+	mapBy := ""
+	switch {
+	case s.RanksPerSocket > 0:
+		mapBy = fmt.Sprintf("ppr:%d:socket", s.RanksPerSocket)
+	case s.RanksPerNode > 0:
+		mapBy = fmt.Sprintf("ppr:%d:node", s.RanksPerNode)
+	case s.ThreadsPerRank > 1:
+		mapBy = "slot"
+	}
+	if mapBy != "" && s.ThreadsPerRank > 1 {
+		mapBy += fmt.Sprintf(":PE=%d", s.ThreadsPerRank)
+	}
+	if mapBy != "" {
+		args = append(args, "--map-by", mapBy)
+	}
+	if s.BindTo != "" {
+		args = append(args, "--bind-to", string(s.BindTo))
+	}
+	if s.Oversubscribe {
+		args = append(args, "--oversubscribe")
+	}
+	return args
+}
+
 func parseOmpiInfoOutputForVersion(output string) (string, error) {
 	lines := strings.Split(output, "\n")
 	if !strings.HasPrefix(lines[0], "Open MPI") {
@@ -57,16 +130,16 @@ func parseOmpiInfoOutputForVersion(output string) (string, error) {
 	return version, nil
 }
 
-// DetectFromDir tries to figure out which version of OpenMPI is installed in a given directory
-func DetectFromDir(dir string, env []string) (string, string, error) {
+// runOmpiInfo executes ompi_info from a given installation and returns its output
+func runOmpiInfo(dir string, env []string, args []string) (string, error) {
 	targetBin := filepath.Join(dir, "bin", "ompi_info")
 	if !util.FileExists(targetBin) {
-		return "", "", fmt.Errorf("%s does not exist, not an OpenMPI implementation", targetBin)
+		return "", fmt.Errorf("%s does not exist, not an OpenMPI implementation", targetBin)
 	}
 
 	var versionCmd advexec.Advcmd
 	versionCmd.BinPath = targetBin
-	versionCmd.CmdArgs = append(versionCmd.CmdArgs, "--version")
+	versionCmd.CmdArgs = args
 	versionCmd.ExecDir = filepath.Join(dir, "bin")
 	versionCmd.Env = env
 	if env == nil {
@@ -86,13 +159,92 @@ func DetectFromDir(dir string, env []string) (string, string, error) {
 		res = versionCmdWithOpalPrefix.Run()
 		if res.Err != nil {
 			log.Printf("unable to run ompi_info: %s; stdout: %s; stderr: %s", res.Err, res.Stdout, res.Stderr)
-			return "", "", res.Err
+			return "", res.Err
 		}
 	}
-	version, err := parseOmpiInfoOutputForVersion(res.Stdout)
+	return res.Stdout, nil
+}
+
+// DetectFromDir tries to figure out which version of OpenMPI is installed in a given directory
+func DetectFromDir(dir string, env []string) (string, string, error) {
+	output, err := runOmpiInfo(dir, env, []string{"--version"})
+	if err != nil {
+		return "", "", err
+	}
+	version, err := parseOmpiInfoOutputForVersion(output)
 	if err != nil {
 		return "", "", fmt.Errorf("parseOmpiInfoOutputForVersion() failed - %w", err)
 	}
 
 	return ID, version, nil
 }
+
+// parseOmpiInfoConfigureOptions extracts the configure options from the output of 'ompi_info -c'
+func parseOmpiInfoConfigureOptions(output string) ([]string, error) {
+	for _, line := range strings.Split(output, "\n") {
+		idx := strings.Index(line, "Configure command line:")
+		if idx != -1 {
+			return buildinfo.SplitConfigureFlags(line[idx+len("Configure command line:"):]), nil
+		}
+	}
+	return nil, fmt.Errorf("configure command line not found")
+}
+
+// parseOmpiInfoParsable sets the build details available from the output of 'ompi_info --parsable --all'
+func parseOmpiInfoParsable(output string, info *buildinfo.Info) {
+	family := ""
+	version := ""
+	command := ""
+	for _, line := range strings.Split(output, "\n") {
+		tokens := strings.Split(strings.TrimSpace(line), ":")
+		switch {
+		case strings.HasPrefix(line, "compiler:c:familyname:"):
+			family = strings.TrimPrefix(line, "compiler:c:familyname:")
+		case strings.HasPrefix(line, "compiler:c:version:"):
+			version = strings.TrimPrefix(line, "compiler:c:version:")
+		case strings.HasPrefix(line, "compiler:c:command:"):
+			command = strings.TrimPrefix(line, "compiler:c:command:")
+		case len(tokens) > 3 && tokens[0] == "mca" && tokens[2] == "ucx":
+			info.UCX = true
+		case len(tokens) > 3 && tokens[0] == "mca" && tokens[2] == "ofi":
+			info.Libfabric = true
+		case len(tokens) > 3 && tokens[0] == "mca" && tokens[1] == "pmix":
+			// These components do not rely on PMIx
+			switch tokens[2] {
+			case "base", "isolated", "s1", "s2", "flux":
+			default:
+				info.PMIx = true
+			}
+		case strings.Contains(line, "mpi_built_with_cuda_support:value:true"):
+			info.CUDA = true
+		}
+	}
+
+	switch {
+	case family != "":
+		info.Compiler = strings.TrimSpace(family + " " + version)
+	case command != "":
+		info.Compiler = command
+	}
+}
+
+// GetBuildInfo returns the details about how the Open MPI installed in a given directory was built
+func GetBuildInfo(dir string, env []string) (*buildinfo.Info, error) {
+	output, err := runOmpiInfo(dir, env, []string{"--parsable", "--all"})
+	if err != nil {
+		return nil, err
+	}
+	info := new(buildinfo.Info)
+	parseOmpiInfoParsable(output, info)
+
+	output, err = runOmpiInfo(dir, env, []string{"-c"})
+	if err != nil {
+		return nil, err
+	}
+	flags, err := parseOmpiInfoConfigureOptions(output)
+	if err != nil {
+		return nil, err
+	}
+	info.AddConfigureFlags(flags)
+	return info, nil
+}
@@ -5,7 +5,13 @@
 
 package openmpi
 
-import "testing"
+import (
+	"strings"
+	"testing"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/network"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/buildinfo"
+)
 
 func TestParseOMPIInfoOutputForVersion(t *testing.T) {
 	output := "Open MPI v3.0.4\n\nhttp://www.open-mpi.org/community/help/\n"
@@ -19,3 +25,77 @@ func TestParseOMPIInfoOutputForVersion(t *testing.T) {
 		t.Fatalf("parseOmpiInfoOutputForVersion() returned %s instead of %s", version, expectedResult)
 	}
 }
+
+func TestParseOmpiInfoConfigureOptions(t *testing.T) {
+	output := `           Configured architecture: x86_64-pc-linux-gnu
+            Configure command line: '--prefix=/opt/openmpi-4.1.5' '--with-ucx=/opt/ucx' 'CFLAGS=-O2 -g'
+                          Built by: builder
+`
+	options, err := parseOmpiInfoConfigureOptions(output)
+	if err != nil {
+		t.Fatalf("parseOmpiInfoConfigureOptions() failed: %s", err)
+	}
+	expected := "--prefix=/opt/openmpi-4.1.5|--with-ucx=/opt/ucx|CFLAGS=-O2 -g"
+	if strings.Join(options, "|") != expected {
+		t.Fatalf("parseOmpiInfoConfigureOptions() returned %s instead of %s", strings.Join(options, "|"), expected)
+	}
+
+	_, err = parseOmpiInfoConfigureOptions("Open MPI v4.1.5\n")
+	if err == nil {
+		t.Fatalf("parseOmpiInfoConfigureOptions() succeeded without configure command line")
+	}
+}
+
+func TestParseOmpiInfoParsable(t *testing.T) {
+	output := `package:Open MPI builder Distribution
+ompi:version:full:4.1.5
+compiler:c:command:gcc
+compiler:c:familyname:GNU
+compiler:c:version:12.2.0
+mca:pml:ucx:version:mca:2.1.0
+mca:mtl:ofi:version:mca:2.1.0
+mca:pmix:isolated:version:mca:2.1.0
+mca:pmix:ext3x:version:mca:2.1.0
+mca:mpi:base:param:mpi_built_with_cuda_support:value:false`
+	var info buildinfo.Info
+	parseOmpiInfoParsable(output, &info)
+	if info.Compiler != "GNU 12.2.0" {
+		t.Fatalf("parseOmpiInfoParsable() returned compiler %s instead of GNU 12.2.0", info.Compiler)
+	}
+	if strings.Join(info.Features(), " ") != "UCX libfabric PMIx" {
+		t.Fatalf("parseOmpiInfoParsable() returned features %s", strings.Join(info.Features(), " "))
+	}
+}
+
+func TestGetExtraMpirunArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		netCfg   *network.Config
+		expected string
+	}{
+		{
+			name:     "default",
+			netCfg:   &network.Config{Device: "mlx5_0:1"},
+			expected: "--mca btl ^openib --mca pml ucx -x UCX_NET_DEVICES=mlx5_0:1",
+		},
+		{
+			name:     "ofi",
+			netCfg:   &network.Config{Transport: network.OFITransport, Provider: "cxi"},
+			expected: "--mca pml cm --mca mtl ofi --mca mtl_ofi_provider_include cxi",
+		},
+		{
+			name:     "tcp",
+			netCfg:   &network.Config{Transport: network.TCPTransport, Device: "eth0"},
+			expected: "--mca pml ob1 --mca btl tcp,self,vader --mca btl_tcp_if_include eth0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := GetExtraMpirunArgs(nil, tt.netCfg, nil)
+			if strings.Join(args, " ") != tt.expected {
+				t.Fatalf("GetExtraMpirunArgs() returned %s instead of %s", strings.Join(args, " "), tt.expected)
+			}
+		})
+	}
+}
@@ -5,14 +5,212 @@
 
 package slurm
 
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/placement"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/rankoutput"
+)
+
 const (
 	// SlurmParitionKey is the key to use to retrieve the optinal parition id that
-	// can be specified in the tool's configuration file.
+	// can be specified in the tool's configuration file (alias of slurm.partition).
 	PartitionKey = "slurm_partition"
 
-	// EnabledKey is the key used in the singularity-mpi.conf file to specify if Slurm shall be used
+	// EnabledKey is the key used in the configuration file to specify if Slurm shall be used (alias of slurm.enabled)
 	EnabledKey = "enable_slurm"
 
 	// ScriptCmdPrefix is the prefix to add to a script
 	ScriptCmdPrefix = "#SBATCH"
+
+	// DefaultTimeLimit is the time limit of the batch scripts of jobs that do not specify one
+	DefaultTimeLimit = "0:30:0"
+
+	// Unlimited is the value used by Slurm for limits that are not set
+	Unlimited = "UNLIMITED"
+
+	// InfiniteTime is the duration used to represent time limits that Slurm reports as unlimited
+	InfiniteTime = time.Duration(math.MaxInt64)
 )
+
+// ParseTime converts a Slurm time specification (e.g., "30", "1:00:00" or "2-12:00:00") into a duration.
+// "UNLIMITED" and "INFINITE" are converted to InfiniteTime, "NONE" and "N/A" to 0.
+func ParseTime(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	switch strings.ToUpper(s) {
+	case Unlimited, "INFINITE":
+		return InfiniteTime, nil
+	case "", "NONE", "N/A":
+		return 0, nil
+	}
+
+	days := 0
+	clock := s
+	if idx := strings.Index(s, "-"); idx != -1 {
+		var err error
+		days, err = strconv.Atoi(s[:idx])
+		if err != nil {
+			return 0, fmt.Errorf("invalid number of days in %s: %w", s, err)
+		}
+		clock = s[idx+1:]
+	}
+
+	var fields []int
+	for _, f := range strings.Split(clock, ":") {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return 0, fmt.Errorf("invalid time format %s: %w", s, err)
+		}
+		fields = append(fields, n)
+	}
+	if len(fields) > 3 {
+		return 0, fmt.Errorf("invalid time format %s", s)
+	}
+
+	var h, m, sec int
+	if days > 0 || strings.Contains(s, "-") {
+		// days-hours[:minutes[:seconds]]
+		h = fields[0]
+		if len(fields) > 1 {
+			m = fields[1]
+		}
+		if len(fields) > 2 {
+			sec = fields[2]
+		}
+	} else {
+		// minutes, minutes:seconds or hours:minutes:seconds
+		switch len(fields) {
+		case 1:
+			m = fields[0]
+		case 2:
+			m, sec = fields[0], fields[1]
+		case 3:
+			h, m, sec = fields[0], fields[1], fields[2]
+		}
+	}
+
+	d := time.Duration(days)*24*time.Hour + time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec)*time.Second
+	return d, nil
+}
+
+// ParseCount converts a Slurm count (e.g., a maximum number of nodes) into an integer; "UNLIMITED" is converted to -1
+func ParseCount(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if strings.ToUpper(s) == Unlimited {
+		return -1, nil
+	}
+	if s == "" || s == "N/A" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// ParseKeyValues parses a single line of 'scontrol show -o' output, i.e., a list of KEY=VALUE pairs
+// separated by spaces. Values that include spaces (e.g., "Reason=Not responding") are preserved.
+func ParseKeyValues(line string) map[string]string {
+	kv := make(map[string]string)
+	lastKey := ""
+	for _, token := range strings.Fields(line) {
+		idx := strings.Index(token, "=")
+		if idx <= 0 {
+			if lastKey != "" {
+				kv[lastKey] += " " + token
+			}
+			continue
+		}
+		lastKey = token[:idx]
+		kv[lastKey] = token[idx+1:]
+	}
+	return kv
+}
+
+// ParseList converts a comma-separated list from Slurm into a slice, ignoring "(null)" values
+func ParseList(s string) []string {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "(null)" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// GetPlacementOptions returns the Slurm options requesting the resources needed by a placement spec, which can be
+// used both with sbatch and srun
+func GetPlacementOptions(s *placement.Spec) []string {
+	if s.IsEmpty() {
+		return nil
+	}
+
+	var opts []string
+	if s.RanksPerNode > 0 {
+		opts = append(opts, "--ntasks-per-node="+strconv.Itoa(s.RanksPerNode))
+	}
+	if s.RanksPerSocket > 0 {
+		opts = append(opts, "--ntasks-per-socket="+strconv.Itoa(s.RanksPerSocket))
+	}
+	if s.ThreadsPerRank > 0 {
+		opts = append(opts, "--cpus-per-task="+strconv.Itoa(s.ThreadsPerRank))
+	}
+	if s.Oversubscribe {
+		opts = append(opts, "--overcommit")
+	}
+	return opts
+}
+
+// cpuBindTypes maps binding units to the types of srun --cpu-bind
+var cpuBindTypes = map[placement.Unit]string{
+	placement.None:     "none",
+	placement.HWThread: "threads",
+	placement.Core:     "cores",
+	placement.Socket:   "sockets",
+	placement.NUMA:     "ldoms",
+}
+
+// GetPlacementArgs returns the srun arguments to distribute and bind tasks according to a placement spec
+func GetPlacementArgs(s *placement.Spec) []string {
+	args := GetPlacementOptions(s)
+	if s.IsEmpty() {
+		return args
+	}
+
+	if s.RanksPerNode > 0 || s.RanksPerSocket > 0 {
+		// Fill nodes, and sockets within nodes, before moving to the next one
+		args = append(args, "--distribution=block:block")
+	}
+	if t, ok := cpuBindTypes[s.BindTo]; ok {
+		args = append(args, "--cpu-bind="+t)
+	}
+	return args
+}
+
+// GetEnvArgs returns the srun arguments to set environment variables for all the tasks, either as KEY=VALUE or KEY to
+// pass the value of the environment of srun. The environment of srun is always propagated.
+func GetEnvArgs(env []string) []string {
+	var vars []string
+	for _, e := range env {
+		if strings.Contains(e, "=") {
+			vars = append(vars, e)
+		}
+	}
+	if len(vars) == 0 {
+		return nil
+	}
+	return []string{"--export=ALL," + strings.Join(vars, ",")}
+}
+
+// GetRankOutputArgs returns the srun arguments to label the output of each task or to write it to a directory
+func GetRankOutputArgs(mode string, dir string) []string {
+	switch mode {
+	case rankoutput.Label:
+		return []string{"--label"}
+	case rankoutput.Files:
+		return []string{
+			"--output=" + rankoutput.FilePattern(dir, "%t", rankoutput.StdoutSuffix),
+			"--error=" + rankoutput.FilePattern(dir, "%t", rankoutput.StderrSuffix),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package slurm
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/placement"
+)
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+	}{
+		{input: "30", expected: 30 * time.Minute},
+		{input: "30:15", expected: 30*time.Minute + 15*time.Second},
+		{input: "01:00:00", expected: time.Hour},
+		{input: "2-00:00:00", expected: 48 * time.Hour},
+		{input: "1-12", expected: 36 * time.Hour},
+		{input: "1-00:30", expected: 24*time.Hour + 30*time.Minute},
+		{input: "UNLIMITED", expected: InfiniteTime},
+		{input: "NONE", expected: 0},
+	}
+
+	for _, tt := range tests {
+		d, err := ParseTime(tt.input)
+		if err != nil {
+			t.Fatalf("ParseTime(%s) failed: %s", tt.input, err)
+		}
+		if d != tt.expected {
+			t.Fatalf("ParseTime(%s) returned %s instead of %s", tt.input, d, tt.expected)
+		}
+	}
+
+	_, err := ParseTime("1:2:3:4")
+	if err == nil {
+		t.Fatalf("ParseTime() succeeded with an invalid time")
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	line := "NodeName=nid001 Arch=x86_64 CPUTot=128 State=DOWN+DRAIN Reason=Not responding [slurm@2023-03-01T10:00:00] Partitions=batch,debug"
+	kv := ParseKeyValues(line)
+	if kv["NodeName"] != "nid001" {
+		t.Fatalf("invalid node name: %s", kv["NodeName"])
+	}
+	if kv["Reason"] != "Not responding [slurm@2023-03-01T10:00:00]" {
+		t.Fatalf("invalid reason: %s", kv["Reason"])
+	}
+	if kv["Partitions"] != "batch,debug" {
+		t.Fatalf("invalid partitions: %s", kv["Partitions"])
+	}
+}
+
+func TestGetPlacementArgs(t *testing.T) {
+	s := &placement.Spec{RanksPerNode: 4, RanksPerSocket: 2, ThreadsPerRank: 8, BindTo: placement.Core, Oversubscribe: true}
+	expected := "--ntasks-per-node=4 --ntasks-per-socket=2 --cpus-per-task=8 --overcommit --distribution=block:block --cpu-bind=cores"
+	args := strings.Join(GetPlacementArgs(s), " ")
+	if args != expected {
+		t.Fatalf("GetPlacementArgs() returned %s instead of %s", args, expected)
+	}
+	if len(GetPlacementArgs(nil)) != 0 {
+		t.Fatalf("GetPlacementArgs() returned arguments without placement")
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package testutil gathers the helpers shared by the tests of the other packages
+package testutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// WriteFile creates a file with a given content and permissions, as well as its parent directories, and returns its
+// path. The test fails if the file cannot be created.
+func WriteFile(t *testing.T, path string, content string, perm os.FileMode) string {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		t.Fatalf("unable to create %s: %s", filepath.Dir(path), err)
+	}
+	err = ioutil.WriteFile(path, []byte(content), perm)
+	if err != nil {
+		t.Fatalf("unable to create %s: %s", path, err)
+	}
+	return path
+}
+
+// WriteFiles creates files, given by their path relative to a directory, with their content (e.g., a fake
+// installation or sysfs tree)
+func WriteFiles(t *testing.T, dir string, files map[string]string, perm os.FileMode) {
+	for name, content := range files {
+		WriteFile(t, filepath.Join(dir, name), content, perm)
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package vendormpi detects vendor builds of MPI (e.g., HPE Cray MPICH) that are usually provided through
+// environment modules rather than installed with a mpirun command in their install directory.
+package vendormpi
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+const (
+	// CrayMPICHID is the internal ID for HPE Cray MPICH
+	CrayMPICHID = "cray-mpich"
+
+	// SpectrumMPIID is the internal ID for IBM Spectrum MPI
+	SpectrumMPIID = "spectrum-mpi"
+
+	// MPTID is the internal ID for HPE (SGI) MPT
+	MPTID = "hpe-mpt"
+)
+
+// Info gathers the details about a vendor MPI that was detected
+type Info struct {
+	// ID is the internal ID of the implementation
+	ID string
+
+	// Version is the version of the implementation
+	Version string
+
+	// InstallDir is the directory where the implementation is installed
+	InstallDir string
+
+	// LibDir is the directory where the libraries of the implementation are
+	LibDir string
+}
+
+// vendor describes how to detect a given vendor MPI
+type vendor struct {
+	// id is the internal ID of the implementation
+	id string
+
+	// rootEnvVars are the environment variables that may point to the install directory
+	rootEnvVars []string
+
+	// specificEnvVars are the root environment variables that are specific enough to identify the implementation
+	// without checking its libraries
+	specificEnvVars []string
+
+	// versionEnvVars are the environment variables that may give the version
+	versionEnvVars []string
+
+	// pkgConfigNames are the names of the pkg-config files of the implementation
+	pkgConfigNames []string
+
+	// libPatterns are the patterns of the libraries that identify the implementation
+	libPatterns []string
+
+	// versionPathRegexp extracts the version from the install directory
+	versionPathRegexp *regexp.Regexp
+
+	// launcher is the command used to start jobs
+	launcher string
+}
+
+var vendors = []vendor{
+	{
+		id:                CrayMPICHID,
+		rootEnvVars:       []string{"CRAY_MPICH_DIR", "CRAY_MPICH_PREFIX"},
+		specificEnvVars:   []string{"CRAY_MPICH_DIR", "CRAY_MPICH_PREFIX"},
+		versionEnvVars:    []string{"CRAY_MPICH_VERSION"},
+		pkgConfigNames:    []string{"cray-mpich"},
+		libPatterns:       []string{"libmpi_cray.so*", "libmpi_gnu_*.so*", "libmpi_intel.so*", "libmpi_nvidia.so*", "libmpi_amd.so*", "libmpi_aocc.so*"},
+		versionPathRegexp: regexp.MustCompile(`/mpich/(\d+(\.\d+)+)`),
+		launcher:          "srun",
+	},
+	{
+		id:                SpectrumMPIID,
+		rootEnvVars:       []string{"MPI_ROOT", "OMPI_DIR"},
+		pkgConfigNames:    []string{"ompi"},
+		libPatterns:       []string{"libmpi_ibm.so*"},
+		versionPathRegexp: regexp.MustCompile(`spectrum[-_]mpi[-_/](\d+(\.\d+)+)`),
+		launcher:          "mpirun",
+	},
+	{
+		id:                MPTID,
+		rootEnvVars:       []string{"MPI_ROOT", "MPT_DIR"},
+		versionEnvVars:    []string{"MPT_VERSION"},
+		pkgConfigNames:    []string{"mpt"},
+		libPatterns:       []string{"libmpi_mt.so*", "libmpi_lustre.so*"},
+		versionPathRegexp: regexp.MustCompile(`mpt-(\d+(\.\d+)+)`),
+		launcher:          "mpiexec_mpt",
+	},
+}
+
+// getEnv returns the value of an environment variable from a list of KEY=VALUE strings, or from the current
+// environment if the list is nil
+func getEnv(env []string, key string) string {
+	if env == nil {
+		return os.Getenv(key)
+	}
+	for _, e := range env {
+		if strings.HasPrefix(e, key+"=") {
+			return strings.TrimPrefix(e, key+"=")
+		}
+	}
+	return ""
+}
+
+// getLibDirs returns the library directories that exist in an install directory
+func getLibDirs(dir string) []string {
+	var dirs []string
+	for _, d := range []string{"lib64", "lib"} {
+		path := filepath.Join(dir, d)
+		if util.PathExists(path) {
+			dirs = append(dirs, path)
+		}
+	}
+	return dirs
+}
+
+// matchLibs checks whether a library directory includes one of the libraries identifying a vendor
+func matchLibs(libDir string, v *vendor) bool {
+	for _, pattern := range v.libPatterns {
+		matches, err := filepath.Glob(filepath.Join(libDir, pattern))
+		if err == nil && len(matches) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePkgConfig parses a pkg-config file and returns the version and the library directory it specifies
+func parsePkgConfig(content string) (string, string) {
+	vars := make(map[string]string)
+	version := ""
+	libDir := ""
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if idx := strings.Index(line, "="); idx > 0 && !strings.Contains(line[:idx], ":") {
+			key := strings.TrimSpace(line[:idx])
+			value := strings.TrimSpace(line[idx+1:])
+			// Expand variables defined earlier in the file, e.g., libdir=${prefix}/lib
+			for k, v := range vars {
+				value = strings.ReplaceAll(value, "${"+k+"}", v)
+			}
+			vars[key] = value
+			if key == "libdir" {
+				libDir = value
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "Version:") {
+			version = strings.TrimSpace(strings.TrimPrefix(line, "Version:"))
+		}
+	}
+	return version, libDir
+}
+
+// findPkgConfig looks for the pkg-config file of a vendor in the library directories of an install and, when the
+// install comes from the environment, in the directories of PKG_CONFIG_PATH. It returns the version and library
+// directory the file specifies.
+func findPkgConfig(libDirs []string, env []string, fromEnv bool, v *vendor) (string, string) {
+	var dirs []string
+	for _, d := range libDirs {
+		dirs = append(dirs, filepath.Join(d, "pkgconfig"))
+	}
+	if fromEnv {
+		for _, d := range strings.Split(getEnv(env, "PKG_CONFIG_PATH"), ":") {
+			if d != "" {
+				dirs = append(dirs, d)
+			}
+		}
+	}
+
+	for _, d := range dirs {
+		for _, name := range v.pkgConfigNames {
+			path := filepath.Join(d, name+".pc")
+			if !util.FileExists(path) {
+				continue
+			}
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			return parsePkgConfig(string(content))
+		}
+	}
+	return "", ""
+}
+
+// detect checks whether an install directory is a given vendor MPI. The environment is only used when the install
+// directory comes from it (fromEnv), otherwise the environment may describe another installation.
+func detect(dir string, env []string, fromEnv bool, v *vendor, identified bool) (*Info, error) {
+	if !util.PathExists(dir) {
+		return nil, fmt.Errorf("%s does not exist", dir)
+	}
+
+	libDirs := getLibDirs(dir)
+	libDir := ""
+	for _, d := range libDirs {
+		if matchLibs(d, v) {
+			libDir = d
+			identified = true
+			break
+		}
+	}
+	if !identified {
+		return nil, fmt.Errorf("%s is not a %s installation", dir, v.id)
+	}
+
+	info := new(Info)
+	info.ID = v.id
+	info.InstallDir = dir
+	pcVersion, pcLibDir := findPkgConfig(libDirs, env, fromEnv, v)
+	if fromEnv {
+		for _, e := range v.versionEnvVars {
+			if info.Version = getEnv(env, e); info.Version != "" {
+				break
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = pcVersion
+	}
+	if info.Version == "" && v.versionPathRegexp != nil {
+		m := v.versionPathRegexp.FindStringSubmatch(dir)
+		if len(m) > 1 {
+			info.Version = m[1]
+		}
+	}
+	if info.Version == "" {
+		return nil, fmt.Errorf("unable to find the version of %s in %s", v.id, dir)
+	}
+
+	switch {
+	case libDir != "":
+		info.LibDir = libDir
+	case pcLibDir != "":
+		info.LibDir = pcLibDir
+	case len(libDirs) > 0:
+		info.LibDir = libDirs[0]
+	}
+
+	return info, nil
+}
+
+// DetectFromEnv figures out if a vendor MPI is available based on the environment (e.g., the environment variables
+// set when loading the MPI's module). If env is nil, the current environment is used.
+func DetectFromEnv(env []string) (*Info, error) {
+	for idx := range vendors {
+		v := &vendors[idx]
+		for _, e := range v.rootEnvVars {
+			dir := getEnv(env, e)
+			if dir == "" {
+				continue
+			}
+			identified := false
+			for _, s := range v.specificEnvVars {
+				if s == e {
+					identified = true
+				}
+			}
+			info, err := detect(dir, env, true, v, identified)
+			if err == nil {
+				return info, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no vendor MPI found in the environment")
+}
+
+// DetectFromDir tries to figure out which vendor MPI is installed in a given directory based on its libraries. The
+// version only comes from the directory itself (pkg-config files, path), never from the environment.
+func DetectFromDir(dir string) (*Info, error) {
+	for idx := range vendors {
+		info, err := detect(dir, nil, false, &vendors[idx], false)
+		if err == nil {
+			return info, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a supported vendor MPI installation", dir)
+}
+
+// IsVendorMPI checks whether an ID is the ID of a vendor MPI
+func IsVendorMPI(id string) bool {
+	for _, v := range vendors {
+		if v.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// GetLauncher returns the name of the command used to start the jobs of a vendor MPI
+func GetLauncher(id string) string {
+	for _, v := range vendors {
+		if v.id == id {
+			return v.launcher
+		}
+	}
+	return ""
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package vendormpi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/testutil"
+)
+
+func TestParsePkgConfig(t *testing.T) {
+	content := `prefix=/opt/cray/pe/mpich/8.1.25/ofi/gnu/9.1
+libdir=${prefix}/lib
+includedir=${prefix}/include
+
+Name: cray-mpich
+Description: Cray MPICH
+Version: 8.1.25
+Libs: -L${libdir} -lmpi_gnu_91
+`
+	version, libDir := parsePkgConfig(content)
+	if version != "8.1.25" {
+		t.Fatalf("parsePkgConfig() returned version %s instead of 8.1.25", version)
+	}
+	if libDir != "/opt/cray/pe/mpich/8.1.25/ofi/gnu/9.1/lib" {
+		t.Fatalf("parsePkgConfig() returned invalid library directory: %s", libDir)
+	}
+}
+
+func TestDetectFromEnv(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "vendormpi")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Cray MPICH, version from the pkg-config file
+	crayDir := filepath.Join(tempDir, "opt", "cray", "pe", "mpich", "8.1.25", "ofi", "gnu", "9.1")
+	testutil.WriteFiles(t, crayDir, map[string]string{
+		"lib/libmpi_gnu_91.so.12":     "",
+		"lib/pkgconfig/cray-mpich.pc": "Name: cray-mpich\nVersion: 8.1.26\n",
+	}, 0644)
+	info, err := DetectFromEnv([]string{"CRAY_MPICH_DIR=" + crayDir, "PE_ENV=GNU"})
+	if err != nil {
+		t.Fatalf("DetectFromEnv() failed: %s", err)
+	}
+	if info.ID != CrayMPICHID || info.Version != "8.1.26" || info.LibDir != filepath.Join(crayDir, "lib") {
+		t.Fatalf("DetectFromEnv() returned invalid data: %+v", info)
+	}
+	if GetLauncher(info.ID) != "srun" {
+		t.Fatalf("invalid launcher for Cray MPICH: %s", GetLauncher(info.ID))
+	}
+
+	// HPE MPT, version from the path
+	mptDir := filepath.Join(tempDir, "opt", "hpe", "hpc", "mpt", "mpt-2.25")
+	testutil.WriteFiles(t, mptDir, map[string]string{
+		"lib/libmpi.so":    "",
+		"lib/libmpi_mt.so": "",
+	}, 0644)
+	info, err = DetectFromEnv([]string{"MPI_ROOT=" + mptDir})
+	if err != nil {
+		t.Fatalf("DetectFromEnv() failed: %s", err)
+	}
+	if info.ID != MPTID || info.Version != "2.25" {
+		t.Fatalf("DetectFromEnv() returned invalid data: %+v", info)
+	}
+
+	// MPI_ROOT pointing to an installation that is not a vendor MPI
+	otherDir := filepath.Join(tempDir, "openmpi")
+	testutil.WriteFiles(t, otherDir, map[string]string{"lib/libmpi.so.40": ""}, 0644)
+	_, err = DetectFromEnv([]string{"MPI_ROOT=" + otherDir})
+	if err == nil {
+		t.Fatalf("DetectFromEnv() succeeded with a non-vendor MPI")
+	}
+
+	// Spectrum MPI detected from its directory
+	spectrumDir := filepath.Join(tempDir, "opt", "ibm", "spectrum_mpi-10.4.0.3")
+	testutil.WriteFiles(t, spectrumDir, map[string]string{"lib/libmpi_ibm.so.3": ""}, 0644)
+	info, err = DetectFromDir(spectrumDir)
+	if err != nil {
+		t.Fatalf("DetectFromDir() failed: %s", err)
+	}
+	if info.ID != SpectrumMPIID || info.Version != "10.4.0.3" {
+		t.Fatalf("DetectFromDir() returned invalid data: %+v", info)
+	}
+}
+
+func TestDetectFromDirIgnoresEnv(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "vendormpi")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// The environment describes the Cray MPICH of the loaded module, not the one of the directory
+	pcDir := filepath.Join(tempDir, "pkgconfig")
+	testutil.WriteFiles(t, pcDir, map[string]string{"cray-mpich.pc": "Name: cray-mpich\nVersion: 8.1.28\n"}, 0644)
+	for key, value := range map[string]string{"CRAY_MPICH_VERSION": "8.1.28", "PKG_CONFIG_PATH": pcDir} {
+		prev, set := os.LookupEnv(key)
+		os.Setenv(key, value)
+		if set {
+			defer os.Setenv(key, prev)
+		} else {
+			defer os.Unsetenv(key)
+		}
+	}
+
+	// The generic MPICH pkg-config file does not describe Cray MPICH
+	crayDir := filepath.Join(tempDir, "opt", "cray", "pe", "mpich", "8.1.25", "ofi", "gnu", "9.1")
+	testutil.WriteFiles(t, crayDir, map[string]string{
+		"lib/libmpi_gnu_91.so.12": "",
+		"lib/pkgconfig/mpich.pc":  "Name: mpich\nVersion: 3.4a2\n",
+	}, 0644)
+	info, err := DetectFromDir(crayDir)
+	if err != nil {
+		t.Fatalf("DetectFromDir() failed: %s", err)
+	}
+	if info.ID != CrayMPICHID || info.Version != "8.1.25" {
+		t.Fatalf("DetectFromDir() returned invalid data: %+v", info)
+	}
+}
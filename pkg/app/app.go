@@ -5,6 +5,8 @@
 
 package app
 
+import "github.com/BTMichalowicz/go_hpc_jobmgr/pkg/expect"
+
 // Info gathers information about a given application
 type Info struct {
 	// Name is the name of the application
@@ -18,4 +20,11 @@ type Info struct {
 
 	// BinArgs is the list of argument that the application's binary needs
 	BinArgs []string
+
+	// SpackHash is the hash of the Spack installation of the application, if installed with Spack (optional)
+	SpackHash string
+
+	// Expectations is the list of expectations that the output of all the jobs running the application must meet
+	// (optional)
+	Expectations []expect.Expectation
 }
@@ -0,0 +1,416 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package artifact gathers the files a job produces, with a description of the job, in a directory dedicated to the
+// job
+package artifact
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// MetadataFile is the name of the file describing the job in its artifact directory
+	MetadataFile = "metadata.json"
+
+	// StdoutFile is the name of the file with the output of a job that the job manager does not save itself
+	StdoutFile = "stdout.txt"
+
+	// StderrFile is the name of the file with stderr of a job that the job manager does not save itself
+	StderrFile = "stderr.txt"
+
+	// BundleExt is the extension of the archives bundling the artifacts of jobs
+	BundleExt = ".tar.gz"
+)
+
+// Spec specifies the artifacts of a job
+type Spec struct {
+	// Dir is the directory where the artifacts of the job are gathered (optional, a new directory in BaseDir by
+	// default). The directory must be dedicated to the job.
+	Dir string
+
+	// BaseDir is the directory where the artifact directories of jobs are created (optional, the scratch directory
+	// of the system by default)
+	BaseDir string
+
+	// Patterns is a list of glob patterns matching the files the job produces, relative to the directory the job
+	// runs from (e.g., *.csv, results/*.log)
+	Patterns []string
+
+	// Move specifies whether the files the job produces are moved, rather than copied, to the artifact directory
+	Move bool
+
+	// Bundle specifies whether the artifacts are archived in a tar.gz file next to the artifact directory
+	Bundle bool
+}
+
+// MPIMetadata describes the MPI implementation a job ran with
+type MPIMetadata struct {
+	// ID is the identifier of the MPI implementation
+	ID string
+
+	// Version is the version of the MPI implementation
+	Version string
+
+	// InstallDir is the directory where the MPI implementation is installed
+	InstallDir string
+
+	// Launcher is the name of the launcher that started the ranks (e.g., mpirun, srun)
+	Launcher string
+}
+
+// AttemptMetadata describes an attempt at running a job
+type AttemptMetadata struct {
+	// Number is the number of the attempt, starting at 1
+	Number int
+
+	// Start is when the attempt was submitted
+	Start time.Time
+
+	// End is when the attempt completed
+	End time.Time
+
+	// State is the final state of the attempt (e.g., COMPLETED, FAILED, NODE_FAIL)
+	State string
+
+	// ExitCode is the exit code of the attempt
+	ExitCode int
+
+	// Error is the error returned by the job manager, if any
+	Error string
+}
+
+// Metadata describes a job and its execution
+type Metadata struct {
+	// Name is the name of the job
+	Name string
+
+	// ID is the identifier of the job given by the job manager (e.g., Slurm job ID), 0 when unknown
+	ID int
+
+	// JobManager is the identifier of the job manager that ran the job
+	JobManager string
+
+	// NP is the number of ranks
+	NP int
+
+	// NNodes is the number of nodes
+	NNodes int
+
+	// Partition is the partition the job ran on
+	Partition string
+
+	// Account is the account the job was charged to
+	Account string
+
+	// App is the path to the binary of the application
+	App string
+
+	// AppArgs is the list of arguments of the application
+	AppArgs []string
+
+	// Command is the command that ran the job (e.g., mpirun and its arguments, sbatch and the batch script)
+	Command []string
+
+	// BatchScript is the name of the batch script in the artifact directory, if the job has one
+	BatchScript string
+
+	// RunDir is the directory the job ran from
+	RunDir string
+
+	// Env is the environment of the job, as KEY=VALUE or KEY for inherited variables
+	Env []string
+
+	// MPI describes the MPI implementation of the job, if any
+	MPI *MPIMetadata
+
+	// LoadedModules is the list of modules loaded to run the job
+	LoadedModules []string
+
+	// Container is the image of the container the ranks ran in, if any
+	Container string
+
+	// Start is when the job was first submitted
+	Start time.Time
+
+	// End is when the last attempt at running the job completed
+	End time.Time
+
+	// Duration is the time in seconds between Start and End
+	Duration float64
+
+	// State is the final state of the job (e.g., COMPLETED, FAILED), unknown for non-blocking jobs
+	State string
+
+	// ExitCode is the exit code of the job
+	ExitCode int
+
+	// Error is the error returned by the job manager, if any
+	Error string
+
+	// Attempts is the history of the attempts at running the job
+	Attempts []AttemptMetadata
+
+	// Files is the list of files in the artifact directory, relative to it
+	Files []string
+}
+
+// CreateDir creates the artifact directory of a job and returns its absolute path. name is used as the prefix of
+// the directory when the spec does not give the directory.
+func CreateDir(s *Spec, defaultBaseDir string, name string) (string, error) {
+	dir := s.Dir
+	if dir == "" {
+		baseDir := s.BaseDir
+		if baseDir == "" {
+			baseDir = defaultBaseDir
+		}
+		if baseDir == "" {
+			return "", fmt.Errorf("undefined base directory for the artifacts")
+		}
+		err := os.MkdirAll(baseDir, 0755)
+		if err != nil {
+			return "", fmt.Errorf("unable to create %s: %w", baseDir, err)
+		}
+		if name == "" {
+			name = "job"
+		}
+		dir, err = ioutil.TempDir(baseDir, name+"-")
+		if err != nil {
+			return "", fmt.Errorf("unable to create the artifact directory: %w", err)
+		}
+	} else {
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			return "", fmt.Errorf("unable to create %s: %w", dir, err)
+		}
+	}
+	return filepath.Abs(dir)
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// moveFile moves a file, copying it when it is on a different file system
+func moveFile(src string, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	err = copyFile(src, dst)
+	if err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// Collect copies, or moves, the files matching the patterns of a spec from the directory a job ran from to its
+// artifact directory. The files keep their path relative to runDir. It returns the paths of the collected files,
+// relative to the artifact directory.
+func Collect(s *Spec, runDir string, dir string) ([]string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var collected []string
+	seen := make(map[string]bool)
+	for _, pattern := range s.Patterns {
+		if filepath.IsAbs(pattern) {
+			return nil, fmt.Errorf("%s is not relative to the directory of the job", pattern)
+		}
+		matches, err := filepath.Glob(filepath.Join(runDir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+		}
+		for _, m := range matches {
+			rel, err := filepath.Rel(runDir, m)
+			if err != nil || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || seen[rel] {
+				continue
+			}
+			absMatch, err := filepath.Abs(m)
+			if err != nil {
+				return nil, err
+			}
+			info, err := os.Stat(m)
+			// Directories are collected with patterns matching their content, and the artifact directory may
+			// be within the directory of the job
+			if err != nil || info.IsDir() || strings.HasPrefix(absMatch, absDir+string(filepath.Separator)) {
+				continue
+			}
+
+			dst := filepath.Join(dir, rel)
+			err = os.MkdirAll(filepath.Dir(dst), 0755)
+			if err != nil {
+				return nil, fmt.Errorf("unable to create %s: %w", filepath.Dir(dst), err)
+			}
+			if s.Move {
+				err = moveFile(m, dst)
+			} else {
+				err = copyFile(m, dst)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("unable to collect %s: %w", m, err)
+			}
+			seen[rel] = true
+			collected = append(collected, rel)
+		}
+	}
+	sort.Strings(collected)
+	return collected, nil
+}
+
+// AddFile copies a file to the artifact directory, e.g., the batch script of a job, and returns its name
+func AddFile(path string, dir string) (string, error) {
+	name := filepath.Base(path)
+	err := copyFile(path, filepath.Join(dir, name))
+	if err != nil {
+		return "", fmt.Errorf("unable to copy %s: %w", path, err)
+	}
+	return name, nil
+}
+
+// Files returns the list of files in an artifact directory, relative to it, except the metadata
+func Files(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel != MetadataFile {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the files of %s: %w", dir, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// WriteMetadata saves the description of a job in its artifact directory
+func WriteMetadata(dir string, m *Metadata) error {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode the metadata: %w", err)
+	}
+	path := filepath.Join(dir, MetadataFile)
+	err = ioutil.WriteFile(path, append(content, '\n'), 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// ReadMetadata loads the description of a job from its artifact directory
+func ReadMetadata(dir string) (*Metadata, error) {
+	path := filepath.Join(dir, MetadataFile)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	m := new(Metadata)
+	err = json.Unmarshal(content, m)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+	return m, nil
+}
+
+func addToArchive(tw *tar.Writer, path string, name string, info os.FileInfo) error {
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = filepath.ToSlash(name)
+	if info.IsDir() {
+		hdr.Name += "/"
+	}
+	err = tw.WriteHeader(hdr)
+	if err != nil || !info.Mode().IsRegular() {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// Bundle archives an artifact directory in a tar.gz file next to it and returns the path to the archive. The
+// content of the archive is within a directory named after the artifact directory.
+func Bundle(dir string) (string, error) {
+	dir = filepath.Clean(dir)
+	archivePath := dir + BundleExt
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("unable to create %s: %w", archivePath, err)
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	root := filepath.Base(dir)
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		return addToArchive(tw, path, filepath.Join(root, rel), info)
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to archive %s: %w", dir, err)
+	}
+	err = tw.Close()
+	if err != nil {
+		return "", fmt.Errorf("unable to write %s: %w", archivePath, err)
+	}
+	err = gw.Close()
+	if err != nil {
+		return "", fmt.Errorf("unable to write %s: %w", archivePath, err)
+	}
+	return archivePath, f.Close()
+}
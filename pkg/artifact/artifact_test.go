@@ -0,0 +1,151 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package artifact
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/testutil"
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+func TestCollect(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	runDir := filepath.Join(tempDir, "run")
+	testutil.WriteFiles(t, runDir, map[string]string{"a.csv": "a.csv", "b.csv": "b.csv", "notes.txt": "notes.txt", "results/rank0.log": "results/rank0.log", "results/rank1.log": "results/rank1.log"}, 0644)
+
+	tests := []struct {
+		name     string
+		spec     Spec
+		expected []string
+		remains  []string
+	}{
+		{
+			name:     "copy",
+			spec:     Spec{Patterns: []string{"*.csv", "results/*.log", "*.csv", "missing*"}},
+			expected: []string{"a.csv", "b.csv", "results/rank0.log", "results/rank1.log"},
+			remains:  []string{"a.csv", "results/rank0.log"},
+		},
+		{
+			name:     "move",
+			spec:     Spec{Patterns: []string{"notes.txt"}, Move: true},
+			expected: []string{"notes.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		dir, err := CreateDir(&tt.spec, filepath.Join(tempDir, "artifacts"), "bench")
+		if err != nil {
+			t.Fatalf("%s: CreateDir() failed: %s", tt.name, err)
+		}
+		if !filepath.IsAbs(dir) || !strings.HasPrefix(filepath.Base(dir), "bench-") {
+			t.Fatalf("%s: CreateDir() returned %s", tt.name, dir)
+		}
+
+		files, err := Collect(&tt.spec, runDir, dir)
+		if err != nil {
+			t.Fatalf("%s: Collect() failed: %s", tt.name, err)
+		}
+		if strings.Join(files, " ") != strings.Join(tt.expected, " ") {
+			t.Fatalf("%s: Collect() returned %v instead of %v", tt.name, files, tt.expected)
+		}
+		for _, f := range files {
+			content, err := ioutil.ReadFile(filepath.Join(dir, f))
+			if err != nil || string(content) != f {
+				t.Fatalf("%s: %s was not collected", tt.name, f)
+			}
+			if util.FileExists(filepath.Join(runDir, f)) == tt.spec.Move {
+				t.Fatalf("%s: %s was not copied or moved as expected", tt.name, f)
+			}
+		}
+	}
+
+	_, err = Collect(&Spec{Patterns: []string{"/etc/*"}}, runDir, tempDir)
+	if err == nil {
+		t.Fatalf("Collect() succeeded with an absolute pattern")
+	}
+}
+
+func TestMetadataAndBundle(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	dir := filepath.Join(tempDir, "bench-1")
+	testutil.WriteFiles(t, dir, map[string]string{StdoutFile: StdoutFile, "results/rank0.log": "results/rank0.log"}, 0644)
+
+	files, err := Files(dir)
+	if err != nil {
+		t.Fatalf("Files() failed: %s", err)
+	}
+	m := &Metadata{
+		Name:    "bench",
+		NP:      4,
+		Command: []string{"mpirun", "-np", "4", "bench"},
+		MPI:     &MPIMetadata{ID: "openmpi", Version: "4.1.5"},
+		Files:   files,
+	}
+	err = WriteMetadata(dir, m)
+	if err != nil {
+		t.Fatalf("WriteMetadata() failed: %s", err)
+	}
+	loaded, err := ReadMetadata(dir)
+	if err != nil {
+		t.Fatalf("ReadMetadata() failed: %s", err)
+	}
+	if loaded.Name != m.Name || loaded.MPI == nil || loaded.MPI.Version != "4.1.5" || strings.Join(loaded.Files, " ") != "results/rank0.log stdout.txt" {
+		t.Fatalf("ReadMetadata() returned %+v", loaded)
+	}
+
+	archive, err := Bundle(dir)
+	if err != nil {
+		t.Fatalf("Bundle() failed: %s", err)
+	}
+	if archive != dir+BundleExt {
+		t.Fatalf("Bundle() created %s instead of %s", archive, dir+BundleExt)
+	}
+	f, err := os.Open(archive)
+	if err != nil {
+		t.Fatalf("unable to open %s: %s", archive, err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("invalid archive: %s", err)
+	}
+	tr := tar.NewReader(gr)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid archive: %s", err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			names = append(names, hdr.Name)
+		}
+	}
+	sort.Strings(names)
+	expected := "bench-1/metadata.json bench-1/results/rank0.log bench-1/stdout.txt"
+	if strings.Join(names, " ") != expected {
+		t.Fatalf("the archive includes %v instead of %s", names, expected)
+	}
+}
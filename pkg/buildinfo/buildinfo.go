@@ -0,0 +1,143 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package buildinfo gathers the details about how a MPI implementation was built
+package buildinfo
+
+import "strings"
+
+// SplitConfigureFlags splits a configure command line into the list of flags it includes. Flags are either
+// separated by spaces or quoted with single quotes, as reported by most MPI implementations
+// (e.g., '--prefix=/opt/mpi' 'CFLAGS=-O2 -g').
+func SplitConfigureFlags(s string) []string {
+	var flags []string
+	var current strings.Builder
+	inQuotes := false
+	hasToken := false
+	for _, c := range s {
+		switch {
+		case c == '\'':
+			inQuotes = !inQuotes
+			hasToken = true
+		case !inQuotes && (c == ' ' || c == '\t' || c == '\n'):
+			if hasToken {
+				flags = append(flags, current.String())
+				current.Reset()
+				hasToken = false
+			}
+		default:
+			current.WriteRune(c)
+			hasToken = true
+		}
+	}
+	if hasToken {
+		flags = append(flags, current.String())
+	}
+	return flags
+}
+
+// Info is a structured description of how a MPI implementation was built
+type Info struct {
+	// Compiler is the C compiler used to build the implementation (e.g., gcc or GNU 12.2.0)
+	Compiler string
+
+	// ConfigureFlags is the list of flags given to configure
+	ConfigureFlags []string
+
+	// Device is the device of MPICH-derived implementations (e.g., ch4:ofi, ch3:mrail)
+	Device string
+
+	// Netmod is the network module of MPICH-derived implementations (e.g., ofi, ucx)
+	Netmod string
+
+	// UCX specifies whether the implementation supports UCX
+	UCX bool
+
+	// Libfabric specifies whether the implementation supports libfabric (OFI)
+	Libfabric bool
+
+	// PMIx specifies whether the implementation supports PMIx
+	PMIx bool
+
+	// CUDA specifies whether the implementation is CUDA-aware
+	CUDA bool
+}
+
+// flagEnabled checks whether a configure flag enables an option, i.e., it is not --without-X or --X=no
+func flagEnabled(flag string) bool {
+	if strings.HasPrefix(flag, "--without-") || strings.HasPrefix(flag, "--disable-") {
+		return false
+	}
+	return !strings.HasSuffix(flag, "=no")
+}
+
+// SetDevice sets the device and the network module from a device specification (e.g., ch4:ofi or ch3:nemesis:tcp)
+func (i *Info) SetDevice(device string) {
+	i.Device = device
+	tokens := strings.Split(device, ":")
+	if len(tokens) > 1 {
+		i.Netmod = tokens[len(tokens)-1]
+	}
+	switch i.Netmod {
+	case "ucx":
+		i.UCX = true
+	case "ofi":
+		i.Libfabric = true
+	}
+}
+
+// AddConfigureFlags adds flags given to configure to the description and sets the features they enable
+func (i *Info) AddConfigureFlags(flags []string) {
+	i.ConfigureFlags = append(i.ConfigureFlags, flags...)
+	for _, f := range flags {
+		name := f
+		value := ""
+		if idx := strings.Index(f, "="); idx != -1 {
+			name = f[:idx]
+			value = f[idx+1:]
+		}
+		if !flagEnabled(f) {
+			continue
+		}
+		switch name {
+		case "--with-ucx":
+			i.UCX = true
+		case "--with-libfabric", "--with-ofi":
+			i.Libfabric = true
+		case "--with-pmix":
+			i.PMIx = true
+		case "--with-pmi":
+			if strings.HasPrefix(value, "pmix") {
+				i.PMIx = true
+			}
+		case "--with-cuda", "--enable-cuda":
+			i.CUDA = true
+		case "--with-device":
+			i.SetDevice(value)
+		case "CC":
+			if i.Compiler == "" {
+				i.Compiler = value
+			}
+		}
+	}
+}
+
+// Features returns the list of the features supported by the implementation (e.g., UCX, PMIx)
+func (i *Info) Features() []string {
+	var features []string
+	if i.UCX {
+		features = append(features, "UCX")
+	}
+	if i.Libfabric {
+		features = append(features, "libfabric")
+	}
+	if i.PMIx {
+		features = append(features, "PMIx")
+	}
+	if i.CUDA {
+		features = append(features, "CUDA")
+	}
+	return features
+}
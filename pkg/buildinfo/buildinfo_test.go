@@ -0,0 +1,56 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package buildinfo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitConfigureFlags(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			input:    "'--prefix=/opt/mpi' 'FCFLAGS=-fallow-argument-mismatch -O2' 'LIBS='",
+			expected: []string{"--prefix=/opt/mpi", "FCFLAGS=-fallow-argument-mismatch -O2", "LIBS="},
+		},
+		{
+			input:    "  --prefix=/opt/mv2 --with-device=ch3:mrail\t--with-rdma=gen2 ",
+			expected: []string{"--prefix=/opt/mv2", "--with-device=ch3:mrail", "--with-rdma=gen2"},
+		},
+		{
+			input:    "",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		flags := SplitConfigureFlags(tt.input)
+		if strings.Join(flags, "|") != strings.Join(tt.expected, "|") || len(flags) != len(tt.expected) {
+			t.Fatalf("SplitConfigureFlags(%q) returned %q instead of %q", tt.input, flags, tt.expected)
+		}
+	}
+}
+
+func TestAddConfigureFlags(t *testing.T) {
+	var i Info
+	i.AddConfigureFlags([]string{"--prefix=/opt/mpich", "--with-device=ch4:ofi", "--with-pmix=/opt/pmix", "--without-cuda", "--with-ucx=no", "CC=gcc"})
+	if i.Device != "ch4:ofi" || i.Netmod != "ofi" {
+		t.Fatalf("invalid device %s and netmod %s", i.Device, i.Netmod)
+	}
+	if i.Compiler != "gcc" {
+		t.Fatalf("invalid compiler %s", i.Compiler)
+	}
+	features := strings.Join(i.Features(), " ")
+	if features != "libfabric PMIx" {
+		t.Fatalf("invalid features %s", features)
+	}
+	if len(i.ConfigureFlags) != 6 {
+		t.Fatalf("%d configure flags instead of 6", len(i.ConfigureFlags))
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package container describes how the ranks of a job run within a container
+package container
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// Apptainer is the Apptainer runtime
+	Apptainer = "apptainer"
+
+	// Singularity is the Singularity runtime
+	Singularity = "singularity"
+
+	// PodmanHPC is the podman-hpc runtime
+	PodmanHPC = "podman-hpc"
+
+	// HybridModel runs an application with the MPI of the container, which must be compatible with the MPI of the
+	// host starting the ranks
+	HybridModel = "hybrid"
+
+	// BindModel runs an application with the MPI of the host, mounted in the container
+	BindModel = "bind"
+)
+
+// Spec describes the container in which the ranks of a job run
+type Spec struct {
+	// Image is the path to the image (e.g., a SIF file) or the name of the image (podman-hpc)
+	Image string
+
+	// Runtime is the container runtime (optional, Apptainer by default)
+	Runtime string
+
+	// Binds is the list of directories to mount in the container, as src[:dst[:options]] (optional)
+	Binds []string
+
+	// Env is the list of environment variables to set in the container, either as KEY=VALUE or KEY to pass the
+	// value from the host (optional)
+	Env []string
+
+	// Model is the way the application gets its MPI (optional, HybridModel by default)
+	Model string
+
+	// ExtraArgs is a list of arguments to pass to the runtime (optional)
+	ExtraArgs []string
+}
+
+// runtime returns the runtime of a spec, using the default runtime if none is specified
+func (s *Spec) runtime() string {
+	if s.Runtime == "" {
+		return Apptainer
+	}
+	return s.Runtime
+}
+
+// model returns the MPI model of a spec, using the default model if none is specified
+func (s *Spec) model() string {
+	if s.Model == "" {
+		return HybridModel
+	}
+	return s.Model
+}
+
+// Validate checks that a container spec is valid and that its runtime is available
+func (s *Spec) Validate() error {
+	if s.Image == "" {
+		return fmt.Errorf("undefined container image")
+	}
+	switch s.runtime() {
+	case Apptainer, Singularity, PodmanHPC:
+	default:
+		return fmt.Errorf("unsupported container runtime %s", s.Runtime)
+	}
+	switch s.model() {
+	case HybridModel, BindModel:
+	default:
+		return fmt.Errorf("unsupported MPI model %s", s.Model)
+	}
+	for _, b := range s.Binds {
+		if b == "" || strings.HasPrefix(b, ":") {
+			return fmt.Errorf("invalid bind %s", b)
+		}
+	}
+	if _, err := exec.LookPath(s.runtime()); err != nil {
+		return fmt.Errorf("container runtime %s not found", s.runtime())
+	}
+	return nil
+}
+
+// IsBindModel checks whether the application runs with the MPI of the host
+func (s *Spec) IsBindModel() bool {
+	return s != nil && s.model() == BindModel
+}
+
+// GetArgs returns the command running an application within the container. In bind mode, mpiDir and libDir are the
+// install and library directories of the MPI of the host, which are mounted at the same location in the container.
+func (s *Spec) GetArgs(mpiDir string, libDir string, bin string, args []string) ([]string, error) {
+	binds := append([]string{}, s.Binds...)
+	env := append([]string{}, s.Env...)
+	if s.IsBindModel() {
+		if mpiDir == "" {
+			return nil, fmt.Errorf("bind mode requires a MPI on the host")
+		}
+		if libDir == "" {
+			libDir = filepath.Join(mpiDir, "lib")
+		}
+		binds = append(binds, mpiDir+":"+mpiDir)
+		if !strings.HasPrefix(libDir, mpiDir+"/") {
+			binds = append(binds, libDir+":"+libDir)
+		}
+		env = append(env, "LD_LIBRARY_PATH="+libDir)
+	}
+
+	var cmd []string
+	switch s.runtime() {
+	case Apptainer, Singularity:
+		cmd = append(cmd, s.runtime(), "exec")
+		if len(binds) > 0 {
+			cmd = append(cmd, "--bind", strings.Join(binds, ","))
+		}
+		for _, e := range env {
+			// The environment of the host is passed to the container by default
+			if strings.Contains(e, "=") {
+				cmd = append(cmd, "--env", e)
+			}
+		}
+	case PodmanHPC:
+		cmd = append(cmd, PodmanHPC, "run", "--rm")
+		for _, b := range binds {
+			// podman requires a destination
+			if !strings.Contains(b, ":") {
+				b += ":" + b
+			}
+			cmd = append(cmd, "-v", b)
+		}
+		for _, e := range env {
+			cmd = append(cmd, "-e", e)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported container runtime %s", s.Runtime)
+	}
+	cmd = append(cmd, s.ExtraArgs...)
+	cmd = append(cmd, s.Image, bin)
+	return append(cmd, args...), nil
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        Spec
+		mpiDir      string
+		libDir      string
+		expected    string
+		expectedErr bool
+	}{
+		{
+			name:     "apptainerHybrid",
+			spec:     Spec{Image: "/images/app.sif", Binds: []string{"/scratch", "/data:/input:ro"}, Env: []string{"OMP_NUM_THREADS=2", "HOME"}},
+			expected: "apptainer exec --bind /scratch,/data:/input:ro --env OMP_NUM_THREADS=2 /images/app.sif /app/bin/app -n 10",
+		},
+		{
+			name:     "singularityBind",
+			spec:     Spec{Image: "/images/app.sif", Runtime: Singularity, Model: BindModel},
+			mpiDir:   "/opt/openmpi",
+			expected: "singularity exec --bind /opt/openmpi:/opt/openmpi --env LD_LIBRARY_PATH=/opt/openmpi/lib /images/app.sif /app/bin/app -n 10",
+		},
+		{
+			name:     "podmanBind",
+			spec:     Spec{Image: "app:latest", Runtime: PodmanHPC, Model: BindModel, Binds: []string{"/scratch"}, Env: []string{"HOME"}},
+			mpiDir:   "/opt/mpich",
+			libDir:   "/usr/lib64/mpich",
+			expected: "podman-hpc run --rm -v /scratch:/scratch -v /opt/mpich:/opt/mpich -v /usr/lib64/mpich:/usr/lib64/mpich -e HOME -e LD_LIBRARY_PATH=/usr/lib64/mpich app:latest /app/bin/app -n 10",
+		},
+		{
+			name:        "bindWithoutMPI",
+			spec:        Spec{Image: "/images/app.sif", Model: BindModel},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := tt.spec.GetArgs(tt.mpiDir, tt.libDir, "/app/bin/app", []string{"-n", "10"})
+			if tt.expectedErr {
+				if err == nil {
+					t.Fatalf("GetArgs() succeeded but was expected to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetArgs() failed: %s", err)
+			}
+			if strings.Join(args, " ") != tt.expected {
+				t.Fatalf("GetArgs() returned %s instead of %s", strings.Join(args, " "), tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "container-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, Apptainer), []byte("#!/bin/sh\n"), 0755)
+	if err != nil {
+		t.Fatalf("unable to create fake runtime: %s", err)
+	}
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", dir)
+
+	tests := []struct {
+		name        string
+		spec        Spec
+		expectedErr bool
+	}{
+		{
+			name: "valid",
+			spec: Spec{Image: "/images/app.sif", Binds: []string{"/scratch"}},
+		},
+		{
+			name:        "noImage",
+			spec:        Spec{},
+			expectedErr: true,
+		},
+		{
+			name:        "unknownRuntime",
+			spec:        Spec{Image: "/images/app.sif", Runtime: "docker"},
+			expectedErr: true,
+		},
+		{
+			name:        "missingRuntime",
+			spec:        Spec{Image: "app:latest", Runtime: PodmanHPC},
+			expectedErr: true,
+		},
+		{
+			name:        "unknownModel",
+			spec:        Spec{Image: "/images/app.sif", Model: "inject"},
+			expectedErr: true,
+		},
+		{
+			name:        "invalidBind",
+			spec:        Spec{Image: "/images/app.sif", Binds: []string{":/data"}},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.spec.Validate()
+			if tt.expectedErr && err == nil {
+				t.Fatalf("Validate() succeeded but was expected to fail")
+			}
+			if !tt.expectedErr && err != nil {
+				t.Fatalf("Validate() failed: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package environ describes the environment of a job: an ordered list of variables that are either set or inherited
+// from the environment the job is submitted from
+package environ
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Var is an environment variable of a job
+type Var struct {
+	// Name is the name of the variable
+	Name string
+
+	// Value is the value of the variable, ignored when Inherit is set
+	Value string
+
+	// Inherit specifies whether the value is the one from the environment the job is submitted from
+	Inherit bool
+}
+
+// Env is the environment of a job
+type Env struct {
+	// Clear specifies whether the job starts from an empty environment rather than from the environment it is
+	// submitted from; only the inherited variables are then passed to the job
+	Clear bool
+
+	// Vars is the ordered list of variables of the environment
+	Vars []Var
+}
+
+// isValidName checks whether a string is a valid name for an environment variable
+func isValidName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// index returns the position of a variable, -1 if the environment does not have it
+func (e *Env) index(name string) int {
+	for idx, v := range e.Vars {
+		if v.Name == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+func (e *Env) add(v Var) {
+	if idx := e.index(v.Name); idx != -1 {
+		e.Vars[idx] = v
+		return
+	}
+	e.Vars = append(e.Vars, v)
+}
+
+// Set sets a variable. A variable that is already set keeps its position.
+func (e *Env) Set(name string, value string) {
+	e.add(Var{Name: name, Value: value})
+}
+
+// Inherit passes a variable from the environment the job is submitted from
+func (e *Env) Inherit(name string) {
+	e.add(Var{Name: name, Inherit: true})
+}
+
+// Merge adds the variables of another environment, which take precedence. The result starts from an empty
+// environment if either does.
+func (e *Env) Merge(other *Env) {
+	if other == nil {
+		return
+	}
+	e.Clear = e.Clear || other.Clear
+	for _, v := range other.Vars {
+		e.add(v)
+	}
+}
+
+// Parse creates an environment from a list of KEY=VALUE assignments and names of inherited variables
+func Parse(list []string) (*Env, error) {
+	e := new(Env)
+	for _, item := range list {
+		idx := strings.Index(item, "=")
+		if idx == -1 {
+			e.Inherit(item)
+		} else {
+			e.Set(item[:idx], item[idx+1:])
+		}
+	}
+	return e, e.Validate()
+}
+
+// FromMap creates an environment from a map of variables, sorted by name so the order is stable
+func FromMap(m map[string]string) *Env {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	e := new(Env)
+	for _, name := range names {
+		e.Set(name, m[name])
+	}
+	return e
+}
+
+// Validate checks that the names of the variables are valid
+func (e *Env) Validate() error {
+	if e == nil {
+		return nil
+	}
+	for _, v := range e.Vars {
+		if !isValidName(v.Name) {
+			return fmt.Errorf("invalid environment variable name %q", v.Name)
+		}
+		if strings.Contains(v.Value, "\x00") {
+			return fmt.Errorf("invalid value for environment variable %s", v.Name)
+		}
+	}
+	return nil
+}
+
+// IsEmpty checks whether an environment does not change anything
+func (e *Env) IsEmpty() bool {
+	return e == nil || !e.Clear && len(e.Vars) == 0
+}
+
+// Names returns the names of the variables of an environment, in order
+func (e *Env) Names() []string {
+	if e == nil {
+		return nil
+	}
+	var names []string
+	for _, v := range e.Vars {
+		names = append(names, v.Name)
+	}
+	return names
+}
+
+// InheritedNames returns the names of the inherited variables of an environment, in order
+func (e *Env) InheritedNames() []string {
+	if e == nil {
+		return nil
+	}
+	var names []string
+	for _, v := range e.Vars {
+		if v.Inherit {
+			names = append(names, v.Name)
+		}
+	}
+	return names
+}
+
+// Quote quotes a string for POSIX shells, using single quotes when it contains characters that the shell interprets
+func Quote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("_-.,:/@%+=", c)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ScriptCmds returns the shell commands setting the variables of an environment in a script. Inherited variables are
+// already part of the environment of the script so they do not require any command.
+func (e *Env) ScriptCmds() string {
+	if e == nil {
+		return ""
+	}
+	cmds := ""
+	for _, v := range e.Vars {
+		if !v.Inherit {
+			cmds += fmt.Sprintf("export %s=%s\n", v.Name, Quote(v.Value))
+		}
+	}
+	return cmds
+}
+
+// Apply returns the environment resulting from applying an environment on top of a base environment (e.g.,
+// os.Environ()). Variables of the base environment keep their position.
+func (e *Env) Apply(base []string) []string {
+	var result []string
+	index := make(map[string]int)
+	baseValues := make(map[string]string)
+	for _, b := range base {
+		idx := strings.Index(b, "=")
+		if idx == -1 {
+			continue
+		}
+		baseValues[b[:idx]] = b[idx+1:]
+		if e != nil && e.Clear {
+			continue
+		}
+		if pos, ok := index[b[:idx]]; ok {
+			result[pos] = b
+			continue
+		}
+		index[b[:idx]] = len(result)
+		result = append(result, b)
+	}
+	if e == nil {
+		return result
+	}
+
+	for _, v := range e.Vars {
+		value := v.Value
+		if v.Inherit {
+			var ok bool
+			value, ok = baseValues[v.Name]
+			if !ok {
+				continue
+			}
+		}
+		if pos, ok := index[v.Name]; ok {
+			result[pos] = v.Name + "=" + value
+			continue
+		}
+		index[v.Name] = len(result)
+		result = append(result, v.Name+"="+value)
+	}
+	return result
+}
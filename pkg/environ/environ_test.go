@@ -0,0 +1,140 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package environ
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		list      []string
+		expectErr bool
+		names     []string
+		inherited []string
+	}{
+		{
+			list:      []string{"OMP_NUM_THREADS=2", "HOME", "MSG=a=b", "OMP_NUM_THREADS=4"},
+			names:     []string{"OMP_NUM_THREADS", "HOME", "MSG"},
+			inherited: []string{"HOME"},
+		},
+		{
+			list:      []string{"1VAR=1"},
+			expectErr: true,
+		},
+		{
+			list:      []string{"MY-VAR=1"},
+			expectErr: true,
+		},
+		{
+			list:      []string{"=1"},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		e, err := Parse(tt.list)
+		if tt.expectErr {
+			if err == nil {
+				t.Fatalf("Parse(%v) succeeded with invalid names", tt.list)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Parse(%v) failed: %s", tt.list, err)
+		}
+		if strings.Join(e.Names(), ",") != strings.Join(tt.names, ",") {
+			t.Fatalf("Parse(%v) returned %v instead of %v", tt.list, e.Names(), tt.names)
+		}
+		if strings.Join(e.InheritedNames(), ",") != strings.Join(tt.inherited, ",") {
+			t.Fatalf("Parse(%v) inherits %v instead of %v", tt.list, e.InheritedNames(), tt.inherited)
+		}
+	}
+}
+
+func TestFromMap(t *testing.T) {
+	e := FromMap(map[string]string{"B": "2", "C": "3", "A": "1"})
+	if strings.Join(e.Names(), ",") != "A,B,C" {
+		t.Fatalf("FromMap() returned %v instead of sorted variables", e.Names())
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"", "''"},
+		{"/usr/bin:/bin", "/usr/bin:/bin"},
+		{"hello world", "'hello world'"},
+		{"it's", `'it'\''s'`},
+		{"$HOME; rm -rf /", "'$HOME; rm -rf /'"},
+		{"`id`", "'`id`'"},
+	}
+
+	for _, tt := range tests {
+		if q := Quote(tt.value); q != tt.expected {
+			t.Fatalf("Quote(%q) returned %s instead of %s", tt.value, q, tt.expected)
+		}
+	}
+}
+
+func TestScriptCmds(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not available, skipping")
+	}
+
+	values := []string{"hello world", "it's \"quoted\"", "$HOME; `id`", "a\nb", ""}
+	e := new(Env)
+	for _, v := range values {
+		e.Set("VAR", v)
+		e.Inherit("HOME")
+		out, err := exec.Command(bash, "-c", e.ScriptCmds()+"printf %s \"$VAR\"").Output()
+		if err != nil {
+			t.Fatalf("unable to run the commands of %q: %s", v, err)
+		}
+		if string(out) != v {
+			t.Fatalf("the commands set %q instead of %q", string(out), v)
+		}
+	}
+	if strings.Contains(e.ScriptCmds(), "HOME") {
+		t.Fatalf("the commands set inherited variables: %s", e.ScriptCmds())
+	}
+}
+
+func TestApply(t *testing.T) {
+	base := []string{"PATH=/bin", "HOME=/home/user", "LANG=C"}
+	tests := []struct {
+		name     string
+		env      *Env
+		expected []string
+	}{
+		{
+			name:     "nil",
+			expected: base,
+		},
+		{
+			name:     "override",
+			env:      &Env{Vars: []Var{{Name: "NEW", Value: "1"}, {Name: "HOME", Value: "/tmp"}}},
+			expected: []string{"PATH=/bin", "HOME=/tmp", "LANG=C", "NEW=1"},
+		},
+		{
+			name:     "clear",
+			env:      &Env{Clear: true, Vars: []Var{{Name: "PATH", Inherit: true}, {Name: "NEW", Value: "1"}, {Name: "UNSET", Inherit: true}}},
+			expected: []string{"PATH=/bin", "NEW=1"},
+		},
+	}
+
+	for _, tt := range tests {
+		vars := tt.env.Apply(base)
+		if strings.Join(vars, " ") != strings.Join(tt.expected, " ") {
+			t.Fatalf("%s: Apply() returned %v instead of %v", tt.name, vars, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package expect validates the output of jobs against expectations attached to applications and jobs
+package expect
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// NPPlaceholder is replaced by the number of ranks of the job in the templates of RankOutput
+	NPPlaceholder = "#NP"
+
+	// RankPlaceholder is replaced by the rank in the templates of RankOutput
+	RankPlaceholder = "#RANK"
+)
+
+// Output is what a job produced once it completed
+type Output struct {
+	// Stdout is the output of the job
+	Stdout string
+
+	// Stderr is stderr of the job
+	Stderr string
+
+	// ExitCode is the exit code of the job
+	ExitCode int
+
+	// NP is the number of ranks of the job
+	NP int
+
+	// Ranks is the output of each rank when the job split it per rank (optional)
+	Ranks map[int][]string
+}
+
+// CheckFn is a "function pointer" to call to check the output of a job; it returns an error describing why the
+// output is not the expected one
+type CheckFn func(*Output) error
+
+// Expectation is an expected property of the output of a job. Exactly one of Substring, Regex, RankOutput, ExitCode
+// and Custom is set. Since some MPI applications print to stderr, Substring, Regex and RankOutput match either stdout
+// or stderr.
+type Expectation struct {
+	// Name identifies the expectation in the results of the job (optional, derived from the expectation by default)
+	Name string
+
+	// Substring is a string that the output of the job contains
+	Substring string
+
+	// Regex is a regular expression that the output of the job matches
+	Regex string
+
+	// RankOutput is a template of the output of every rank, where NPPlaceholder and RankPlaceholder are replaced by
+	// the number of ranks and by the rank (e.g., "Hello from #RANK of #NP"). It is looked for in the output of each
+	// rank when the job split its output per rank, in the whole output otherwise.
+	RankOutput string
+
+	// ExitCode is the exit code of the job. A job that fails with the expected exit code passes.
+	ExitCode *int
+
+	// Custom is a custom function checking the output of the job
+	Custom CheckFn
+}
+
+// String returns the name of an expectation, derived from what it expects when it has no name
+func (e *Expectation) String() string {
+	switch {
+	case e.Name != "":
+		return e.Name
+	case e.Substring != "":
+		return fmt.Sprintf("output contains %q", e.Substring)
+	case e.Regex != "":
+		return fmt.Sprintf("output matches %q", e.Regex)
+	case e.RankOutput != "":
+		return fmt.Sprintf("output of each rank contains %q", e.RankOutput)
+	case e.ExitCode != nil:
+		return fmt.Sprintf("exit code is %d", *e.ExitCode)
+	case e.Custom != nil:
+		return "custom check"
+	}
+	return "empty expectation"
+}
+
+// Validate checks that an expectation expects exactly one thing and that its regular expression is valid
+func (e *Expectation) Validate() error {
+	n := 0
+	for _, set := range []bool{e.Substring != "", e.Regex != "", e.RankOutput != "", e.ExitCode != nil, e.Custom != nil} {
+		if set {
+			n++
+		}
+	}
+	if n != 1 {
+		return fmt.Errorf("expectation %s must set exactly one of Substring, Regex, RankOutput, ExitCode and Custom", e)
+	}
+	if e.Regex != "" {
+		_, err := regexp.Compile(e.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid regular expression %q: %w", e.Regex, err)
+		}
+	}
+	return nil
+}
+
+// ExpectsExitCode checks whether one of the expectations is about the exit code of the job
+func ExpectsExitCode(exps []Expectation) bool {
+	for _, e := range exps {
+		if e.ExitCode != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRankOutput checks that the output of every rank contains the expanded template
+func checkRankOutput(template string, out *Output) error {
+	if out.NP <= 0 {
+		return fmt.Errorf("unknown number of ranks")
+	}
+	expected := strings.ReplaceAll(template, NPPlaceholder, strconv.Itoa(out.NP))
+	var missing []string
+	for rank := 0; rank < out.NP; rank++ {
+		rankExpected := strings.ReplaceAll(expected, RankPlaceholder, strconv.Itoa(rank))
+		var found bool
+		if out.Ranks != nil {
+			found = strings.Contains(strings.Join(out.Ranks[rank], "\n"), rankExpected)
+		} else {
+			found = strings.Contains(out.Stdout, rankExpected) || strings.Contains(out.Stderr, rankExpected)
+		}
+		if !found {
+			missing = append(missing, strconv.Itoa(rank))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing from the output of rank(s) %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Check checks the output of a job against an expectation
+func (e *Expectation) Check(out *Output) error {
+	err := e.Validate()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case e.Substring != "":
+		if !strings.Contains(out.Stdout, e.Substring) && !strings.Contains(out.Stderr, e.Substring) {
+			return fmt.Errorf("not found in the output")
+		}
+	case e.Regex != "":
+		re := regexp.MustCompile(e.Regex)
+		if !re.MatchString(out.Stdout) && !re.MatchString(out.Stderr) {
+			return fmt.Errorf("no match in the output")
+		}
+	case e.RankOutput != "":
+		return checkRankOutput(e.RankOutput, out)
+	case e.ExitCode != nil:
+		if out.ExitCode != *e.ExitCode {
+			return fmt.Errorf("exit code is %d", out.ExitCode)
+		}
+	case e.Custom != nil:
+		return e.Custom(out)
+	}
+	return nil
+}
+
+// Failure is an expectation that the output of a job does not meet
+type Failure struct {
+	// Expectation is the expectation that failed
+	Expectation *Expectation
+
+	// Err describes why the expectation failed
+	Err error
+}
+
+func (f *Failure) Error() string {
+	return fmt.Sprintf("expectation %s failed: %s", f.Expectation, f.Err)
+}
+
+// CheckAll checks the output of a job against a list of expectations and returns the ones that failed
+func CheckAll(exps []Expectation, out *Output) []*Failure {
+	var failures []*Failure
+	for idx := range exps {
+		e := &exps[idx]
+		err := e.Check(out)
+		if err != nil {
+			failures = append(failures, &Failure{Expectation: e, Err: err})
+		}
+	}
+	return failures
+}
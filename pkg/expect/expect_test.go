@@ -0,0 +1,101 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package expect
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	zero := 0
+	one := 1
+	out := &Output{
+		Stdout:   "Hello from 0 of 2\nHello from 1 of 2\n",
+		Stderr:   "warning: deprecated option\n",
+		ExitCode: 0,
+		NP:       2,
+	}
+	tests := []struct {
+		name  string
+		exp   Expectation
+		out   *Output
+		match bool
+	}{
+		{name: "substring in stdout", exp: Expectation{Substring: "Hello from 1"}, match: true},
+		{name: "substring in stderr", exp: Expectation{Substring: "deprecated"}, match: true},
+		{name: "missing substring", exp: Expectation{Substring: "Goodbye"}, match: false},
+		{name: "regex", exp: Expectation{Regex: `from \d of 2`}, match: true},
+		{name: "regex without match", exp: Expectation{Regex: `^error`}, match: false},
+		{name: "rank template", exp: Expectation{RankOutput: "Hello from #RANK of #NP"}, match: true},
+		{
+			name:  "rank template with a missing rank",
+			exp:   Expectation{RankOutput: "Hello from #RANK of #NP"},
+			out:   &Output{Stdout: "Hello from 0 of 3\nHello from 2 of 3\n", NP: 3},
+			match: false,
+		},
+		{
+			name:  "rank template with per-rank output",
+			exp:   Expectation{RankOutput: "rank #RANK done"},
+			out:   &Output{NP: 2, Ranks: map[int][]string{0: {"rank 0 done"}, 1: {"rank 1 done"}}},
+			match: true,
+		},
+		{
+			name:  "rank template printed by another rank",
+			exp:   Expectation{RankOutput: "rank #RANK done"},
+			out:   &Output{NP: 2, Ranks: map[int][]string{0: {"rank 0 done", "rank 1 done"}}},
+			match: false,
+		},
+		{name: "exit code", exp: Expectation{ExitCode: &zero}, match: true},
+		{name: "unexpected exit code", exp: Expectation{ExitCode: &one}, match: false},
+		{
+			name: "custom",
+			exp: Expectation{Custom: func(o *Output) error {
+				if strings.Count(o.Stdout, "\n") != o.NP {
+					return fmt.Errorf("unexpected number of lines")
+				}
+				return nil
+			}},
+			match: true,
+		},
+		{name: "invalid regex", exp: Expectation{Regex: "("}, match: false},
+		{name: "several kinds", exp: Expectation{Substring: "Hello", Regex: "Hello"}, match: false},
+		{name: "empty", exp: Expectation{}, match: false},
+	}
+
+	for _, tt := range tests {
+		o := tt.out
+		if o == nil {
+			o = out
+		}
+		err := tt.exp.Check(o)
+		if tt.match && err != nil {
+			t.Fatalf("%s: Check() failed: %s", tt.name, err)
+		}
+		if !tt.match && err == nil {
+			t.Fatalf("%s: Check() succeeded", tt.name)
+		}
+	}
+}
+
+func TestCheckAll(t *testing.T) {
+	exps := []Expectation{
+		{Substring: "Hello"},
+		{Name: "completion", Substring: "done"},
+		{RankOutput: "rank #RANK"},
+	}
+	failures := CheckAll(exps, &Output{Stdout: "Hello\nrank 0\n", NP: 2})
+	if len(failures) != 2 {
+		t.Fatalf("CheckAll() returned %d failures instead of 2", len(failures))
+	}
+	if !strings.Contains(failures[0].Error(), "completion") {
+		t.Fatalf("failure %q does not name the expectation", failures[0])
+	}
+	if !strings.Contains(failures[1].Error(), "rank(s) 1") {
+		t.Fatalf("failure %q does not name the missing rank", failures[1])
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package hostlist handles the compressed host lists used by Slurm (e.g., nid[001-004,010],gpu[1-2])
+package hostlist
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Format specifies how the number of slots per host is expressed in a hostfile
+type Format int
+
+const (
+	// PlainFormat lists one host per line, without any number of slots
+	PlainFormat Format = iota
+
+	// SlotsFormat lists hosts as "host slots=N" (e.g., Open MPI)
+	SlotsFormat
+
+	// ColonFormat lists hosts as "host:N" (e.g., MPICH/Hydra, MVAPICH2)
+	ColonFormat
+)
+
+// splitTopLevel splits an expression on the commas that are not within brackets
+func splitTopLevel(expr string) ([]string, error) {
+	var tokens []string
+	depth := 0
+	start := 0
+	for i, c := range expr {
+		switch c {
+		case '[':
+			depth++
+			if depth > 1 {
+				return nil, fmt.Errorf("nested brackets in %s", expr)
+			}
+		case ']':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced brackets in %s", expr)
+			}
+		case ',':
+			if depth == 0 {
+				tokens = append(tokens, expr[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced brackets in %s", expr)
+	}
+	return append(tokens, expr[start:]), nil
+}
+
+// expandRange expands the content of a bracket (e.g., "001-004,010") into the list of values it represents
+func expandRange(r string) ([]string, error) {
+	var values []string
+	for _, item := range strings.Split(r, ",") {
+		if item == "" {
+			return nil, fmt.Errorf("empty range in [%s]", r)
+		}
+		bounds := strings.Split(item, "-")
+		if len(bounds) == 1 {
+			if _, err := strconv.Atoi(item); err != nil {
+				return nil, fmt.Errorf("invalid value %s in [%s]", item, r)
+			}
+			values = append(values, item)
+			continue
+		}
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("invalid range %s in [%s]", item, r)
+		}
+		low, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %s in [%s]", item, r)
+		}
+		high, err := strconv.Atoi(bounds[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %s in [%s]", item, r)
+		}
+		if high < low {
+			return nil, fmt.Errorf("invalid range %s in [%s]", item, r)
+		}
+		// Zero-padding is given by the lower bound, e.g., 001-010
+		width := 0
+		if len(bounds[0]) > 1 && bounds[0][0] == '0' {
+			width = len(bounds[0])
+		}
+		for n := low; n <= high; n++ {
+			values = append(values, fmt.Sprintf("%0*d", width, n))
+		}
+	}
+	return values, nil
+}
+
+// expandHost expands a single host expression that may include several brackets (e.g., rack[1-2]-node[01-04])
+func expandHost(expr string) ([]string, error) {
+	open := strings.Index(expr, "[")
+	if open == -1 {
+		if strings.Contains(expr, "]") {
+			return nil, fmt.Errorf("unbalanced brackets in %s", expr)
+		}
+		return []string{expr}, nil
+	}
+	end := strings.Index(expr, "]")
+	if end < open {
+		return nil, fmt.Errorf("unbalanced brackets in %s", expr)
+	}
+
+	values, err := expandRange(expr[open+1 : end])
+	if err != nil {
+		return nil, err
+	}
+	suffixes, err := expandHost(expr[end+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	for _, v := range values {
+		for _, s := range suffixes {
+			hosts = append(hosts, expr[:open]+v+s)
+		}
+	}
+	return hosts, nil
+}
+
+// Expand returns the list of hosts represented by a compressed host list (e.g., nid[001-004,010],gpu[1-2])
+func Expand(expr string) ([]string, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, nil
+	}
+
+	tokens, err := splitTopLevel(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	for _, t := range tokens {
+		if t == "" {
+			continue
+		}
+		h, err := expandHost(t)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, h...)
+	}
+	return hosts, nil
+}
+
+// group gathers hosts that only differ by a number, e.g., nid001 and nid002
+type group struct {
+	prefix  string
+	suffix  string
+	width   int
+	numbers []int
+}
+
+// splitHost splits a host name around its last number, e.g., "node01-ib" gives "node", "01" and "-ib"
+func splitHost(host string) (string, string, string) {
+	end := -1
+	for i := len(host) - 1; i >= 0; i-- {
+		if host[i] >= '0' && host[i] <= '9' {
+			end = i + 1
+			break
+		}
+	}
+	if end == -1 {
+		return host, "", ""
+	}
+	start := end
+	for start > 0 && host[start-1] >= '0' && host[start-1] <= '9' {
+		start--
+	}
+	return host[:start], host[start:end], host[end:]
+}
+
+func (g *group) String() string {
+	sort.Ints(g.numbers)
+	if len(g.numbers) == 1 {
+		return fmt.Sprintf("%s%0*d%s", g.prefix, g.width, g.numbers[0], g.suffix)
+	}
+
+	var ranges []string
+	for i := 0; i < len(g.numbers); {
+		j := i
+		for j+1 < len(g.numbers) && g.numbers[j+1] == g.numbers[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, fmt.Sprintf("%0*d", g.width, g.numbers[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%0*d-%0*d", g.width, g.numbers[i], g.width, g.numbers[j]))
+		}
+		i = j + 1
+	}
+	return g.prefix + "[" + strings.Join(ranges, ",") + "]" + g.suffix
+}
+
+// Compress returns the compressed host list representing a list of hosts, e.g., nid001, nid002 and nid003 gives
+// nid[001-003]. Duplicates are ignored and groups appear in the order of the first host of each group.
+func Compress(hosts []string) string {
+	// Zero-padded numbers give the width to use for the other numbers of the same length
+	paddedWidths := make(map[string]bool)
+	for _, h := range hosts {
+		prefix, digits, suffix := splitHost(h)
+		if len(digits) > 1 && digits[0] == '0' {
+			paddedWidths[fmt.Sprintf("%s|%s|%d", prefix, suffix, len(digits))] = true
+		}
+	}
+
+	index := make(map[string]*group)
+	seen := make(map[string]bool)
+	var items []interface{}
+	for _, h := range hosts {
+		if h == "" || seen[h] {
+			continue
+		}
+		seen[h] = true
+
+		prefix, digits, suffix := splitHost(h)
+		if digits == "" {
+			items = append(items, h)
+			continue
+		}
+		n, err := strconv.Atoi(digits)
+		if err != nil {
+			items = append(items, h)
+			continue
+		}
+		width := 0
+		if paddedWidths[fmt.Sprintf("%s|%s|%d", prefix, suffix, len(digits))] {
+			width = len(digits)
+		}
+		key := fmt.Sprintf("%s|%s|%d", prefix, suffix, width)
+		g, ok := index[key]
+		if !ok {
+			g = &group{prefix: prefix, suffix: suffix, width: width}
+			index[key] = g
+			items = append(items, g)
+		}
+		g.numbers = append(g.numbers, n)
+	}
+
+	var tokens []string
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			tokens = append(tokens, v)
+		case *group:
+			tokens = append(tokens, v.String())
+		}
+	}
+	return strings.Join(tokens, ",")
+}
+
+// Hostfile returns the content of a hostfile for a list of hosts, in a given format. slots is ignored with PlainFormat
+// or when it is not positive.
+func Hostfile(hosts []string, slots int, format Format) string {
+	content := ""
+	for _, h := range hosts {
+		switch {
+		case format == SlotsFormat && slots > 0:
+			content += fmt.Sprintf("%s slots=%d\n", h, slots)
+		case format == ColonFormat && slots > 0:
+			content += fmt.Sprintf("%s:%d\n", h, slots)
+		default:
+			content += h + "\n"
+		}
+	}
+	return content
+}
+
+// WriteHostfile creates a hostfile for a list of hosts
+func WriteHostfile(path string, hosts []string, slots int, format Format) error {
+	if len(hosts) == 0 {
+		return fmt.Errorf("empty list of hosts")
+	}
+	err := ioutil.WriteFile(path, []byte(Hostfile(hosts, slots, format)), 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write to file %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package hostlist
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "nid001", expected: "nid001"},
+		{input: "nid[001-004,010],gpu[1-2]", expected: "nid001,nid002,nid003,nid004,nid010,gpu1,gpu2"},
+		{input: "node[8-11]", expected: "node8,node9,node10,node11"},
+		{input: "rack[1-2]-node[01-02]", expected: "rack1-node01,rack1-node02,rack2-node01,rack2-node02"},
+		{input: "login,cn[098-101]-ib", expected: "login,cn098-ib,cn099-ib,cn100-ib,cn101-ib"},
+	}
+
+	for _, tt := range tests {
+		hosts, err := Expand(tt.input)
+		if err != nil {
+			t.Fatalf("Expand(%s) failed: %s", tt.input, err)
+		}
+		if strings.Join(hosts, ",") != tt.expected {
+			t.Fatalf("Expand(%s) returned %s instead of %s", tt.input, strings.Join(hosts, ","), tt.expected)
+		}
+	}
+
+	for _, invalid := range []string{"nid[001-004", "nid001]", "nid[4-1]", "nid[a-b]", "nid[[1-2]]"} {
+		_, err := Expand(invalid)
+		if err == nil {
+			t.Fatalf("Expand(%s) succeeded with an invalid expression", invalid)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "nid001", expected: "nid001"},
+		{input: "nid001,nid002,nid003,nid004,nid010,gpu1,gpu2", expected: "nid[001-004,010],gpu[1-2]"},
+		{input: "node8,node9,node10,node11", expected: "node[8-11]"},
+		{input: "nid003,nid001,nid002,nid001", expected: "nid[001-003]"},
+		{input: "nid098,nid099,nid100", expected: "nid[098-100]"},
+		{input: "login,cn01-ib,cn02-ib", expected: "login,cn[01-02]-ib"},
+	}
+
+	for _, tt := range tests {
+		compressed := Compress(strings.Split(tt.input, ","))
+		if compressed != tt.expected {
+			t.Fatalf("Compress(%s) returned %s instead of %s", tt.input, compressed, tt.expected)
+		}
+		hosts, err := Expand(compressed)
+		if err != nil {
+			t.Fatalf("Expand(%s) failed: %s", compressed, err)
+		}
+		if Compress(hosts) != compressed {
+			t.Fatalf("Compress() and Expand() are not consistent for %s", compressed)
+		}
+	}
+}
+
+func TestHostfile(t *testing.T) {
+	hosts := []string{"nid001", "nid002"}
+	if Hostfile(hosts, 4, SlotsFormat) != "nid001 slots=4\nnid002 slots=4\n" {
+		t.Fatalf("invalid hostfile: %s", Hostfile(hosts, 4, SlotsFormat))
+	}
+	if Hostfile(hosts, 4, ColonFormat) != "nid001:4\nnid002:4\n" {
+		t.Fatalf("invalid hostfile: %s", Hostfile(hosts, 4, ColonFormat))
+	}
+	if Hostfile(hosts, 4, PlainFormat) != "nid001\nnid002\n" {
+		t.Fatalf("invalid hostfile: %s", Hostfile(hosts, 4, PlainFormat))
+	}
+}
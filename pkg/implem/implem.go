@@ -8,9 +8,12 @@ package implem
 import (
 	"fmt"
 
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/intelmpi"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/mpich"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/mvapich2"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/openmpi"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/vendormpi"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/buildinfo"
 )
 
 const (
@@ -22,6 +25,18 @@ const (
 
 	// MVAPICH2 us the identifier for MVAPICH2
 	MVAPICH2 = mvapich2.ID
+
+	// INTELMPI is the identifier for Intel MPI
+	INTELMPI = intelmpi.ID
+
+	// CRAYMPICH is the identifier for HPE Cray MPICH
+	CRAYMPICH = vendormpi.CrayMPICHID
+
+	// SPECTRUMMPI is the identifier for IBM Spectrum MPI
+	SPECTRUMMPI = vendormpi.SpectrumMPIID
+
+	// HPEMPT is the identifier for HPE (SGI) MPT
+	HPEMPT = vendormpi.MPTID
 )
 
 // Info gathers all data about a specific MPI implementation
@@ -34,11 +49,32 @@ type Info struct {
 
 	// InstallDir is where the MPI implementation is installed
 	InstallDir string
+
+	// LibDir is where the libraries of the MPI implementation are (optional, InstallDir/lib by default)
+	LibDir string
+
+	// Launcher is the path to the command used to start the ranks (optional)
+	Launcher string
+
+	// ConfigureOptions is the list of options used to configure the MPI implementation, when known (optional)
+	ConfigureOptions []string
+
+	// Build describes how the MPI implementation was built, when known (optional)
+	Build *buildinfo.Info
+
+	// NetworkLibs is the list of network libraries the MPI implementation depends on (e.g., libucp.so.0) (optional)
+	NetworkLibs []string
+
+	// SpackHash is the hash of the Spack installation of the MPI implementation, if installed with Spack (optional)
+	SpackHash string
 }
 
 // IsMPI checks if information passed in is an MPI implementation
 func IsMPI(i *Info) bool {
-	if i != nil && (i.ID == OMPI || i.ID == MPICH || i.ID == MVAPICH2) {
+	if i != nil && (i.ID == OMPI || i.ID == MPICH || i.ID == MVAPICH2 || i.ID == INTELMPI) {
+		return true
+	}
+	if i != nil && vendormpi.IsVendorMPI(i.ID) {
 		return true
 	}
 
@@ -50,14 +86,47 @@ func IsMPI(i *Info) bool {
 // - the install directory, then the function figures out the implementation and version
 // - the implementation (e.g., openmpi) and the function figures out where it is installed
 // - a few other combinations of these to provide a flexible way to handle various implementation of MPI
+// - nothing or a vendor implementation (e.g., cray-mpich), in which case the function looks for a vendor
+// implementation provided by the environment, i.e., env or the current environment if env is nil
 // If no suitable implementation can be found, the function returns an error
 func (i *Info) Load(env []string) error {
+	if i.InstallDir == "" && (i.ID == "" || vendormpi.IsVendorMPI(i.ID)) {
+		v, err := vendormpi.DetectFromEnv(env)
+		if err != nil || (i.ID != "" && v.ID != i.ID) {
+			if i.ID == "" {
+				// Nothing was requested, nothing to load
+				return nil
+			}
+			return fmt.Errorf("unable to find %s in the environment", i.ID)
+		}
+		i.ID = v.ID
+		i.Version = v.Version
+		i.InstallDir = v.InstallDir
+		i.LibDir = v.LibDir
+		return nil
+	}
+
 	if i.InstallDir != "" && (i.ID == "" || i.Version == "") {
-		var err error
+		v, err := vendormpi.DetectFromDir(i.InstallDir)
+		if err == nil {
+			i.ID = v.ID
+			i.Version = v.Version
+			i.LibDir = v.LibDir
+			return nil
+		}
 		i.ID, i.Version, err = openmpi.DetectFromDir(i.InstallDir, env)
 		if err == nil {
 			return nil
 		}
+		// MVAPICH2 and Intel MPI are derived from MPICH, they must be checked first
+		i.ID, i.Version, err = mvapich2.DetectFromDir(i.InstallDir, env)
+		if err == nil {
+			return nil
+		}
+		i.ID, i.Version, err = intelmpi.DetectFromDir(i.InstallDir, env)
+		if err == nil {
+			return nil
+		}
 		i.ID, i.Version, err = mpich.DetectFromDir(i.InstallDir, env)
 		if err == nil {
 			return nil
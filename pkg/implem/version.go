@@ -0,0 +1,291 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package implem
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// preReleases lists the pre-release tags, from the oldest to the most recent
+var preReleases = []string{"a", "b", "rc"}
+
+// preReleaseAliases maps the long forms of pre-release tags to their short form
+var preReleaseAliases = map[string]string{
+	"alpha": "a",
+	"beta":  "b",
+}
+
+// Version is a parsed version of a MPI implementation, e.g., 4.1.0rc2 or 2.3.7-gdr
+type Version struct {
+	// Release is the list of release numbers, e.g., 4, 1, 0 for 4.1.0rc2
+	Release []int
+
+	// PreRelease is the pre-release tag (a, b or rc), empty for final releases
+	PreRelease string
+
+	// PreReleaseNumber is the number of the pre-release, e.g., 2 for 4.1.0rc2
+	PreReleaseNumber int
+
+	// Suffix is the vendor suffix following the version (e.g., -gdr, +cuda), it is ignored by comparisons
+	Suffix string
+
+	// Raw is the string the version was parsed from
+	Raw string
+}
+
+// leadingDigits returns the number at the beginning of a string and the rest of the string
+func leadingDigits(s string) (string, string) {
+	idx := 0
+	for idx < len(s) && s[idx] >= '0' && s[idx] <= '9' {
+		idx++
+	}
+	return s[:idx], s[idx:]
+}
+
+// ParseVersion parses a version string such as 3.0.4, 4.0b1, 4.1.0rc2, 2019.12 or 2.3.7-1
+func ParseVersion(s string) (*Version, error) {
+	v := new(Version)
+	v.Raw = s
+	rest := strings.TrimPrefix(strings.TrimSpace(s), "v")
+
+	for {
+		digits, r := leadingDigits(rest)
+		if digits == "" {
+			return nil, fmt.Errorf("invalid version %s", s)
+		}
+		n, err := strconv.Atoi(digits)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %s: %w", s, err)
+		}
+		v.Release = append(v.Release, n)
+		rest = r
+		if !strings.HasPrefix(rest, ".") {
+			break
+		}
+		rest = rest[1:]
+	}
+
+	lower := strings.ToLower(rest)
+	for alias, tag := range preReleaseAliases {
+		if strings.HasPrefix(lower, alias) {
+			lower = tag + lower[len(alias):]
+			rest = tag + rest[len(alias):]
+		}
+	}
+	// "rc" must be checked before shorter tags
+	for idx := len(preReleases) - 1; idx >= 0; idx-- {
+		tag := preReleases[idx]
+		if !strings.HasPrefix(lower, tag) {
+			continue
+		}
+		digits, r := leadingDigits(rest[len(tag):])
+		if digits == "" {
+			// Not a pre-release (e.g., a vendor suffix starting with the same letter)
+			break
+		}
+		v.PreRelease = tag
+		v.PreReleaseNumber, _ = strconv.Atoi(digits)
+		rest = r
+		break
+	}
+
+	v.Suffix = rest
+	return v, nil
+}
+
+// String returns the version as it was parsed
+func (v *Version) String() string {
+	return v.Raw
+}
+
+func preReleaseRank(tag string) int {
+	for idx, t := range preReleases {
+		if t == tag {
+			return idx
+		}
+	}
+	// Final releases come after all pre-releases
+	return len(preReleases)
+}
+
+// sameRelease checks whether two versions have the same release numbers, regardless of pre-releases
+func (v *Version) sameRelease(other *Version) bool {
+	final := Version{Release: v.Release}
+	otherFinal := Version{Release: other.Release}
+	return final.Compare(&otherFinal) == 0
+}
+
+// Compare compares two versions and returns -1, 0 or 1 if v is respectively older, the same or more recent than
+// other. Missing release numbers are considered to be 0, e.g., 4.1 and 4.1.0 are the same version.
+func (v *Version) Compare(other *Version) int {
+	n := len(v.Release)
+	if len(other.Release) > n {
+		n = len(other.Release)
+	}
+	for idx := 0; idx < n; idx++ {
+		a, b := 0, 0
+		if idx < len(v.Release) {
+			a = v.Release[idx]
+		}
+		if idx < len(other.Release) {
+			b = other.Release[idx]
+		}
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	rankA, rankB := preReleaseRank(v.PreRelease), preReleaseRank(other.PreRelease)
+	switch {
+	case rankA < rankB:
+		return -1
+	case rankA > rankB:
+		return 1
+	case v.PreReleaseNumber < other.PreReleaseNumber:
+		return -1
+	case v.PreReleaseNumber > other.PreReleaseNumber:
+		return 1
+	}
+	return 0
+}
+
+// ParsedVersion returns the parsed version of a MPI implementation
+func (i *Info) ParsedVersion() (*Version, error) {
+	// Some implementations report their version prefixed by their name (e.g., mvapich2-2.3.7)
+	return ParseVersion(strings.TrimPrefix(i.Version, i.ID+"-"))
+}
+
+// condition is a single comparison of a constraint, e.g., >=4.1
+type condition struct {
+	op      string
+	version *Version
+}
+
+// operators is the list of supported comparison operators; longer operators must be listed first
+var operators = []string{">=", "<=", "==", "!=", ">", "<", "="}
+
+func (c *condition) matches(v *Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case ">=":
+		return cmp >= 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case "<":
+		// Pre-releases of a version are not considered as older than that version, e.g., 5.0.0rc1 does not
+		// satisfy <5, which is what users expect when requesting any version prior to a given release
+		if c.version.PreRelease == "" && v.PreRelease != "" && v.sameRelease(c.version) {
+			return false
+		}
+		return cmp < 0
+	case "!=":
+		return cmp != 0
+	}
+	return cmp == 0
+}
+
+// Constraint restricts the MPI implementations that can be used, e.g., openmpi>=4.1,<5
+type Constraint struct {
+	// ID is the identifier of the required MPI implementation, empty if any implementation is suitable
+	ID string
+
+	conditions []condition
+}
+
+// ParseConstraint parses a constraint expression: an optional implementation identifier followed by a
+// comma-separated list of version conditions (e.g., "openmpi>=4.1,<5", "mpich", ">=3.4"). A version without
+// operator requires that exact version (e.g., "mvapich2==2.3.7" or "mvapich2=2.3.7").
+func ParseConstraint(expr string) (*Constraint, error) {
+	expr = strings.TrimSpace(expr)
+	c := new(Constraint)
+	idx := strings.IndexAny(expr, "<>=!")
+	if idx == -1 {
+		c.ID = expr
+		return c, nil
+	}
+	c.ID = strings.TrimSpace(expr[:idx])
+
+	for _, token := range strings.Split(expr[idx:], ",") {
+		token = strings.TrimSpace(token)
+		var cond condition
+		for _, op := range operators {
+			if strings.HasPrefix(token, op) {
+				cond.op = op
+				break
+			}
+		}
+		if cond.op == "" {
+			return nil, fmt.Errorf("invalid condition %s in %s", token, expr)
+		}
+		v, err := ParseVersion(strings.TrimSpace(token[len(cond.op):]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition %s in %s: %w", token, expr, err)
+		}
+		cond.version = v
+		c.conditions = append(c.conditions, cond)
+	}
+	return c, nil
+}
+
+// Matches checks whether a MPI implementation satisfies a constraint
+func (c *Constraint) Matches(i *Info) bool {
+	if i == nil || (c.ID != "" && c.ID != i.ID) {
+		return false
+	}
+	if len(c.conditions) == 0 {
+		return true
+	}
+	v, err := i.ParsedVersion()
+	if err != nil {
+		return false
+	}
+	for idx := range c.conditions {
+		if !c.conditions[idx].matches(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Select returns the most recent MPI implementation from a list that satisfies a constraint expression
+func Select(installs []Info, expr string) (*Info, error) {
+	c, err := ParseConstraint(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected *Info
+	var selectedVersion *Version
+	for idx := range installs {
+		i := &installs[idx]
+		if !c.Matches(i) {
+			continue
+		}
+		v, err := i.ParsedVersion()
+		if err != nil {
+			// Only possible when the constraint does not include any version
+			if selected == nil {
+				selected = i
+			}
+			continue
+		}
+		if selectedVersion == nil || v.Compare(selectedVersion) > 0 {
+			selected = i
+			selectedVersion = v
+		}
+	}
+	if selected == nil {
+		return nil, fmt.Errorf("no MPI implementation satisfies %s", expr)
+	}
+	return selected, nil
+}
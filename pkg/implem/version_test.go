@@ -0,0 +1,126 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package implem
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input      string
+		release    []int
+		preRelease string
+		preNumber  int
+		suffix     string
+	}{
+		{input: "3.0.4", release: []int{3, 0, 4}},
+		{input: "4.0b1", release: []int{4, 0}, preRelease: "b", preNumber: 1},
+		{input: "4.1.0rc2", release: []int{4, 1, 0}, preRelease: "rc", preNumber: 2},
+		{input: "5.0.0alpha1", release: []int{5, 0, 0}, preRelease: "a", preNumber: 1},
+		{input: "v2019.12", release: []int{2019, 12}},
+		{input: "2.3.7-1", release: []int{2, 3, 7}, suffix: "-1"},
+		{input: "8.1.27-cray", release: []int{8, 1, 27}, suffix: "-cray"},
+		{input: "2.3b", release: []int{2, 3}, suffix: "b"},
+	}
+
+	for _, tt := range tests {
+		v, err := ParseVersion(tt.input)
+		if err != nil {
+			t.Fatalf("ParseVersion(%s) failed: %s", tt.input, err)
+		}
+		if len(v.Release) != len(tt.release) {
+			t.Fatalf("ParseVersion(%s) returned %v instead of %v", tt.input, v.Release, tt.release)
+		}
+		for idx := range tt.release {
+			if v.Release[idx] != tt.release[idx] {
+				t.Fatalf("ParseVersion(%s) returned %v instead of %v", tt.input, v.Release, tt.release)
+			}
+		}
+		if v.PreRelease != tt.preRelease || v.PreReleaseNumber != tt.preNumber || v.Suffix != tt.suffix {
+			t.Fatalf("ParseVersion(%s) returned %s%d/%s instead of %s%d/%s", tt.input, v.PreRelease, v.PreReleaseNumber, v.Suffix, tt.preRelease, tt.preNumber, tt.suffix)
+		}
+	}
+
+	_, err := ParseVersion("unknown")
+	if err == nil {
+		t.Fatalf("ParseVersion() succeeded with an invalid version")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		{a: "4.1", b: "4.1.0", expected: 0},
+		{a: "4.0b1", b: "4.0", expected: -1},
+		{a: "4.0rc1", b: "4.0b2", expected: 1},
+		{a: "4.0a3", b: "4.0b1", expected: -1},
+		{a: "3.0.4", b: "4.0b1", expected: -1},
+		{a: "10.0", b: "9.9.9", expected: 1},
+		{a: "2.3.7-1", b: "2.3.7", expected: 0},
+	}
+
+	for _, tt := range tests {
+		a, _ := ParseVersion(tt.a)
+		b, _ := ParseVersion(tt.b)
+		if cmp := a.Compare(b); cmp != tt.expected {
+			t.Fatalf("comparing %s and %s returned %d instead of %d", tt.a, tt.b, cmp, tt.expected)
+		}
+		if cmp := b.Compare(a); cmp != -tt.expected {
+			t.Fatalf("comparing %s and %s returned %d instead of %d", tt.b, tt.a, cmp, -tt.expected)
+		}
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	installs := []Info{
+		{ID: OMPI, Version: "3.0.4", InstallDir: "/opt/openmpi-3.0.4"},
+		{ID: OMPI, Version: "4.1.5", InstallDir: "/opt/openmpi-4.1.5"},
+		{ID: OMPI, Version: "5.0.0rc9", InstallDir: "/opt/openmpi-5.0.0rc9"},
+		{ID: MPICH, Version: "4.0b1", InstallDir: "/opt/mpich-4.0b1"},
+		{ID: MVAPICH2, Version: "mvapich2-2.3.7", InstallDir: "/opt/mvapich2-2.3.7"},
+	}
+
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{expr: "openmpi>=4.1,<5", expected: "/opt/openmpi-4.1.5"},
+		{expr: "openmpi<5.0.0", expected: "/opt/openmpi-4.1.5"},
+		{expr: "openmpi<5.0.0rc10", expected: "/opt/openmpi-5.0.0rc9"},
+		{expr: "openmpi", expected: "/opt/openmpi-5.0.0rc9"},
+		{expr: "openmpi!=5.0.0rc9,>3", expected: "/opt/openmpi-4.1.5"},
+		{expr: "mpich>=4.0a1", expected: "/opt/mpich-4.0b1"},
+		{expr: "mvapich2==2.3.7", expected: "/opt/mvapich2-2.3.7"},
+		{expr: "<4", expected: "/opt/openmpi-3.0.4"},
+		{expr: "mpich>=4", expected: ""},
+		{expr: "intelmpi", expected: ""},
+	}
+
+	for _, tt := range tests {
+		i, err := Select(installs, tt.expr)
+		if tt.expected == "" {
+			if err == nil {
+				t.Fatalf("Select(%s) returned %s instead of failing", tt.expr, i.InstallDir)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Select(%s) failed: %s", tt.expr, err)
+		}
+		if i.InstallDir != tt.expected {
+			t.Fatalf("Select(%s) returned %s instead of %s", tt.expr, i.InstallDir, tt.expected)
+		}
+	}
+
+	for _, expr := range []string{"openmpi>=", "openmpi>=4.1,~5", "openmpi>=abc"} {
+		_, err := ParseConstraint(expr)
+		if err == nil {
+			t.Fatalf("ParseConstraint(%s) succeeded with an invalid expression", expr)
+		}
+	}
+}
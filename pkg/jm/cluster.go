@@ -0,0 +1,218 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package jm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/slurm"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/job"
+)
+
+// Availability gives the current usage of the resources of a partition
+type Availability struct {
+	AllocatedNodes int
+	IdleNodes      int
+	OtherNodes     int
+	TotalNodes     int
+
+	AllocatedCPUs int
+	IdleCPUs      int
+	OtherCPUs     int
+	TotalCPUs     int
+}
+
+func (a *Availability) add(b Availability) {
+	a.AllocatedNodes += b.AllocatedNodes
+	a.IdleNodes += b.IdleNodes
+	a.OtherNodes += b.OtherNodes
+	a.TotalNodes += b.TotalNodes
+	a.AllocatedCPUs += b.AllocatedCPUs
+	a.IdleCPUs += b.IdleCPUs
+	a.OtherCPUs += b.OtherCPUs
+	a.TotalCPUs += b.TotalCPUs
+}
+
+// Partition represents a partition (or queue) handled by the job manager
+type Partition struct {
+	// Name is the name of the partition
+	Name string
+
+	// Default specifies whether the partition is used when none is requested
+	Default bool
+
+	// State is the state of the partition (e.g., UP, DOWN, DRAIN)
+	State string
+
+	// DefaultTime is the time limit of jobs that do not specify one
+	DefaultTime time.Duration
+
+	// MaxTime is the maximum time limit a job can request (slurm.InfiniteTime when unlimited)
+	MaxTime time.Duration
+
+	// MinNodes is the minimum number of nodes a job must request
+	MinNodes int
+
+	// MaxNodes is the maximum number of nodes a job can request (-1 when unlimited)
+	MaxNodes int
+
+	// TotalNodes is the number of nodes in the partition
+	TotalNodes int
+
+	// TotalCPUs is the number of CPUs in the partition
+	TotalCPUs int
+
+	// Nodes is the compressed list of nodes in the partition (e.g., nid[001-004])
+	Nodes string
+
+	// Availability is the current usage of the partition's resources
+	Availability Availability
+}
+
+// Node represents a compute node handled by the job manager
+type Node struct {
+	// Name is the name of the node
+	Name string
+
+	// State is the state of the node (e.g., IDLE, MIXED, ALLOCATED, DOWN+DRAIN)
+	State string
+
+	// CPUs is the number of CPUs on the node
+	CPUs int
+
+	// AllocatedCPUs is the number of CPUs currently allocated to jobs
+	AllocatedCPUs int
+
+	// Memory is the amount of memory of the node in MB
+	Memory int
+
+	// AllocatedMemory is the amount of memory currently allocated to jobs in MB
+	AllocatedMemory int
+
+	// Gres is the list of generic resources of the node (e.g., gpu:a100:4)
+	Gres []string
+
+	// Features is the list of features of the node
+	Features []string
+
+	// Partitions is the list of partitions the node belongs to
+	Partitions []string
+}
+
+// ClusterInfo gathers the details about the partitions and nodes handled by a job manager
+type ClusterInfo struct {
+	Partitions []Partition
+	Nodes      []Node
+}
+
+// Partition returns the partition of a given name
+func (c *ClusterInfo) Partition(name string) (*Partition, error) {
+	for idx := range c.Partitions {
+		if c.Partitions[idx].Name == name {
+			return &c.Partitions[idx], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown partition %s", name)
+}
+
+// Fits checks whether the resources requested by a job can be satisfied by the partition.
+// It returns nil if so, or an error explaining why not otherwise. The time limit of a job that does not specify one
+// is the one of its batch script (slurm.DefaultTimeLimit). GRES and features are not checked since jobs do not
+// request them.
+func (p *Partition) Fits(j *job.Job) error {
+	if p.State != "" && p.State != "UP" {
+		return fmt.Errorf("partition %s is %s", p.Name, p.State)
+	}
+
+	nnodes := j.NNodes
+	if nnodes == 0 {
+		nnodes = 1
+	}
+	if p.MaxNodes != -1 && nnodes > p.MaxNodes {
+		return fmt.Errorf("%d nodes requested but partition %s allows at most %d", nnodes, p.Name, p.MaxNodes)
+	}
+	if p.TotalNodes > 0 && nnodes > p.TotalNodes {
+		return fmt.Errorf("%d nodes requested but partition %s has only %d", nnodes, p.Name, p.TotalNodes)
+	}
+	if nnodes < p.MinNodes {
+		return fmt.Errorf("%d nodes requested but partition %s requires at least %d", nnodes, p.Name, p.MinNodes)
+	}
+	if p.TotalCPUs > 0 && j.NP > p.TotalCPUs {
+		return fmt.Errorf("%d ranks requested but partition %s has only %d CPUs", j.NP, p.Name, p.TotalCPUs)
+	}
+
+	timeLimit := j.MaxExecTime
+	if timeLimit == "" {
+		timeLimit = slurm.DefaultTimeLimit
+	}
+	t, err := slurm.ParseTime(timeLimit)
+	if err != nil {
+		return fmt.Errorf("invalid time limit: %w", err)
+	}
+	if p.MaxTime > 0 && t > p.MaxTime {
+		return fmt.Errorf("time limit %s exceeds the maximum time of partition %s", timeLimit, p.Name)
+	}
+
+	return nil
+}
+
+// SelectPartition picks a partition that fits the resources requested by a job. If the job already specifies a
+// partition, the function only checks that it fits. Otherwise, partitions where the job can start right away are
+// preferred, then the default partition, then the partitions with the most idle nodes.
+func (c *ClusterInfo) SelectPartition(j *job.Job) (*Partition, error) {
+	if j == nil {
+		return nil, fmt.Errorf("undefined job")
+	}
+
+	if j.Partition != "" {
+		p, err := c.Partition(j.Partition)
+		if err != nil {
+			return nil, err
+		}
+		err = p.Fits(j)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+
+	var candidates []*Partition
+	var reasons []string
+	for idx := range c.Partitions {
+		p := &c.Partitions[idx]
+		err := p.Fits(j)
+		if err != nil {
+			reasons = append(reasons, err.Error())
+			continue
+		}
+		candidates = append(candidates, p)
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no partition can run the job: %s", strings.Join(reasons, "; "))
+	}
+
+	nnodes := j.NNodes
+	if nnodes == 0 {
+		nnodes = 1
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		pa, pb := candidates[a], candidates[b]
+		startA := pa.Availability.IdleNodes >= nnodes
+		startB := pb.Availability.IdleNodes >= nnodes
+		if startA != startB {
+			return startA
+		}
+		if pa.Default != pb.Default {
+			return pa.Default
+		}
+		return pa.Availability.IdleNodes > pb.Availability.IdleNodes
+	})
+
+	return candidates[0], nil
+}
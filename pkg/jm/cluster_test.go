@@ -0,0 +1,136 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package jm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/slurm"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/job"
+)
+
+const scontrolPartitions = `PartitionName=debug AllowGroups=ALL AllowAccounts=ALL AllowQos=ALL AllocNodes=ALL Default=NO QoS=N/A DefaultTime=00:30:00 DisableRootJobs=NO ExclusiveUser=NO GraceTime=0 Hidden=NO MaxNodes=2 MaxTime=01:00:00 MinNodes=0 LLN=NO MaxCPUsPerNode=UNLIMITED Nodes=nid[001-002] PriorityJobFactor=1 PriorityTier=1 RootOnly=NO ReqResv=NO OverSubscribe=NO OverTimeLimit=NONE PreemptMode=OFF State=UP TotalCPUs=256 TotalNodes=2 SelectTypeParameters=NONE JobDefaults=(null) DefMemPerNode=UNLIMITED MaxMemPerNode=UNLIMITED
+PartitionName=batch AllowGroups=ALL AllowAccounts=ALL AllowQos=ALL AllocNodes=ALL Default=YES QoS=N/A DefaultTime=NONE DisableRootJobs=NO ExclusiveUser=NO GraceTime=0 Hidden=NO MaxNodes=UNLIMITED MaxTime=2-00:00:00 MinNodes=1 LLN=NO MaxCPUsPerNode=UNLIMITED Nodes=nid[003-010] PriorityJobFactor=1 PriorityTier=1 RootOnly=NO ReqResv=NO OverSubscribe=NO OverTimeLimit=NONE PreemptMode=OFF State=UP TotalCPUs=1024 TotalNodes=8 SelectTypeParameters=NONE JobDefaults=(null) DefMemPerNode=UNLIMITED MaxMemPerNode=UNLIMITED
+PartitionName=maint AllowGroups=ALL Default=NO DefaultTime=NONE MaxNodes=UNLIMITED MaxTime=UNLIMITED MinNodes=0 Nodes=nid011 State=DOWN TotalCPUs=128 TotalNodes=1
+`
+
+const scontrolNodes = `NodeName=nid001 Arch=x86_64 CoresPerSocket=64 CPUAlloc=0 CPUTot=128 CPULoad=0.01 AvailableFeatures=rome,ib ActiveFeatures=rome,ib Gres=gpu:a100:4 NodeAddr=nid001 NodeHostName=nid001 Version=22.05.8 OS=Linux 5.14.21-150400.24.46-default #1 SMP PREEMPT_DYNAMIC RealMemory=256000 AllocMem=0 FreeMem=250000 Sockets=2 Boards=1 State=IDLE ThreadsPerCore=1 TmpDisk=0 Weight=1 Owner=N/A MCS_label=N/A Partitions=debug
+NodeName=nid003 Arch=x86_64 CoresPerSocket=64 CPUAlloc=64 CPUTot=128 CPULoad=32.00 AvailableFeatures=(null) ActiveFeatures=(null) Gres=(null) NodeAddr=nid003 RealMemory=256000 AllocMem=128000 Sockets=2 State=MIXED Partitions=batch
+`
+
+const sinfoAvailability = `debug|0/2/0/2|0/256/0/256
+batch|3/1/4/8|448/512/64/1024
+maint|0/0/1/1|0/0/128/128
+`
+
+func loadTestClusterInfo(t *testing.T) *ClusterInfo {
+	var err error
+	info := new(ClusterInfo)
+	info.Partitions, err = parseScontrolPartitions(scontrolPartitions)
+	if err != nil {
+		t.Fatalf("parseScontrolPartitions() failed: %s", err)
+	}
+	info.Nodes, err = parseScontrolNodes(scontrolNodes)
+	if err != nil {
+		t.Fatalf("parseScontrolNodes() failed: %s", err)
+	}
+	err = parseSinfoAvailability(sinfoAvailability, info.Partitions)
+	if err != nil {
+		t.Fatalf("parseSinfoAvailability() failed: %s", err)
+	}
+	return info
+}
+
+func TestParseClusterInfo(t *testing.T) {
+	info := loadTestClusterInfo(t)
+
+	if len(info.Partitions) != 3 {
+		t.Fatalf("%d partitions found instead of 3", len(info.Partitions))
+	}
+	batch, err := info.Partition("batch")
+	if err != nil {
+		t.Fatalf("unable to find partition batch: %s", err)
+	}
+	if !batch.Default || batch.MaxNodes != -1 || batch.MinNodes != 1 || batch.MaxTime != 48*time.Hour || batch.DefaultTime != 0 {
+		t.Fatalf("invalid limits for partition batch: %+v", batch)
+	}
+	if batch.TotalNodes != 8 || batch.TotalCPUs != 1024 || batch.Nodes != "nid[003-010]" {
+		t.Fatalf("invalid resources for partition batch: %+v", batch)
+	}
+	if batch.Availability.IdleNodes != 1 || batch.Availability.AllocatedCPUs != 448 {
+		t.Fatalf("invalid availability for partition batch: %+v", batch.Availability)
+	}
+	maint, _ := info.Partition("maint")
+	if maint.MaxTime != slurm.InfiniteTime {
+		t.Fatalf("invalid maximum time for partition maint: %s", maint.MaxTime)
+	}
+
+	if len(info.Nodes) != 2 {
+		t.Fatalf("%d nodes found instead of 2", len(info.Nodes))
+	}
+	n := info.Nodes[0]
+	if n.Name != "nid001" || n.CPUs != 128 || n.Memory != 256000 || n.State != "IDLE" {
+		t.Fatalf("invalid node: %+v", n)
+	}
+	if len(n.Gres) != 1 || n.Gres[0] != "gpu:a100:4" || len(n.Features) != 2 || n.Features[1] != "ib" {
+		t.Fatalf("invalid GRES or features: %+v", n)
+	}
+	if info.Nodes[1].Gres != nil || info.Nodes[1].AllocatedCPUs != 64 {
+		t.Fatalf("invalid node: %+v", info.Nodes[1])
+	}
+}
+
+func TestSelectPartition(t *testing.T) {
+	info := loadTestClusterInfo(t)
+
+	tests := []struct {
+		name              string
+		j                 job.Job
+		expectedPartition string
+	}{
+		{
+			name:              "small job starting right away",
+			j:                 job.Job{NNodes: 2, NP: 4, MaxExecTime: "0:30:0"},
+			expectedPartition: "debug",
+		},
+		{
+			name:              "long job",
+			j:                 job.Job{NNodes: 2, NP: 4, MaxExecTime: "10:00:00"},
+			expectedPartition: "batch",
+		},
+		{
+			name:              "large job",
+			j:                 job.Job{NNodes: 6, NP: 600},
+			expectedPartition: "batch",
+		},
+	}
+
+	for _, tt := range tests {
+		p, err := info.SelectPartition(&tt.j)
+		if err != nil {
+			t.Fatalf("%s: SelectPartition() failed: %s", tt.name, err)
+		}
+		if p.Name != tt.expectedPartition {
+			t.Fatalf("%s: SelectPartition() returned %s instead of %s", tt.name, p.Name, tt.expectedPartition)
+		}
+	}
+
+	tooBig := job.Job{NNodes: 20}
+	_, err := info.SelectPartition(&tooBig)
+	if err == nil {
+		t.Fatalf("SelectPartition() succeeded with a job that cannot fit")
+	}
+	short := Partition{Name: "short", State: "UP", MaxTime: 10 * time.Minute, MaxNodes: -1}
+	if short.Fits(&job.Job{NNodes: 1}) == nil {
+		t.Fatalf("a job with the default time limit fits a partition with a shorter maximum time")
+	}
+	down := job.Job{Partition: "maint"}
+	_, err = info.SelectPartition(&down)
+	if err == nil {
+		t.Fatalf("SelectPartition() succeeded with a partition that is down")
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package jm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/environ"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/hostlist"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/job"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/mpi"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/sys"
+)
+
+const (
+	// slurmNodeListEnvVar is the environment variable set by Slurm with the list of nodes of the current allocation
+	slurmNodeListEnvVar = "SLURM_JOB_NODELIST"
+)
+
+// getJobHosts returns the list of hosts to use for a job, i.e., the hosts explicitly requested by the user or,
+// if none, the hosts of the current Slurm allocation.
+func getJobHosts(j *job.Job) ([]string, error) {
+	expr := j.HostList
+	if expr == "" {
+		expr = os.Getenv(slurmNodeListEnvVar)
+	}
+	hosts, err := hostlist.Expand(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid host list %s: %w", expr, err)
+	}
+	return hosts, nil
+}
+
+// getHostfileDir returns the directory where the hostfiles of a job are created
+func getHostfileDir(j *job.Job, sysCfg *sys.Config) string {
+	if j.RunDir != "" {
+		return j.RunDir
+	}
+	return sysCfg.ScratchDir
+}
+
+// getHostfileFormat returns the format of the hostfiles expected by the MPI implementation of a job
+func getHostfileFormat(j *job.Job) hostlist.Format {
+	if j.MPICfg == nil {
+		return hostlist.PlainFormat
+	}
+	return mpi.GetHostfileFormat(&j.MPICfg.Implem)
+}
+
+// getSlotsPerHost returns the number of ranks to start on each host, 0 if unknown
+func getSlotsPerHost(j *job.Job, nHosts int) int {
+	return j.Placement.RanksPerNodeFor(j.NP, nHosts)
+}
+
+// writeHostfile creates a hostfile for a job with a given list of hosts and returns its path
+func writeHostfile(j *job.Job, sysCfg *sys.Config, hosts []string) (string, error) {
+	f, err := ioutil.TempFile(getHostfileDir(j, sysCfg), "hostfile-"+j.Name+"-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %s", err)
+	}
+	path := f.Name()
+	f.Close()
+	addTempFile(j, path)
+
+	err = hostlist.WriteHostfile(path, hosts, getSlotsPerHost(j, len(hosts)), getHostfileFormat(j))
+	if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// writeJobHostfile creates a hostfile for a job, based on the hosts returned by getJobHosts(), and returns its path.
+// The returned path is empty if there is no host to include in a hostfile.
+func writeJobHostfile(j *job.Job, sysCfg *sys.Config) (string, error) {
+	hosts, err := getJobHosts(j)
+	if err != nil {
+		return "", err
+	}
+	if len(hosts) == 0 {
+		return "", nil
+	}
+	return writeHostfile(j, sysCfg, hosts)
+}
+
+// writeLocalHostfile creates a hostfile that only includes the local host, for launchers that cannot start ranks
+// without a hostfile (e.g., mpirun_rsh)
+func writeLocalHostfile(j *job.Job, sysCfg *sys.Config) (string, error) {
+	return writeHostfile(j, sysCfg, []string{"localhost"})
+}
+
+// getSlurmHostfileCmd returns the commands to add to a batch script to create a hostfile from the nodes allocated
+// to the job, as well as the path to the hostfile. The hosts are only known once the job starts.
+func getSlurmHostfileCmd(j *job.Job, sysCfg *sys.Config) (string, string) {
+	j.SetTimestamp()
+	path := filepath.Join(getHostfileDir(j, sysCfg), "hostfile-"+getJobOutFilenamePrefix(j))
+	cmd := "scontrol show hostnames \"$" + slurmNodeListEnvVar + "\""
+	slots := getSlotsPerHost(j, j.NNodes)
+	if slots > 0 {
+		switch getHostfileFormat(j) {
+		case hostlist.SlotsFormat:
+			cmd += fmt.Sprintf(" | sed 's/$/ slots=%d/'", slots)
+		case hostlist.ColonFormat:
+			cmd += fmt.Sprintf(" | sed 's/$/:%d/'", slots)
+		}
+	}
+	cmd += " > " + environ.Quote(path) + "\n"
+	addTempFile(j, path)
+	return cmd, path
+}
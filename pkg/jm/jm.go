@@ -11,10 +11,16 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"time"
 
 	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/network"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/environ"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/job"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/modules"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/mpi"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/sys"
 	"github.com/BTMichalowicz/go_util/pkg/util"
 )
@@ -44,6 +50,18 @@ type Environment struct {
 type JobStatus struct {
 	Code int
 	Str  string
+
+	// Reason is the reason why a queued job is not running yet, as reported by the job manager (optional)
+	Reason string
+
+	// EstimatedStart is the time at which a queued job is expected to start (zero when unknown)
+	EstimatedStart time.Time
+
+	// Priority is the priority of a queued job (0 when unknown)
+	Priority int
+
+	// QueuePosition is the position of a queued job among the queued jobs of the same partition, starting at 1 (0 when unknown)
+	QueuePosition int
 }
 
 const (
@@ -97,6 +115,22 @@ type JobStatusFn func(jobmgr *JM, jobIDs []int) ([]JobStatus, error)
 // NumJobsFn is a "function pointer" that lets us know how many jobs the job manager is currently handling
 type NumJobsFn func(jobmgr *JM, partition string, user string) (int, error)
 
+// ClusterInfoFn is a "function pointer" that lets us get the details about the partitions and nodes the job manager handles
+type ClusterInfoFn func(jobmgr *JM) (*ClusterInfo, error)
+
+// PendingDetailsFn is a "function pointer" that lets us complete the status of a queued job with why it is queued,
+// when it is expected to start and its position in the queue
+type PendingDetailsFn func(jobmgr *JM, jobID int, status *JobStatus) error
+
+// JobExitStateFn is a "function pointer" that lets us know the final state and exit code of a completed job
+type JobExitStateFn func(jobmgr *JM, j *job.Job) (string, int, error)
+
+// FollowFn is a "function pointer" that lets us follow the output of a job while it runs
+type FollowFn func(jobmgr *JM, j *job.Job, sysCfg *sys.Config) (*Output, error)
+
+// TailFn is a "function pointer" that lets us follow the output of a job known only by its ID while it runs
+type TailFn func(jobmgr *JM, jobID int) (*Output, error)
+
 // PostJobFn is a "function pointer" that lets us update results once the job completes. By default jobs are blocking, in which case this does not need to be used.
 type PostJobFn func(cmdRes *advexec.Result, j *job.Job, sysCfg *sys.Config) advexec.Result
 
@@ -113,6 +147,16 @@ type JM struct {
 
 	numJobsJM NumJobsFn
 
+	clusterInfoJM ClusterInfoFn
+
+	pendingDetailsJM PendingDetailsFn
+
+	jobExitStateJM JobExitStateFn
+
+	followJM FollowFn
+
+	tailJM TailFn
+
 	postRunJM PostJobFn
 
 	BinPath string
@@ -123,6 +167,12 @@ type JM struct {
 // Detect figures out which job manager must be used on the system and return a
 // structure that gather all the data necessary to interact with it
 func Detect() JM {
+	return DetectFromConfig(nil)
+}
+
+// DetectFromConfig figures out which job manager must be used on the system, ignoring the job managers the system
+// configuration disables
+func DetectFromConfig(sysCfg *sys.Config) JM {
 	// Default job manager
 	loaded, comp := NativeDetect()
 	if !loaded {
@@ -130,19 +180,39 @@ func Detect() JM {
 	}
 
 	// Now we check if we can find better
-	loaded, slurmComp := SlurmDetect()
-	if loaded {
-		return slurmComp
+	if !sysCfg.Backend(SlurmID).Disabled {
+		loaded, slurmComp := SlurmDetect()
+		if loaded {
+			return slurmComp
+		}
 	}
 
-	loaded, prunComp := PrunDetect()
-	if loaded {
-		return prunComp
+	if !sysCfg.Backend(PrunID).Disabled {
+		loaded, prunComp := PrunDetect()
+		if loaded {
+			return prunComp
+		}
 	}
 
 	return comp
 }
 
+// applyBackendConfig sets the values of a job that are not specified from the configuration of a job manager
+func applyBackendConfig(j *job.Job, b *sys.BackendConfig) {
+	if j.Partition == "" {
+		j.Partition = b.Partition
+	}
+	if j.Account == "" {
+		j.Account = b.Account
+	}
+	if j.MaxExecTime == "" {
+		j.MaxExecTime = b.TimeLimit
+	}
+	if j.MPICfg != nil && j.MPICfg.Launcher == "" {
+		j.MPICfg.Launcher = b.Launcher
+	}
+}
+
 func getBatchScriptPath(j *job.Job, sysCfg *sys.Config, batchScriptFilenamePrefix string) (string, error) {
 	if j.RunDir != "" {
 		return filepath.Join(j.RunDir, batchScriptFilenamePrefix+".sh"), nil
@@ -185,12 +255,181 @@ func TempFile(j *job.Job, sysCfg *sys.Config) error {
 		if err != nil {
 			return fmt.Errorf("unable to delete %s: %s", j.BatchScript, err)
 		}
+		return removeTempFiles(j)
+	}
+
+	return nil
+}
+
+// removeTempFiles deletes the files created to run a job
+func removeTempFiles(j *job.Job) error {
+	for _, path := range j.TempFiles {
+		err := os.RemoveAll(path)
+		if err != nil {
+			return fmt.Errorf("unable to delete %s: %s", path, err)
+		}
+	}
+	j.TempFiles = nil
+	return nil
+}
+
+// addTempFile records a file created to run a job so that the clean up of the job deletes it
+func addTempFile(j *job.Job, path string) {
+	j.TempFiles = append(j.TempFiles, path)
+	if j.CleanUp == nil {
+		j.CleanUp = func(...interface{}) error {
+			return removeTempFiles(j)
+		}
+	}
+}
+
+// checkMPIIntegrity makes sure the MPI installation of a job was not modified when the job requires it
+func checkMPIIntegrity(j *job.Job) error {
+	if j.MPICfg == nil || !j.MPICfg.RequireIntegrity {
+		return nil
+	}
+	err := mpi.CheckIntegrity(j.MPICfg.Implem.InstallDir)
+	if err != nil {
+		return fmt.Errorf("refusing to use %s: %w", j.MPICfg.Implem.InstallDir, err)
+	}
+	return nil
+}
+
+// loadModules checks that the modules a job requires exist and returns the environment resulting from loading them.
+// The versions of the loaded modules are recorded with the job.
+func loadModules(j *job.Job) ([]string, error) {
+	if len(j.RequiredModules) == 0 {
+		return nil, nil
+	}
+	s, err := modules.Detect(nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the required modules: %w", err)
+	}
+	err = s.Check(j.RequiredModules)
+	if err != nil {
+		return nil, err
+	}
+	env, err := s.Environment(j.RequiredModules)
+	if err != nil {
+		return nil, err
+	}
+	j.LoadedModules = modules.LoadedModules(env)
+	return env, nil
+}
+
+// setCmdEnv sets the environment of the command running a job. base is the environment the job starts from (e.g., the
+// environment with the required modules loaded), the environment of the current process if nil.
+func setCmdEnv(cmd *advexec.Advcmd, env *environ.Env, base []string) error {
+	if env.IsEmpty() {
+		cmd.Env = base
+		return nil
+	}
+	if base == nil {
+		base = os.Environ()
+	}
+	vars := env.Apply(base)
+	if !env.Clear {
+		cmd.Env = vars
 		return nil
 	}
 
+	// The environment of the command always extends the one of the current process so we clear it with env
+	envPath, err := exec.LookPath("env")
+	if err != nil {
+		return fmt.Errorf("env not found")
+	}
+	args := append([]string{"-i"}, vars...)
+	args = append(args, cmd.BinPath)
+	cmd.CmdArgs = append(args, cmd.CmdArgs...)
+	cmd.BinPath = envPath
+	cmd.Env = nil
 	return nil
 }
 
+// getRankMPICfg returns the MPI configuration of a job propagating the environment of the job to all the ranks, when
+// the launcher supports it, and handling the per-rank output of the job. The values of the environment are the ones
+// of the environment of the launcher.
+func getRankMPICfg(j *job.Job, env *environ.Env, l *mpi.Launcher) (*mpi.Config, error) {
+	cfg := *j.MPICfg
+	names := env.Names()
+	if len(names) > 0 && mpi.PropagatesEnv(j.MPICfg, l) {
+		cfg.RankEnv = append(names, j.MPICfg.RankEnv...)
+	}
+	err := setRankOutputCfg(&cfg, j)
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// getAppCmd returns the command running the application of a job, within its container if it has one
+func getAppCmd(j *job.Job) ([]string, error) {
+	if j.Container == nil {
+		return append([]string{j.App.BinPath}, j.App.BinArgs...), nil
+	}
+
+	err := j.Container.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid container: %w", err)
+	}
+	mpiDir := ""
+	libDir := ""
+	if j.MPICfg != nil {
+		mpiDir = j.MPICfg.Implem.InstallDir
+		libDir = j.MPICfg.Implem.LibDir
+	}
+	return j.Container.GetArgs(mpiDir, libDir, j.App.BinPath, j.App.BinArgs)
+}
+
+// getNetworkConfig returns the network configuration of a job. The devices of the host are only discovered when the
+// job lets the configuration be selected automatically and discover is set. Job managers running the job on other
+// nodes than the current host do not discover the devices: AutoTransport falls back to DefaultTransport when no
+// device is specified and, when a transport is specified without device, the MPI implementation selects the device on
+// the compute nodes.
+func getNetworkConfig(j *job.Job, sysCfg *sys.Config, discover bool) (*network.Config, error) {
+	netCfg := &network.Config{
+		Device:    j.Device,
+		Transport: j.Transport,
+		Provider:  j.Provider,
+	}
+	if !discover {
+		err := netCfg.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("invalid network configuration: %w", err)
+		}
+		if netCfg.Transport == network.AutoTransport {
+			if netCfg.Device == "" {
+				netCfg.Transport = network.DefaultTransport
+				return netCfg, nil
+			}
+			// The transport only depends on the specified device and provider
+			err = netCfg.Resolve(new(network.Devices))
+			if err != nil {
+				return nil, fmt.Errorf("invalid network configuration: %w", err)
+			}
+		}
+		return netCfg, nil
+	}
+
+	var devices *network.Devices
+	if netCfg.Transport == network.AutoTransport || netCfg.Transport != network.DefaultTransport && netCfg.Device == "" {
+		var err error
+		root := network.DefaultSysfsRoot
+		if sysCfg != nil && sysCfg.SysfsRoot != "" {
+			root = sysCfg.SysfsRoot
+		}
+		devices, err = network.Discover(root)
+		if err != nil {
+			return nil, fmt.Errorf("unable to discover the network devices: %w", err)
+		}
+	}
+	err := netCfg.Resolve(devices)
+	if err != nil {
+		return nil, fmt.Errorf("invalid network configuration: %w", err)
+	}
+	return netCfg, nil
+}
+
 // Load sets data specific to the job managers that was previously detected
 func (jobmgr *JM) Load(sysCfg *sys.Config) error {
 	return jobmgr.loadJM(jobmgr, sysCfg)
@@ -198,6 +437,7 @@ func (jobmgr *JM) Load(sysCfg *sys.Config) error {
 
 // Submit executes a job with a job manager that was previously detected and loaded
 func (jobmgr *JM) Submit(j *job.Job, sysCfg *sys.Config) advexec.Result {
+	applyBackendConfig(j, sysCfg.Backend(jobmgr.ID))
 	return jobmgr.submitJM(j, jobmgr, sysCfg)
 }
 
@@ -215,6 +455,49 @@ func (jobmgr *JM) NumJobs(partition string, user string) (int, error) {
 	return jobmgr.numJobsJM(jobmgr, partition, user)
 }
 
+// ClusterInfo returns the details about the partitions and nodes handled by the job manager
+func (jobmgr *JM) ClusterInfo() (*ClusterInfo, error) {
+	if jobmgr.clusterInfoJM == nil {
+		return nil, fmt.Errorf("not implemented")
+	}
+	return jobmgr.clusterInfoJM(jobmgr)
+}
+
+// PendingDetails completes the status of a queued job with the reason why it is queued, its estimated start time,
+// its priority and its position in the queue. The details are costly to get so JobStatus does not include them.
+func (jobmgr *JM) PendingDetails(jobID int, status *JobStatus) error {
+	if jobmgr.pendingDetailsJM == nil {
+		return fmt.Errorf("not implemented")
+	}
+	return jobmgr.pendingDetailsJM(jobmgr, jobID, status)
+}
+
+// JobExitState returns the final state (e.g., COMPLETED, FAILED, NODE_FAIL) and exit code of a completed job
+func (jobmgr *JM) JobExitState(j *job.Job) (string, int, error) {
+	if jobmgr.jobExitStateJM == nil {
+		return "", -1, fmt.Errorf("not implemented")
+	}
+	return jobmgr.jobExitStateJM(jobmgr, j)
+}
+
+// Follow gives access to the output of a job while it runs. With job managers running jobs locally, it must be called
+// before submitting the job, and the readers get io.EOF once CloseOutput is called; with batch systems, it must be
+// called once the job is submitted.
+func (jobmgr *JM) Follow(j *job.Job, sysCfg *sys.Config) (*Output, error) {
+	if jobmgr.followJM == nil {
+		return nil, fmt.Errorf("not implemented")
+	}
+	return jobmgr.followJM(jobmgr, j, sysCfg)
+}
+
+// Tail gives access to the output of a job, identified by its ID, while it runs
+func (jobmgr *JM) Tail(jobID int) (*Output, error) {
+	if jobmgr.tailJM == nil {
+		return nil, fmt.Errorf("not implemented")
+	}
+	return jobmgr.tailJM(jobmgr, jobID)
+}
+
 func (jobmgr *JM) PostRun(cmdRes *advexec.Result, j *job.Job, sysCfg *sys.Config) advexec.Result {
 	var res advexec.Result
 	if jobmgr.postRunJM == nil {
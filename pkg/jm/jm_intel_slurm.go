@@ -43,7 +43,12 @@ func IntelSlurmDetect() (bool, JM) {
 	jm.loadJM = intelSlurmLoad
 	jm.jobStatusJM = slurmJobStatus
 	jm.numJobsJM = slurmGetNumJobs
+	jm.clusterInfoJM = slurmClusterInfo
+	jm.pendingDetailsJM = slurmPendingDetails
+	jm.jobExitStateJM = slurmJobExitState
 	jm.postRunJM = slurmPostJob
+	jm.followJM = slurmFollow
+	jm.tailJM = slurmTail
 
 	return true, jm
 }
@@ -79,16 +84,7 @@ func intelSlurmSubmit(j *job.Job, jobmgr *JM, sysCfg *sys.Config) advexec.Result
 
 	cmd.BinPath = jobmgr.BinPath
 	cmd.ExecDir = j.RunDir
-	// We want the default to be blocking sbatch but users can request non-blocking
-	if !j.NonBlocking {
-		jobmgr.CmdArgs = append(jobmgr.CmdArgs, "-W")
-	}
-
-	if len(jobmgr.CmdArgs) > 0 {
-		cmd.CmdArgs = append(cmd.CmdArgs, jobmgr.CmdArgs...)
-	}
-	//cmd.CmdArgs = append(cmd.CmdArgs, j.BatchScript)
-	cmd.CmdArgs = []string{j.BatchScript}
+	cmd.CmdArgs = getSbatchArgs(j, jobmgr)
 
 	j.SetOutputFn(slurmGetOutput)
 	j.SetErrorFn(slurmGetError)
@@ -98,7 +94,13 @@ func intelSlurmSubmit(j *job.Job, jobmgr *JM, sysCfg *sys.Config) advexec.Result
 		return resExec
 	}
 
+	j.Command = append([]string{cmd.BinPath}, cmd.CmdArgs...)
 	cmdRes := cmd.Run()
+	if cmdRes.Err != nil && !strings.HasPrefix(cmdRes.Stdout, slurmJobIDPrefix) {
+		// The job was not submitted, e.g., because of a limit set by the site
+		cmdRes.Err = newSubmitError(cmdRes.Err, cmdRes.Stderr)
+		return cmdRes
+	}
 	if strings.HasPrefix(cmdRes.Stdout, slurmJobIDPrefix) {
 		jobIDStr := strings.TrimPrefix(cmdRes.Stdout, slurmJobIDPrefix)
 		jobIDStr = strings.TrimRight(jobIDStr, "\n")
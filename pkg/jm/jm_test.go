@@ -7,9 +7,13 @@
 package jm
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/network"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/job"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/mpi"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/sys"
 	"github.com/BTMichalowicz/go_util/pkg/util"
 )
@@ -41,3 +45,71 @@ func TestTempFile(t *testing.T) {
 		t.Fatalf("temporary file %s still exists even after cleanup", j.BatchScript)
 	}
 }
+
+func TestApplyBackendConfig(t *testing.T) {
+	j := job.Job{MaxExecTime: "0:10:00", MPICfg: new(mpi.Config)}
+	applyBackendConfig(&j, &sys.BackendConfig{Partition: "debug", Account: "proj01", TimeLimit: "1:00:00", Launcher: "mpiexec.hydra"})
+	if j.Partition != "debug" || j.Account != "proj01" || j.MPICfg.Launcher != "mpiexec.hydra" {
+		t.Fatalf("applyBackendConfig() did not set the job's values: %s, %s, %s", j.Partition, j.Account, j.MPICfg.Launcher)
+	}
+	if j.MaxExecTime != "0:10:00" {
+		t.Fatalf("applyBackendConfig() overwrote the job's time limit with %s", j.MaxExecTime)
+	}
+}
+
+func TestHostfileCleanUp(t *testing.T) {
+	var j job.Job
+	var sysCfg sys.Config
+	var err error
+	j.Name = "hostfile"
+	j.NP = 2
+	sysCfg.ScratchDir, err = ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(sysCfg.ScratchDir)
+
+	path, err := writeLocalHostfile(&j, &sysCfg)
+	if err != nil {
+		t.Fatalf("writeLocalHostfile() failed: %s", err)
+	}
+	if j.CleanUp == nil {
+		t.Fatalf("the hostfile is not recorded for the clean up of the job")
+	}
+	err = j.CleanUp()
+	if err != nil {
+		t.Fatalf("failed to clean up: %s", err)
+	}
+	if util.PathExists(path) {
+		t.Fatalf("hostfile %s still exists even after cleanup", path)
+	}
+}
+
+func TestGetNetworkConfigNoDiscovery(t *testing.T) {
+	tests := []struct {
+		name              string
+		transport         string
+		device            string
+		provider          string
+		expectedTransport string
+		expectedDevice    string
+	}{
+		{name: "auto", transport: network.AutoTransport, expectedTransport: network.DefaultTransport},
+		{name: "auto with device", transport: network.AutoTransport, device: "mlx5_1:1", expectedTransport: network.UCXTransport, expectedDevice: "mlx5_1:1"},
+		{name: "ucx", transport: network.UCXTransport, expectedTransport: network.UCXTransport},
+		{name: "tcp with device", transport: network.TCPTransport, device: "eth1", expectedTransport: network.TCPTransport, expectedDevice: "eth1"},
+	}
+
+	// Discovering the devices from this root would fail
+	sysCfg := sys.Config{SysfsRoot: "/nonexistent"}
+	for _, tt := range tests {
+		j := job.Job{Transport: tt.transport, Device: tt.device, Provider: tt.provider}
+		netCfg, err := getNetworkConfig(&j, &sysCfg, false)
+		if err != nil {
+			t.Fatalf("%s: getNetworkConfig() failed: %s", tt.name, err)
+		}
+		if netCfg.Transport != tt.expectedTransport || netCfg.Device != tt.expectedDevice {
+			t.Fatalf("%s: getNetworkConfig() returned %q/%q instead of %q/%q", tt.name, netCfg.Transport, netCfg.Device, tt.expectedTransport, tt.expectedDevice)
+		}
+	}
+}
@@ -8,11 +8,12 @@ package jm
 
 import (
 	"fmt"
-	"path/filepath"
 	"strconv"
 
 	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/mvapich2"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/network"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/environ"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/job"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/mpi"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/sys"
@@ -32,21 +33,55 @@ func nativeGetError(j *job.Job, sysCfg *sys.Config) string {
 	return j.ErrBuffer.String()
 }
 
-func prepareMPISubmit(cmd *advexec.Advcmd, j *job.Job, sysCfg *sys.Config, netCfg *network.Config) error {
-	var err error
-	cmd.BinPath = filepath.Join(j.MPICfg.Implem.InstallDir, "bin", "mpirun")
+func prepareMPISubmit(cmd *advexec.Advcmd, j *job.Job, sysCfg *sys.Config, netCfg *network.Config, env *environ.Env) error {
+	err := j.Placement.Validate(j.NP, j.NNodes)
+	if err != nil {
+		return fmt.Errorf("invalid placement: %s", err)
+	}
+
+	err = checkMPIIntegrity(j)
+	if err != nil {
+		return err
+	}
+
+	launcher, err := mpi.GetLauncher(j.MPICfg)
+	if err != nil {
+		return fmt.Errorf("unable to get the MPI launcher: %s", err)
+	}
+	cmd.BinPath = launcher.Path
 	if j.NP > 0 {
-		cmd.CmdArgs = append(cmd.CmdArgs, "-np")
+		cmd.CmdArgs = append(cmd.CmdArgs, launcher.NPFlag)
 		cmd.CmdArgs = append(cmd.CmdArgs, strconv.Itoa(j.NP))
 	}
 
-	mpirunArgs, err := mpi.GetMpirunArgs(&j.MPICfg.Implem, &j.App, sysCfg, netCfg, j.MPICfg.UserMpirunArgs)
+	hostfile := j.MPICfg.Hostfile
+	if hostfile == "" {
+		hostfile, err = writeJobHostfile(j, sysCfg)
+		if err != nil {
+			return fmt.Errorf("unable to create hostfile: %s", err)
+		}
+	}
+	if hostfile == "" && launcher.Name == mvapich2.RshLauncher {
+		hostfile, err = writeLocalHostfile(j, sysCfg)
+		if err != nil {
+			return fmt.Errorf("unable to create hostfile: %s", err)
+		}
+	}
+
+	rankCfg, err := getRankMPICfg(j, env, launcher)
+	if err != nil {
+		return err
+	}
+	launchArgs, err := mpi.GetLaunchArgs(rankCfg, launcher, j.Placement, &j.App, sysCfg, netCfg, hostfile)
 	if err != nil {
 		return fmt.Errorf("unable to get mpirun arguments: %s", err)
 	}
-	if len(mpirunArgs) > 0 {
-		cmd.CmdArgs = append(cmd.CmdArgs, mpirunArgs...)
+	appCmd, err := getAppCmd(j)
+	if err != nil {
+		return err
 	}
+	cmd.CmdArgs = append(cmd.CmdArgs, launchArgs...)
+	cmd.CmdArgs = append(cmd.CmdArgs, appCmd...)
 
 	//newPath := getEnvPath(j.HostCfg, env)
 	//newLDPath := getEnvLDPath(j.HostCfg, env)
@@ -79,22 +114,49 @@ func nativeSubmit(j *job.Job, jobmgr *JM, sysCfg *sys.Config) advexec.Result {
 		return res
 	}
 
-	netCfg := new(network.Config)
-	netCfg.Device = j.Device
+	netCfg, err := getNetworkConfig(j, sysCfg, true)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	env, err := j.Environment()
+	if err != nil {
+		res.Err = err
+		return res
+	}
 
-	err := prepareMPISubmit(&cmd, j, sysCfg, netCfg)
+	moduleEnv, err := loadModules(j)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	err = prepareMPISubmit(&cmd, j, sysCfg, netCfg, env)
 	if err != nil {
 		res.Err = fmt.Errorf("unable to prepare MPI job: %s", err)
 		return res
 	}
 
+	err = setCmdEnv(&cmd, env, moduleEnv)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
 	j.SetOutputFn(nativeGetOutput)
 	j.SetErrorFn(nativeGetError)
 
 	if j.RunDir != "" {
 		cmd.ExecDir = j.RunDir
 	}
-	return cmd.Run()
+	j.Command = append([]string{cmd.BinPath}, cmd.CmdArgs...)
+	res = runJobCmd(&cmd, j)
+	err = setRankOutput(j, &res)
+	if err != nil && res.Err == nil {
+		res.Err = err
+	}
+	return res
 }
 
 func nativeLoad(jobmgr *JM, sysCfg *sys.Config) error {
@@ -111,6 +173,7 @@ func NativeDetect() (bool, JM) {
 	jm.loadJM = nativeLoad
 	jm.jobStatusJM = nil // Not implemented yet
 	jm.postRunJM = nil   // Not implemented yet
+	jm.followJM = localFollow
 
 	// This is the default job manager, i.e., mpirun so we do not check anything, just return this component.
 	// If the component is selected and mpirun not correctly installed, the framework will pick it up later.
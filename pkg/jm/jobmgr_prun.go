@@ -41,20 +41,49 @@ func PrunSubmit(j *job.Job, jobmgr *JM, sysCfg *sys.Config) advexec.Result {
 		return res
 	}
 
+	if j.RankOutputMode != "" {
+		res.Err = fmt.Errorf("per-rank output is not supported with prun")
+		return res
+	}
+
 	cmd.BinPath, err = exec.LookPath("prun")
 	if err != nil {
 		res.Err = fmt.Errorf("prun not found")
 		return res
 	}
 
+	env, err := j.Environment()
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	moduleEnv, err := loadModules(j)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
 	cmd.CmdArgs = append(cmd.CmdArgs, j.Args...)
 	cmd.CmdArgs = append(cmd.CmdArgs, "-x")
 	cmd.CmdArgs = append(cmd.CmdArgs, "PATH")
-	cmd.CmdArgs = append(cmd.CmdArgs, j.App.BinPath)
-	cmd.CmdArgs = append(cmd.CmdArgs, j.App.BinArgs...)
+	for _, name := range env.Names() {
+		cmd.CmdArgs = append(cmd.CmdArgs, "-x", name)
+	}
+	appCmd, err := getAppCmd(j)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	cmd.CmdArgs = append(cmd.CmdArgs, appCmd...)
 	if j.RunDir != "" {
 		cmd.ExecDir = j.RunDir
 	}
+	err = setCmdEnv(&cmd, env, moduleEnv)
+	if err != nil {
+		res.Err = err
+		return res
+	}
 
 	//newPath := getEnvPath(j.HostCfg, env)
 	//newLDPath := getEnvLDPath(j.HostCfg, env)
@@ -67,7 +96,8 @@ func PrunSubmit(j *job.Job, jobmgr *JM, sysCfg *sys.Config) advexec.Result {
 
 	j.SetOutputFn(prunGetOutput)
 	j.SetErrorFn(prunGetError)
-	return cmd.Run()
+	j.Command = append([]string{cmd.BinPath}, cmd.CmdArgs...)
+	return runJobCmd(&cmd, j)
 }
 
 // PrunDetect is the function used by our job management framework to figure out if mpirun should be used directly.
@@ -86,6 +116,7 @@ func PrunDetect() (bool, JM) {
 	jm.submitJM = PrunSubmit
 	jm.jobStatusJM = nil // Not implemented yet
 	jm.postRunJM = nil   // Not implemented yet
+	jm.followJM = localFollow
 
 	// This is the default job manager, i.e., mpirun so we do not check anything, just return this component.
 	// If the component is selected and mpirun not correctly installed, the framework will pick it up later.
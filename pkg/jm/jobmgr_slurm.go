@@ -15,20 +15,42 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/BTMichalowicz/go_exec/pkg/advexec"
-	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/network"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/mvapich2"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/openmpi"
-    "github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/mvapich2"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/slurm"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/environ"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/job"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/modules"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/mpi"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/placement"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/spack"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/sys"
 	"github.com/BTMichalowicz/go_util/pkg/util"
 )
 
 const (
 	slurmJobIDPrefix = "Submitted batch job "
+
+	// slurmTimeFormat is the format used by Slurm to display dates
+	slurmTimeFormat = "2006-01-02T15:04:05"
+
+	// squeueStartFormat is the format used with 'squeue --start' to get the details of a pending job
+	squeueStartFormat = "%i|%P|%S|%r"
+
+	// sprioFormat is the format used with sprio to get the priority of a job
+	sprioFormat = "%i|%Y"
+
+	// squeuePriorityFormat is the format used with squeue to get the priority of a job when sprio is not available
+	squeuePriorityFormat = "%i|%Q"
+
+	// squeueQueueFormat is the format used with squeue to list the jobs of a queue
+	squeueQueueFormat = "%i"
+
+	// sacctExitStateFormat is the format used with sacct to get the final state and exit code of a job
+	sacctExitStateFormat = "State,ExitCode"
 )
 
 func removeFromSlice(a []string, idx int) []string {
@@ -91,6 +113,138 @@ func getSlurmJobStatus(jobID int) (JobStatus, error) {
 	return StatusUnknown, nil
 }
 
+// parseSqueueStartOutput parses the output of squeue used with squeueStartFormat and returns the partition, the
+// estimated start time (zero if unknown) and the pending reason of the job
+func parseSqueueStartOutput(output string) (string, time.Time, string, error) {
+	var start time.Time
+	line := strings.TrimSpace(output)
+	tokens := strings.Split(line, "|")
+	if len(tokens) != 4 {
+		return "", start, "", fmt.Errorf("invalid format: %s", line)
+	}
+	if tokens[2] != "N/A" && tokens[2] != "" {
+		var err error
+		start, err = time.ParseInLocation(slurmTimeFormat, tokens[2], time.Local)
+		if err != nil {
+			return "", start, "", fmt.Errorf("invalid start time %s: %w", tokens[2], err)
+		}
+	}
+	return tokens[1], start, tokens[3], nil
+}
+
+// parseSprioOutput parses the output of sprio used with sprioFormat and returns the priority of a job
+func parseSprioOutput(output string, jobID int) (int, error) {
+	for _, line := range strings.Split(output, "\n") {
+		tokens := strings.Split(strings.TrimSpace(line), "|")
+		if len(tokens) != 2 || tokens[0] != strconv.Itoa(jobID) {
+			continue
+		}
+		return strconv.Atoi(tokens[1])
+	}
+	return 0, fmt.Errorf("job %d not found", jobID)
+}
+
+// parseQueuePosition parses the output of squeue used with squeueQueueFormat, i.e., the queued jobs of a partition
+// sorted by decreasing priority, and returns the position of a job in the queue, starting at 1
+func parseQueuePosition(output string, jobID int) (int, error) {
+	pos := 0
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		pos++
+		if line == strconv.Itoa(jobID) {
+			return pos, nil
+		}
+	}
+	return 0, fmt.Errorf("job %d not found", jobID)
+}
+
+// getSlurmJobPriority returns the priority of a pending job
+func getSlurmJobPriority(jobID int) (int, error) {
+	output, err := runSlurmCmd("sprio", "-h", "-j", strconv.Itoa(jobID), "-o", sprioFormat)
+	if err == nil {
+		var priority int
+		priority, err = parseSprioOutput(output, jobID)
+		if err == nil {
+			return priority, nil
+		}
+	}
+
+	// sprio is not available when the priority/basic plugin is used, and does not report jobs that are not
+	// waiting for resources (e.g., held jobs), in which cases squeue gives the priority
+	output, err = runSlurmCmd("squeue", "-h", "-j", strconv.Itoa(jobID), "-o", squeuePriorityFormat)
+	if err != nil {
+		return 0, err
+	}
+	return parseSprioOutput(output, jobID)
+}
+
+// slurmPendingDetails completes the status of a pending job with the pending reason, estimated start time, priority
+// and position in the queue
+func slurmPendingDetails(jobmgr *JM, jobID int, status *JobStatus) error {
+	output, err := runSlurmCmd("squeue", "-h", "--start", "-j", strconv.Itoa(jobID), "-o", squeueStartFormat)
+	if err != nil {
+		return err
+	}
+	partition, start, reason, err := parseSqueueStartOutput(output)
+	if err != nil {
+		return err
+	}
+	status.EstimatedStart = start
+	status.Reason = reason
+
+	status.Priority, err = getSlurmJobPriority(jobID)
+	if err != nil {
+		return err
+	}
+
+	output, err = runSlurmCmd("squeue", "-h", "-t", "PD", "-p", partition, "--sort=-p,i", "-o", squeueQueueFormat)
+	if err != nil {
+		return err
+	}
+	status.QueuePosition, err = parseQueuePosition(output, jobID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// parseSacctExitState parses the output of sacct used with sacctExitStateFormat and returns the final state and exit
+// code of a job
+func parseSacctExitState(output string) (string, int, error) {
+	line := strings.TrimSpace(strings.Split(strings.TrimSpace(output), "\n")[0])
+	tokens := strings.Split(line, "|")
+	if len(tokens) != 2 {
+		return "", -1, fmt.Errorf("invalid format: %s", line)
+	}
+	// The state can include details, e.g., "CANCELLED by 1234"
+	state := strings.Fields(tokens[0])
+	if len(state) == 0 {
+		return "", -1, fmt.Errorf("invalid format: %s", line)
+	}
+	// The exit code is reported as <exit code>:<signal>
+	exitCode, err := strconv.Atoi(strings.Split(tokens[1], ":")[0])
+	if err != nil {
+		return "", -1, fmt.Errorf("invalid exit code %s: %w", tokens[1], err)
+	}
+	return state[0], exitCode, nil
+}
+
+// slurmJobExitState gets the final state and exit code of a completed job from sacct
+func slurmJobExitState(jobmgr *JM, j *job.Job) (string, int, error) {
+	if j.ID == 0 {
+		return "", -1, fmt.Errorf("undefined job ID")
+	}
+	output, err := runSlurmCmd("sacct", "-j", strconv.Itoa(j.ID), "-X", "-n", "-P", "-o", sacctExitStateFormat)
+	if err != nil {
+		return "", -1, err
+	}
+	return parseSacctExitState(output)
+}
+
 func slurmGetNumJobs(jobmgr *JM, partitionName string, user string) (int, error) {
 	var cmd advexec.Advcmd
 	var err error
@@ -98,7 +252,10 @@ func slurmGetNumJobs(jobmgr *JM, partitionName string, user string) (int, error)
 	if err != nil {
 		return -1, err
 	}
-	cmd.CmdArgs = []string{"-p", partitionName, "-u", user}
+	cmd.CmdArgs = []string{"-u", user}
+	if partitionName != "" {
+		cmd.CmdArgs = append(cmd.CmdArgs, "-p", partitionName)
+	}
 	res := cmd.Run()
 	if res.Err != nil {
 		return -1, res.Err
@@ -151,7 +308,12 @@ func SlurmDetect() (bool, JM) {
 	jm.loadJM = slurmLoad
 	jm.jobStatusJM = slurmJobStatus
 	jm.numJobsJM = slurmGetNumJobs
+	jm.clusterInfoJM = slurmClusterInfo
+	jm.pendingDetailsJM = slurmPendingDetails
+	jm.jobExitStateJM = slurmJobExitState
 	jm.postRunJM = slurmPostJob
+	jm.followJM = slurmFollow
+	jm.tailJM = slurmTail
 
 	return true, jm
 }
@@ -188,18 +350,57 @@ func getJobOutFilenamePrefix(j *job.Job) string {
 	if j.ExecutionTimestamp == "" {
 		return ""
 	}
+	prefix := j.Name + "-" + j.ExecutionTimestamp
 	if j.MPICfg != nil && j.MPICfg.Implem.ID != "" {
-		return j.Name + "-" + j.ExecutionTimestamp + "-" + j.MPICfg.Implem.ID + j.MPICfg.Implem.Version
+		prefix += "-" + j.MPICfg.Implem.ID + j.MPICfg.Implem.Version
 	}
-	return j.Name + "-" + j.ExecutionTimestamp
+	// Each new attempt at running the job gets its own output files
+	if j.Attempt > 1 {
+		prefix += fmt.Sprintf("-attempt%d", j.Attempt)
+	}
+	return prefix
+}
+
+// getJobOutputDir returns the directory where Slurm writes the output files of a job: its artifact directory when it
+// has one, otherwise the directory the job runs from
+func getJobOutputDir(j *job.Job) string {
+	if j.ArtifactDir != "" {
+		return j.ArtifactDir
+	}
+	dir := j.RunDir
+	if dir == "" {
+		// sbatch runs from the current directory
+		dir = "."
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return dir
+	}
+	return absDir
 }
 
 func getJobOutputFilePath(j *job.Job, sysCfg *sys.Config) string {
-	return getJobOutFilenamePrefix(j) + ".out"
+	return filepath.Join(getJobOutputDir(j), getJobOutFilenamePrefix(j)+".out")
 }
 
 func getJobErrorFilePath(j *job.Job, sysCfg *sys.Config) string {
-	return getJobOutFilenamePrefix(j) + ".err"
+	return filepath.Join(getJobOutputDir(j), getJobOutFilenamePrefix(j)+".err")
+}
+
+// getJobModulesFilePath returns the path to the file where the batch script of a job records the modules it loaded
+func getJobModulesFilePath(j *job.Job, sysCfg *sys.Config) string {
+	return filepath.Join(getJobOutputDir(j), getJobOutFilenamePrefix(j)+".modules")
+}
+
+// setLoadedModules sets the modules that the batch script of a job loaded on the compute node
+func setLoadedModules(j *job.Job, sysCfg *sys.Config) {
+	path := getJobModulesFilePath(j, sysCfg)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Printf("unable to get the modules loaded by job %s: %s", j.Name, err)
+		return
+	}
+	j.LoadedModules = modules.ParseLoadedModules(string(content))
 }
 
 func generateBatchScriptContent(j *job.Job, sysCfg *sys.Config) (string, error) {
@@ -208,17 +409,30 @@ func generateBatchScriptContent(j *job.Job, sysCfg *sys.Config) (string, error)
 		return "", fmt.Errorf("batch script path is undefined")
 	}
 
+	env, err := j.Environment()
+	if err != nil {
+		return "", err
+	}
+
 	scriptText := "#!/bin/bash -l\n#\n"
 	if j.Partition != "" {
 		scriptText += slurm.ScriptCmdPrefix + " -p " + j.Partition + "\n"
 	}
 
+	if j.Account != "" {
+		scriptText += slurm.ScriptCmdPrefix + " -A " + j.Account + "\n"
+	}
+
 	if j.NNodes > 0 {
 		scriptText += slurm.ScriptCmdPrefix + " -N " + strconv.Itoa(j.NNodes) + "\n"
 	}
 
+	for _, opt := range slurm.GetPlacementOptions(j.Placement) {
+		scriptText += slurm.ScriptCmdPrefix + " " + opt + "\n"
+	}
+
 	if j.MaxExecTime == "" {
-		scriptText += slurm.ScriptCmdPrefix + " -t 0:30:0\n"
+		scriptText += slurm.ScriptCmdPrefix + " -t " + slurm.DefaultTimeLimit + "\n"
 	} else {
 		scriptText += slurm.ScriptCmdPrefix + " -t " + j.MaxExecTime + "\n"
 	}
@@ -229,71 +443,181 @@ func generateBatchScriptContent(j *job.Job, sysCfg *sys.Config) (string, error)
 		}
 	*/
 
+	if j.Retry != nil && j.Retry.Requeue {
+		// Outputs of the job are preserved when Slurm requeues the job
+		scriptText += slurm.ScriptCmdPrefix + " --requeue\n"
+		scriptText += slurm.ScriptCmdPrefix + " --open-mode=append\n"
+	}
+
+	if env.Clear {
+		// Only the inherited variables are exported from the submitting environment
+		exports := "NONE"
+		if names := env.InheritedNames(); len(names) > 0 {
+			exports = strings.Join(names, ",")
+		}
+		scriptText += slurm.ScriptCmdPrefix + " --export=" + exports + "\n"
+	}
+
 	j.SetTimestamp()
 	scriptText += slurm.ScriptCmdPrefix + " --error=" + getJobErrorFilePath(j, sysCfg) + "\n"
 	scriptText += slurm.ScriptCmdPrefix + " --output=" + getJobOutputFilePath(j, sysCfg) + "\n"
 	scriptText += "\n"
 
 	if len(j.RequiredModules) > 0 {
-		scriptText += "\nmodule purge\nmodule load " + strings.Join(j.RequiredModules, " ") + "\n"
+		// The modules are loaded on the compute nodes, whose modules may not be available on the submitting host
+		s, err := modules.Detect(nil)
+		if err == nil {
+			err = s.Check(j.RequiredModules)
+		}
+		if err != nil {
+			log.Printf("unable to check the modules of job %s: %s", j.Name, err)
+		}
+		modulesFile := getJobModulesFilePath(j, sysCfg)
+		scriptText += "\n" + modules.ScriptCmds(j.RequiredModules)
+		scriptText += "echo \"$" + modules.LoadedModulesVar + "\" > " + environ.Quote(modulesFile) + "\n"
+		addTempFile(j, modulesFile)
 	}
 
-	if j.CustomEnv != nil {
-		for envvar, val := range j.CustomEnv {
-			scriptText += fmt.Sprintf("export %s=%s\n", envvar, val)
-		}
+	spackCmds, err := getSpackScriptCmds(j)
+	if err != nil {
+		return "", err
 	}
+	scriptText += spackCmds
+
+	if env.Clear {
+		// srun inherits the --export setting of the batch job, the tasks must get the environment of the script
+		scriptText += "export SLURM_EXPORT_ENV=ALL\n"
+	}
+	scriptText += env.ScriptCmds()
 
 	return scriptText, nil
 }
 
+// usesSpackMPI checks whether the batch script of a job loads its MPI implementation with Spack
+func usesSpackMPI(j *job.Job) bool {
+	return j.SpackLoad && j.MPICfg != nil && j.MPICfg.Implem.SpackHash != ""
+}
+
+// getSpackScriptCmds returns the commands loading the MPI implementation and the application of a job with Spack,
+// when the job requires it
+func getSpackScriptCmds(j *job.Job) (string, error) {
+	if !j.SpackLoad {
+		return "", nil
+	}
+	var hashes []string
+	if usesSpackMPI(j) {
+		hashes = append(hashes, j.MPICfg.Implem.SpackHash)
+	}
+	if j.App.SpackHash != "" {
+		hashes = append(hashes, j.App.SpackHash)
+	}
+	if len(hashes) == 0 {
+		return "", nil
+	}
+	s, err := spack.Detect()
+	if err != nil {
+		return "", fmt.Errorf("unable to load packages with Spack: %w", err)
+	}
+	return "\n" + s.ScriptCmds(hashes), nil
+}
+
+// getJobPlacement returns the placement of the ranks of a job. Without explicit placement, Open MPI jobs spread
+// their ranks evenly across the nodes and bind them to cores.
+func getJobPlacement(j *job.Job) *placement.Spec {
+	if !j.Placement.IsEmpty() || j.MPICfg == nil || j.MPICfg.Implem.ID != openmpi.ID {
+		return j.Placement
+	}
+	rpn := j.Placement.RanksPerNodeFor(j.NP, j.NNodes)
+	if rpn == 0 {
+		return j.Placement
+	}
+	return &placement.Spec{RanksPerNode: rpn, BindTo: placement.Core}
+}
+
+// getScriptCmd returns a command line of a batch script, quoting the arguments that the shell would interpret
+func getScriptCmd(args []string) string {
+	var quoted []string
+	for _, a := range args {
+		quoted = append(quoted, environ.Quote(a))
+	}
+	return strings.Join(quoted, " ")
+}
+
 func setupMpiJob(j *job.Job, sysCfg *sys.Config) error {
 	scriptText, err := generateBatchScriptContent(j, sysCfg)
 	if err != nil {
 		return err
 	}
 
-	netCfg := new(network.Config)
-	netCfg.Device = j.Device
-
-	if j.CustomEnv != nil {
-		for envvar, val := range j.CustomEnv {
-			scriptText += fmt.Sprintf("export %s=%s\n", envvar, val)
-		}
+	netCfg, err := getNetworkConfig(j, sysCfg, false)
+	if err != nil {
+		return err
 	}
 
 	// Add the mpirun command
-	if j.MPICfg != nil && len(j.RequiredModules) == 0 {
+	if j.MPICfg != nil && len(j.RequiredModules) == 0 && !usesSpackMPI(j) {
+		libDir := "$MPI_DIR/lib"
+		if j.MPICfg.Implem.LibDir != "" {
+			libDir = j.MPICfg.Implem.LibDir
+		}
 		scriptText += "\nMPI_DIR=" + j.MPICfg.Implem.InstallDir + "\n"
 		scriptText += "export PATH=$MPI_DIR/bin:$PATH\n"
-		scriptText += "export LD_LIBRARY_PATH=$MPI_DIR/lib:$LD_LIBRARY_PATH\n\n"
+		scriptText += "export LD_LIBRARY_PATH=" + libDir + ":$LD_LIBRARY_PATH\n\n"
 	}
-	mpirunArgs, errMpiArgs := mpi.GetMpirunArgs(&j.MPICfg.Implem, &j.App, sysCfg, netCfg, j.MPICfg.UserMpirunArgs)
-	if errMpiArgs != nil {
-		return fmt.Errorf("unable to get mpirun arguments: %s", err)
+	err = j.Placement.Validate(j.NP, j.NNodes)
+	if err != nil {
+		return fmt.Errorf("invalid placement: %s", err)
 	}
 
-    if j.MPICfg.Implem.ID == mvapich2.ID {
-        scriptText += "\nwhich mpirun_rsh\n"
-        scriptText += "\nmpirun_rsh "
-    } else {
-        scriptText += "\nwhich mpirun\n"
+	err = checkMPIIntegrity(j)
+	if err != nil {
+		return err
+	}
 
-        scriptText += "\nmpirun "
-    }
-	if j.NP > 0 {
-		scriptText += fmt.Sprintf("-np %d ", j.NP)
+	launcher, err := mpi.GetLauncher(j.MPICfg)
+	if err != nil {
+		return fmt.Errorf("unable to get the MPI launcher: %s", err)
 	}
-	// todo: this should really be in the openmpi package
-	if j.MPICfg.Implem.ID == openmpi.ID {
-		ppr := j.NP / j.NNodes
-		scriptText += fmt.Sprintf("--map-by ppr:%d:node -rank-by core -bind-to core", ppr)
+
+	hostfile := j.MPICfg.Hostfile
+	// mpirun_rsh does not get the list of hosts from Slurm
+	if hostfile == "" && launcher.Name == mvapich2.RshLauncher {
+		if j.HostList != "" {
+			hostfile, err = writeJobHostfile(j, sysCfg)
+			if err != nil {
+				return fmt.Errorf("unable to create hostfile: %s", err)
+			}
+		} else {
+			var hostfileCmd string
+			hostfileCmd, hostfile = getSlurmHostfileCmd(j, sysCfg)
+			scriptText += "\n" + hostfileCmd
+		}
 	}
-	scriptText += " " + strings.Join(mpirunArgs, " ") + " " + j.App.BinPath
-	if len(j.App.BinArgs) > 0 {
-		scriptText += " " + strings.Join(j.App.BinArgs, " ")
+
+	appCmd, err := getAppCmd(j)
+	if err != nil {
+		return err
 	}
-	scriptText += "\n"
+
+	env, err := j.Environment()
+	if err != nil {
+		return err
+	}
+	rankCfg, err := getRankMPICfg(j, env, launcher)
+	if err != nil {
+		return err
+	}
+	launchArgs, errMpiArgs := mpi.GetLaunchArgs(rankCfg, launcher, getJobPlacement(j), &j.App, sysCfg, netCfg, hostfile)
+	if errMpiArgs != nil {
+		return fmt.Errorf("unable to get mpirun arguments: %s", errMpiArgs)
+	}
+
+	scriptText += "\nwhich " + launcher.Name + "\n"
+	scriptText += "\n" + launcher.Name + " "
+	if j.NP > 0 {
+		scriptText += fmt.Sprintf("%s %d ", launcher.NPFlag, j.NP)
+	}
+	scriptText += getScriptCmd(append(launchArgs, appCmd...)) + "\n"
 
 	err = ioutil.WriteFile(j.BatchScript, []byte(scriptText), 0644)
 	if err != nil {
@@ -309,11 +633,18 @@ func setupNonMpiJob(j *job.Job, sysCfg *sys.Config) error {
 	if j.BatchScript == "" {
 		return fmt.Errorf("undefined job script path")
 	}
+	if j.RankOutputMode != "" {
+		return fmt.Errorf("per-rank output requires an MPI job")
+	}
 	scriptText, err := generateBatchScriptContent(j, sysCfg)
 	if err != nil {
 		return err
 	}
-	scriptText += "\n" + j.App.BinPath + "\n"
+	appCmd, err := getAppCmd(j)
+	if err != nil {
+		return err
+	}
+	scriptText += "\n" + getScriptCmd(appCmd) + "\n"
 
 	err = ioutil.WriteFile(j.BatchScript, []byte(scriptText), 0644)
 	if err != nil {
@@ -365,9 +696,6 @@ func slurmPostJob(cmdRes *advexec.Result, j *job.Job, sysCfg *sys.Config) advexe
 	expRes.Err = cmdRes.Err
 
 	stdoutFile := getJobOutputFilePath(j, sysCfg)
-	if j.RunDir != "" {
-		stdoutFile = filepath.Join(j.RunDir, stdoutFile)
-	}
 	outputFileContent, err := ioutil.ReadFile(stdoutFile)
 	if err != nil {
 		expRes.Err = fmt.Errorf("unable to read %s: %s", stdoutFile, err)
@@ -375,19 +703,37 @@ func slurmPostJob(cmdRes *advexec.Result, j *job.Job, sysCfg *sys.Config) advexe
 	}
 	expRes.Stdout = string(outputFileContent)
 
-	stderrFile := getJobOutputFilePath(j, sysCfg)
-	if j.RunDir != "" {
-		stderrFile = filepath.Join(j.RunDir, stderrFile)
-	}
+	stderrFile := getJobErrorFilePath(j, sysCfg)
 	errFileContent, err := ioutil.ReadFile(stderrFile)
 	if err != nil {
 		expRes.Err = fmt.Errorf("unable to read %s: %s", stderrFile, err)
 		return expRes
 	}
 	expRes.Stderr = string(errFileContent)
+
+	if len(j.RequiredModules) > 0 {
+		setLoadedModules(j, sysCfg)
+	}
+
+	err = setRankOutput(j, &expRes)
+	if err != nil && expRes.Err == nil {
+		expRes.Err = err
+	}
 	return expRes
 }
 
+// getSbatchArgs returns the arguments of the command submitting the batch script of a job, without modifying the
+// arguments of the job manager
+func getSbatchArgs(j *job.Job, jobmgr *JM) []string {
+	var args []string
+	args = append(args, jobmgr.CmdArgs...)
+	// We want the default to be blocking sbatch but users can request non-blocking
+	if !j.NonBlocking {
+		args = append(args, "-W")
+	}
+	return append(args, j.BatchScript)
+}
+
 // slurmSubmit prepares the batch script necessary to start a given job.
 //
 // Note that a script does not need any specific environment to be submitted
@@ -413,16 +759,7 @@ func slurmSubmit(j *job.Job, jobmgr *JM, sysCfg *sys.Config) advexec.Result {
 
 	cmd.BinPath = jobmgr.BinPath
 	cmd.ExecDir = j.RunDir
-	// We want the default to be blocking sbatch but users can request non-blocking
-	if !j.NonBlocking {
-		jobmgr.CmdArgs = append(jobmgr.CmdArgs, "-W")
-	}
-
-	if len(jobmgr.CmdArgs) > 0 {
-		cmd.CmdArgs = append(cmd.CmdArgs, jobmgr.CmdArgs...)
-	}
-	//cmd.CmdArgs = append(cmd.CmdArgs, j.BatchScript)
-	cmd.CmdArgs = []string{j.BatchScript}
+	cmd.CmdArgs = getSbatchArgs(j, jobmgr)
 
 	j.SetOutputFn(slurmGetOutput)
 	j.SetErrorFn(slurmGetError)
@@ -432,7 +769,13 @@ func slurmSubmit(j *job.Job, jobmgr *JM, sysCfg *sys.Config) advexec.Result {
 		return resExec
 	}
 
+	j.Command = append([]string{cmd.BinPath}, cmd.CmdArgs...)
 	cmdRes := cmd.Run()
+	if cmdRes.Err != nil && !strings.HasPrefix(cmdRes.Stdout, slurmJobIDPrefix) {
+		// The job was not submitted, e.g., because of a limit set by the site
+		cmdRes.Err = newSubmitError(cmdRes.Err, cmdRes.Stderr)
+		return cmdRes
+	}
 	if strings.HasPrefix(cmdRes.Stdout, slurmJobIDPrefix) {
 		jobIDStr := strings.TrimPrefix(cmdRes.Stdout, slurmJobIDPrefix)
 		jobIDStr = strings.TrimRight(jobIDStr, "\n")
@@ -0,0 +1,209 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package jm
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/slurm"
+)
+
+// sinfoAvailabilityFormat is the format used with sinfo to get the usage of the nodes and CPUs of all partitions
+const sinfoAvailabilityFormat = "%R|%F|%C"
+
+// runSlurmCmd executes a Slurm command and returns its standard output
+func runSlurmCmd(name string, args ...string) (string, error) {
+	var cmd advexec.Advcmd
+	var err error
+	cmd.BinPath, err = exec.LookPath(name)
+	if err != nil {
+		return "", err
+	}
+	cmd.CmdArgs = args
+	res := cmd.Run()
+	if res.Err != nil {
+		return "", fmt.Errorf("%s failed: %w - stderr: %s", name, res.Err, res.Stderr)
+	}
+	return res.Stdout, nil
+}
+
+func atoiOrZero(s string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// parseScontrolPartitions parses the output of 'scontrol show partition -o'
+func parseScontrolPartitions(output string) ([]Partition, error) {
+	var partitions []Partition
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		kv := slurm.ParseKeyValues(line)
+		name, ok := kv["PartitionName"]
+		if !ok {
+			return nil, fmt.Errorf("invalid format: %s", line)
+		}
+
+		var p Partition
+		var err error
+		p.Name = name
+		p.Default = kv["Default"] == "YES"
+		p.State = kv["State"]
+		p.Nodes = kv["Nodes"]
+		p.DefaultTime, err = slurm.ParseTime(kv["DefaultTime"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid default time for partition %s: %w", name, err)
+		}
+		p.MaxTime, err = slurm.ParseTime(kv["MaxTime"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid maximum time for partition %s: %w", name, err)
+		}
+		if p.MaxTime == 0 {
+			p.MaxTime = slurm.InfiniteTime
+		}
+		p.MinNodes, err = slurm.ParseCount(kv["MinNodes"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid minimum number of nodes for partition %s: %w", name, err)
+		}
+		p.MaxNodes, err = slurm.ParseCount(kv["MaxNodes"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid maximum number of nodes for partition %s: %w", name, err)
+		}
+		if _, ok := kv["MaxNodes"]; !ok {
+			p.MaxNodes = -1
+		}
+		p.TotalNodes = atoiOrZero(kv["TotalNodes"])
+		p.TotalCPUs = atoiOrZero(kv["TotalCPUs"])
+		partitions = append(partitions, p)
+	}
+	return partitions, nil
+}
+
+// parseScontrolNodes parses the output of 'scontrol show node -o'
+func parseScontrolNodes(output string) ([]Node, error) {
+	var nodes []Node
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		kv := slurm.ParseKeyValues(line)
+		name, ok := kv["NodeName"]
+		if !ok {
+			return nil, fmt.Errorf("invalid format: %s", line)
+		}
+
+		var n Node
+		n.Name = name
+		n.State = kv["State"]
+		n.CPUs = atoiOrZero(kv["CPUTot"])
+		n.AllocatedCPUs = atoiOrZero(kv["CPUAlloc"])
+		n.Memory = atoiOrZero(kv["RealMemory"])
+		n.AllocatedMemory = atoiOrZero(kv["AllocMem"])
+		n.Gres = slurm.ParseList(kv["Gres"])
+		n.Features = slurm.ParseList(kv["AvailableFeatures"])
+		if n.Features == nil {
+			n.Features = slurm.ParseList(kv["Features"])
+		}
+		n.Partitions = slurm.ParseList(kv["Partitions"])
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// parseAllocIdleOtherTotal parses the "allocated/idle/other/total" format used by sinfo
+func parseAllocIdleOtherTotal(s string) (int, int, int, int, error) {
+	tokens := strings.Split(strings.TrimSpace(s), "/")
+	if len(tokens) != 4 {
+		return 0, 0, 0, 0, fmt.Errorf("invalid format: %s", s)
+	}
+	var values [4]int
+	for i, t := range tokens {
+		var err error
+		values[i], err = strconv.Atoi(t)
+		if err != nil {
+			return 0, 0, 0, 0, fmt.Errorf("invalid format: %s", s)
+		}
+	}
+	return values[0], values[1], values[2], values[3], nil
+}
+
+// parseSinfoAvailability parses the output of sinfo used with sinfoAvailabilityFormat and sets the availability
+// of the partitions accordingly
+func parseSinfoAvailability(output string, partitions []Partition) error {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		tokens := strings.Split(line, "|")
+		if len(tokens) != 3 {
+			return fmt.Errorf("invalid format: %s", line)
+		}
+
+		var a Availability
+		var err error
+		a.AllocatedNodes, a.IdleNodes, a.OtherNodes, a.TotalNodes, err = parseAllocIdleOtherTotal(tokens[1])
+		if err != nil {
+			return err
+		}
+		a.AllocatedCPUs, a.IdleCPUs, a.OtherCPUs, a.TotalCPUs, err = parseAllocIdleOtherTotal(tokens[2])
+		if err != nil {
+			return err
+		}
+
+		// sinfo may report a partition over several lines, we therefore accumulate the values
+		for idx := range partitions {
+			if partitions[idx].Name == tokens[0] {
+				partitions[idx].Availability.add(a)
+			}
+		}
+	}
+	return nil
+}
+
+// slurmClusterInfo gathers the details about the partitions and nodes of a Slurm cluster
+func slurmClusterInfo(jobmgr *JM) (*ClusterInfo, error) {
+	if jobmgr == nil {
+		return nil, fmt.Errorf("undefined job manager")
+	}
+
+	info := new(ClusterInfo)
+	output, err := runSlurmCmd("scontrol", "show", "partition", "-o")
+	if err != nil {
+		return nil, err
+	}
+	info.Partitions, err = parseScontrolPartitions(output)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse partitions: %w", err)
+	}
+
+	output, err = runSlurmCmd("scontrol", "show", "node", "-o")
+	if err != nil {
+		return nil, err
+	}
+	info.Nodes, err = parseScontrolNodes(output)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse nodes: %w", err)
+	}
+
+	output, err = runSlurmCmd("sinfo", "-h", "-o", sinfoAvailabilityFormat)
+	if err != nil {
+		return nil, err
+	}
+	err = parseSinfoAvailability(output, info.Partitions)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse partitions availability: %w", err)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package jm
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/slurm"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/job"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/sys"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/tail"
+)
+
+// slurmPollInterval is the minimum time between two queries of the state of a job whose output is followed
+const slurmPollInterval = 5 * time.Second
+
+// slurmActiveStates are the states of the jobs that may still write to their output files
+var slurmActiveStates = map[string]bool{
+	"PENDING":      true,
+	"CONFIGURING":  true,
+	"RUNNING":      true,
+	"COMPLETING":   true,
+	"SUSPENDED":    true,
+	"STOPPED":      true,
+	"REQUEUED":     true,
+	"REQUEUE_HOLD": true,
+	"REQUEUE_FED":  true,
+	"RESIZING":     true,
+	"SIGNALING":    true,
+	"STAGE_OUT":    true,
+}
+
+// slurmJobFiles is the state and output files of a job as reported by scontrol
+type slurmJobFiles struct {
+	State  string
+	Stdout string
+	Stderr string
+}
+
+// errSlurmUnknownJob is returned when Slurm does not know a job anymore, e.g., a while after its completion
+var errSlurmUnknownJob = errors.New("unknown job")
+
+// parseScontrolJobOutput parses the output of 'scontrol show job -o' for a single job
+func parseScontrolJobOutput(output string) (*slurmJobFiles, error) {
+	// Job arrays have a line per job, the first one is enough to know where the output goes
+	line := strings.SplitN(strings.TrimSpace(output), "\n", 2)[0]
+	kv := slurm.ParseKeyValues(line)
+	if kv["JobId"] == "" {
+		return nil, fmt.Errorf("invalid output: %s", line)
+	}
+	return &slurmJobFiles{
+		State:  kv["JobState"],
+		Stdout: kv["StdOut"],
+		Stderr: kv["StdErr"],
+	}, nil
+}
+
+func getSlurmJobFiles(jobID int) (*slurmJobFiles, error) {
+	output, err := runSlurmCmd("scontrol", "show", "job", "-o", strconv.Itoa(jobID))
+	if err != nil {
+		if strings.Contains(err.Error(), "Invalid job id specified") {
+			return nil, errSlurmUnknownJob
+		}
+		return nil, err
+	}
+	return parseScontrolJobOutput(output)
+}
+
+// slurmJobDoneFn returns a function checking whether a job completed, querying Slurm at most every slurmPollInterval
+func slurmJobDoneFn(jobID int) tail.DoneFn {
+	var lastCheck time.Time
+	done := false
+	return func() bool {
+		if done || time.Since(lastCheck) < slurmPollInterval {
+			return done
+		}
+		lastCheck = time.Now()
+		files, err := getSlurmJobFiles(jobID)
+		switch {
+		case err == errSlurmUnknownJob:
+			done = true
+		case err != nil:
+			log.Printf("unable to get the state of job %d: %s", jobID, err)
+		default:
+			done = !slurmActiveStates[files.State]
+		}
+		return done
+	}
+}
+
+// slurmFollowFiles follows the output files of a job
+func slurmFollowFiles(jobID int, stdoutFile string, stderrFile string) *Output {
+	o := &Output{Stdout: tail.File(stdoutFile, slurmJobDoneFn(jobID))}
+	if stderrFile == "" || stderrFile == stdoutFile {
+		// stderr goes to the output file
+		o.Stderr = ioutil.NopCloser(strings.NewReader(""))
+	} else {
+		o.Stderr = tail.File(stderrFile, slurmJobDoneFn(jobID))
+	}
+	return o
+}
+
+// slurmTail follows the output files of a job as reported by Slurm
+func slurmTail(jobmgr *JM, jobID int) (*Output, error) {
+	files, err := getSlurmJobFiles(jobID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the output files of job %d: %w", jobID, err)
+	}
+	if files.Stdout == "" {
+		return nil, fmt.Errorf("Slurm does not report the output file of job %d", jobID)
+	}
+	return slurmFollowFiles(jobID, files.Stdout, files.Stderr), nil
+}
+
+// slurmFollow follows the output files of a submitted job
+func slurmFollow(jobmgr *JM, j *job.Job, sysCfg *sys.Config) (*Output, error) {
+	if j.ID == 0 {
+		return nil, fmt.Errorf("job %s is not submitted yet", j.Name)
+	}
+	o, err := slurmTail(jobmgr, j.ID)
+	if !errors.Is(err, errSlurmUnknownJob) {
+		return o, err
+	}
+	if j.ExecutionTimestamp == "" {
+		return nil, err
+	}
+
+	// Slurm forgets about jobs a while after their completion, the files are then the ones we set in the script
+	return slurmFollowFiles(j.ID, getJobOutputFilePath(j, sysCfg), getJobErrorFilePath(j, sysCfg)), nil
+}
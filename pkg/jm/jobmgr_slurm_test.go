@@ -15,6 +15,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/environ"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/implem"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/job"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/mpi"
@@ -154,3 +155,244 @@ func TestSlurmSubmitMPI(t *testing.T) {
 
 	runAndCheckJob(t, jobmgr, j, sysCfg)
 }
+
+func TestParsePendingJobDetails(t *testing.T) {
+	partition, start, reason, err := parseSqueueStartOutput("1234|batch|2023-03-15T10:30:00|Priority\n")
+	if err != nil {
+		t.Fatalf("parseSqueueStartOutput() failed: %s", err)
+	}
+	if partition != "batch" || reason != "Priority" {
+		t.Fatalf("parseSqueueStartOutput() returned invalid partition or reason: %s, %s", partition, reason)
+	}
+	if start.Year() != 2023 || start.Month() != 3 || start.Day() != 15 || start.Hour() != 10 || start.Minute() != 30 {
+		t.Fatalf("parseSqueueStartOutput() returned an invalid start time: %s", start)
+	}
+
+	_, start, reason, err = parseSqueueStartOutput("1234|batch|N/A|Resources")
+	if err != nil {
+		t.Fatalf("parseSqueueStartOutput() failed: %s", err)
+	}
+	if !start.IsZero() || reason != "Resources" {
+		t.Fatalf("parseSqueueStartOutput() returned invalid data: %s, %s", start, reason)
+	}
+
+	priority, err := parseSprioOutput("1234|10542\n", 1234)
+	if err != nil {
+		t.Fatalf("parseSprioOutput() failed: %s", err)
+	}
+	if priority != 10542 {
+		t.Fatalf("parseSprioOutput() returned %d instead of 10542", priority)
+	}
+
+	pos, err := parseQueuePosition("1240\n1239\n1234\n1250\n", 1234)
+	if err != nil {
+		t.Fatalf("parseQueuePosition() failed: %s", err)
+	}
+	if pos != 3 {
+		t.Fatalf("parseQueuePosition() returned %d instead of 3", pos)
+	}
+}
+
+func TestParseSacctExitState(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedState    string
+		expectedExitCode int
+	}{
+		{input: "COMPLETED|0:0\n", expectedState: "COMPLETED", expectedExitCode: 0},
+		{input: "FAILED|2:0\n", expectedState: "FAILED", expectedExitCode: 2},
+		{input: "NODE_FAIL|0:0\n", expectedState: "NODE_FAIL", expectedExitCode: 0},
+		{input: "CANCELLED by 1234|0:15\n", expectedState: "CANCELLED", expectedExitCode: 0},
+	}
+
+	for _, tt := range tests {
+		state, exitCode, err := parseSacctExitState(tt.input)
+		if err != nil {
+			t.Fatalf("parseSacctExitState(%s) failed: %s", tt.input, err)
+		}
+		if state != tt.expectedState || exitCode != tt.expectedExitCode {
+			t.Fatalf("parseSacctExitState(%s) returned %s/%d instead of %s/%d", tt.input, state, exitCode, tt.expectedState, tt.expectedExitCode)
+		}
+	}
+}
+
+func TestGetSlurmHostfileCmd(t *testing.T) {
+	var j job.Job
+	var sysCfg sys.Config
+	j.Name = "test"
+	j.RunDir = "/scratch/my run"
+	j.ExecutionTimestamp = "20230102-030405"
+	j.NP = 8
+	j.NNodes = 2
+	j.MPICfg = new(mpi.Config)
+	j.MPICfg.Implem.ID = implem.MVAPICH2
+
+	cmd, path := getSlurmHostfileCmd(&j, &sysCfg)
+	expectedPath := "/scratch/my run/hostfile-test-20230102-030405-mvapich2"
+	expectedCmd := "scontrol show hostnames \"$SLURM_JOB_NODELIST\" | sed 's/$/:4/' > '" + expectedPath + "'\n"
+	if path != expectedPath {
+		t.Fatalf("getSlurmHostfileCmd() returned %s instead of %s", path, expectedPath)
+	}
+	if cmd != expectedCmd {
+		t.Fatalf("getSlurmHostfileCmd() returned %s instead of %s", cmd, expectedCmd)
+	}
+	if len(j.TempFiles) != 1 || j.TempFiles[0] != expectedPath || j.CleanUp == nil {
+		t.Fatalf("the hostfile is not removed by the clean up of the job")
+	}
+}
+
+func TestGenerateBatchScriptEnv(t *testing.T) {
+	var j job.Job
+	var sysCfg sys.Config
+	j.Name = "test"
+	j.BatchScript = "/tmp/test.sh"
+	j.CustomEnv = map[string]string{"B": "2", "A": "1"}
+	j.Env = &environ.Env{
+		Clear: true,
+		Vars: []environ.Var{
+			{Name: "MSG", Value: "hello world; exit"},
+			{Name: "HOME", Inherit: true},
+			{Name: "A", Value: "it's"},
+		},
+	}
+
+	script, err := generateBatchScriptContent(&j, &sysCfg)
+	if err != nil {
+		t.Fatalf("generateBatchScriptContent() failed: %s", err)
+	}
+	expected := []string{
+		"#SBATCH --export=HOME\n",
+		"export SLURM_EXPORT_ENV=ALL\nexport A='it'\\''s'\nexport B=2\nexport MSG='hello world; exit'\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(script, e) {
+			t.Fatalf("batch script does not include %q:\n%s", e, script)
+		}
+	}
+
+	j.Env.Vars = append(j.Env.Vars, environ.Var{Name: "1A", Value: "1"})
+	_, err = generateBatchScriptContent(&j, &sysCfg)
+	if err == nil {
+		t.Fatalf("generateBatchScriptContent() succeeded with an invalid variable")
+	}
+}
+
+func TestGetScriptCmd(t *testing.T) {
+	cmd := getScriptCmd([]string{"mpirun", "-x", "MSG=hello world", "--bind", "/data:/data", "app", "it's"})
+	expected := `mpirun -x 'MSG=hello world' --bind /data:/data app 'it'\''s'`
+	if cmd != expected {
+		t.Fatalf("getScriptCmd() returned %s instead of %s", cmd, expected)
+	}
+}
+
+func TestGetSbatchArgs(t *testing.T) {
+	jobmgr := JM{CmdArgs: []string{"--parsable"}}
+	tests := []struct {
+		name        string
+		nonBlocking bool
+		expected    []string
+	}{
+		{name: "blocking", expected: []string{"--parsable", "-W", "/tmp/job.sh"}},
+		{name: "nonBlocking", nonBlocking: true, expected: []string{"--parsable", "/tmp/job.sh"}},
+	}
+
+	for _, tt := range tests {
+		j := job.Job{BatchScript: "/tmp/job.sh", NonBlocking: tt.nonBlocking}
+		// Submitting several jobs must not change the arguments
+		for i := 0; i < 2; i++ {
+			args := getSbatchArgs(&j, &jobmgr)
+			if strings.Join(args, " ") != strings.Join(tt.expected, " ") {
+				t.Fatalf("%s: getSbatchArgs() returned %s instead of %s", tt.name, args, tt.expected)
+			}
+		}
+	}
+	if len(jobmgr.CmdArgs) != 1 {
+		t.Fatalf("getSbatchArgs() modified the arguments of the job manager: %s", jobmgr.CmdArgs)
+	}
+}
+
+func TestSetupNonMpiJob(t *testing.T) {
+	var j job.Job
+	var sysCfg sys.Config
+	var err error
+	sysCfg.ScratchDir, err = ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(sysCfg.ScratchDir)
+	j.Name = "test"
+	j.BatchScript = filepath.Join(sysCfg.ScratchDir, "test.sh")
+	j.App.BinPath = "/bin/echo"
+	j.App.BinArgs = []string{"-n", "hello world; exit"}
+
+	err = setupNonMpiJob(&j, &sysCfg)
+	if err != nil {
+		t.Fatalf("setupNonMpiJob() failed: %s", err)
+	}
+	script, err := ioutil.ReadFile(j.BatchScript)
+	if err != nil {
+		t.Fatalf("unable to read %s: %s", j.BatchScript, err)
+	}
+	expected := "\n/bin/echo -n 'hello world; exit'\n"
+	if !strings.Contains(string(script), expected) {
+		t.Fatalf("batch script does not include %q:\n%s", expected, script)
+	}
+}
+
+func TestBatchScriptModules(t *testing.T) {
+	var j job.Job
+	var sysCfg sys.Config
+	var err error
+	j.ArtifactDir, err = ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(j.ArtifactDir)
+	j.Name = "test"
+	j.BatchScript = filepath.Join(j.ArtifactDir, "test.sh")
+	// The module may only exist on the compute nodes
+	j.RequiredModules = []string{"nosuchmodule/1.0"}
+
+	script, err := generateBatchScriptContent(&j, &sysCfg)
+	if err != nil {
+		t.Fatalf("generateBatchScriptContent() failed: %s", err)
+	}
+	modulesFile := getJobModulesFilePath(&j, &sysCfg)
+	expected := "module load nosuchmodule/1.0\necho \"$LOADEDMODULES\" > " + modulesFile + "\n"
+	if !strings.Contains(script, expected) {
+		t.Fatalf("batch script does not include %q:\n%s", expected, script)
+	}
+
+	err = ioutil.WriteFile(modulesFile, []byte("gcc/12.2.0:nosuchmodule/1.0\n"), 0644)
+	if err != nil {
+		t.Fatalf("unable to write %s: %s", modulesFile, err)
+	}
+	setLoadedModules(&j, &sysCfg)
+	if strings.Join(j.LoadedModules, " ") != "gcc/12.2.0 nosuchmodule/1.0" {
+		t.Fatalf("setLoadedModules() set %s", strings.Join(j.LoadedModules, " "))
+	}
+}
+
+func TestGetJobOutputFilePath(t *testing.T) {
+	var j job.Job
+	var sysCfg sys.Config
+	j.Name = "test"
+	j.ExecutionTimestamp = "20230102-030405"
+	j.RunDir = "/scratch/run"
+
+	path := getJobOutputFilePath(&j, &sysCfg)
+	if path != "/scratch/run/test-20230102-030405.out" {
+		t.Fatalf("getJobOutputFilePath() returned %s", path)
+	}
+	j.ArtifactDir = "/scratch/artifacts/test-1"
+	path = getJobErrorFilePath(&j, &sysCfg)
+	if path != "/scratch/artifacts/test-1/test-20230102-030405.err" {
+		t.Fatalf("getJobErrorFilePath() returned %s", path)
+	}
+	j.ArtifactDir = ""
+	j.RunDir = ""
+	path = getJobOutputFilePath(&j, &sysCfg)
+	if !filepath.IsAbs(path) {
+		t.Fatalf("getJobOutputFilePath() returned the relative path %s", path)
+	}
+}
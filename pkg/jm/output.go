@@ -0,0 +1,174 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package jm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+
+	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/job"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/sys"
+)
+
+// followBufferSize is the maximum amount of output of a job running locally that is kept for a reader following it
+const followBufferSize = 1024 * 1024
+
+// Output gives access to the output of a job while it runs; the readers return io.EOF once the job completes. With
+// job managers running jobs locally, the output that does not fit in the buffer of a reader that is not consumed is
+// dropped so the job never waits for its readers (e.g., when only stdout is read).
+type Output struct {
+	// Stdout is the output of the job
+	Stdout io.ReadCloser
+
+	// Stderr is stderr of the job
+	Stderr io.ReadCloser
+}
+
+// Close stops following the output of a job, the job itself is not affected
+func (o *Output) Close() error {
+	err := o.Stdout.Close()
+	errStderr := o.Stderr.Close()
+	if err == nil {
+		err = errStderr
+	}
+	return err
+}
+
+// outputPipe is a pipe whose writes never block: the data that does not fit in its buffer is dropped. The reader
+// closing the pipe makes writes fail.
+type outputPipe struct {
+	mutex        sync.Mutex
+	cond         *sync.Cond
+	buf          bytes.Buffer
+	size         int
+	writerClosed bool
+	readerClosed bool
+}
+
+// outputPipeWriter is the end of an outputPipe that the output of a job is written to
+type outputPipeWriter struct {
+	p *outputPipe
+}
+
+func newOutputPipe(size int) (*outputPipe, *outputPipeWriter) {
+	p := &outputPipe{size: size}
+	p.cond = sync.NewCond(&p.mutex)
+	return p, &outputPipeWriter{p: p}
+}
+
+func (p *outputPipe) Read(b []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for p.buf.Len() == 0 && !p.writerClosed && !p.readerClosed {
+		p.cond.Wait()
+	}
+	if p.readerClosed {
+		return 0, io.ErrClosedPipe
+	}
+	if p.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return p.buf.Read(b)
+}
+
+func (p *outputPipe) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.readerClosed = true
+	p.buf.Reset()
+	p.cond.Broadcast()
+	return nil
+}
+
+func (w *outputPipeWriter) Write(b []byte) (int, error) {
+	p := w.p
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.readerClosed {
+		return 0, io.ErrClosedPipe
+	}
+	if p.buf.Len()+len(b) <= p.size {
+		p.buf.Write(b)
+		p.cond.Broadcast()
+	}
+	return len(b), nil
+}
+
+func (w *outputPipeWriter) Close() error {
+	p := w.p
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.writerClosed = true
+	p.cond.Broadcast()
+	return nil
+}
+
+// jobOutputWriter copies the output of a job to a buffer and to a writer of the job. The writer is dropped once it
+// fails (e.g., when the output is not followed anymore).
+type jobOutputWriter struct {
+	buf *bytes.Buffer
+	w   io.Writer
+}
+
+func (o *jobOutputWriter) Write(p []byte) (int, error) {
+	if o.w != nil {
+		_, err := o.w.Write(p)
+		if err != nil {
+			o.w = nil
+		}
+	}
+	return o.buf.Write(p)
+}
+
+// runJobCmd runs the command of a job running locally. When the job has writers, its output is copied to them as it
+// runs.
+func runJobCmd(cmd *advexec.Advcmd, j *job.Job) advexec.Result {
+	if j.Stdout == nil && j.Stderr == nil {
+		return cmd.Run()
+	}
+
+	var res advexec.Result
+	var stdout, stderr bytes.Buffer
+	c := exec.Command(cmd.BinPath, cmd.CmdArgs...)
+	c.Dir = cmd.ExecDir
+	// The environment of the command is complete (see setCmdEnv), the one of the current process when not set
+	c.Env = cmd.Env
+	c.Stdout = &jobOutputWriter{buf: &stdout, w: j.Stdout}
+	c.Stderr = &jobOutputWriter{buf: &stderr, w: j.Stderr}
+	res.Err = c.Run()
+	res.Stdout = stdout.String()
+	res.Stderr = stderr.String()
+	return res
+}
+
+// CloseOutput closes the writers the output of a job running locally is copied to, so that the readers following its
+// output get io.EOF. It is called once the job completes, after its last attempt when it is submitted again after a
+// failure.
+func CloseOutput(j *job.Job) {
+	if c, ok := j.Stdout.(io.Closer); ok {
+		c.Close()
+	}
+	if c, ok := j.Stderr.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// localFollow gives access to the output of a job running locally, through pipes that the job manager writes to as
+// the job runs
+func localFollow(jobmgr *JM, j *job.Job, sysCfg *sys.Config) (*Output, error) {
+	if j.Stdout != nil || j.Stderr != nil {
+		return nil, fmt.Errorf("the output of job %s is already followed", j.Name)
+	}
+	stdoutReader, stdoutWriter := newOutputPipe(followBufferSize)
+	stderrReader, stderrWriter := newOutputPipe(followBufferSize)
+	j.Stdout = stdoutWriter
+	j.Stderr = stderrWriter
+	return &Output{Stdout: stdoutReader, Stderr: stderrReader}, nil
+}
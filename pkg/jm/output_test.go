@@ -0,0 +1,131 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package jm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/job"
+)
+
+func TestLocalFollow(t *testing.T) {
+	var j job.Job
+	var cmd advexec.Advcmd
+	var err error
+	cmd.BinPath, err = exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available, skipping")
+	}
+	cmd.CmdArgs = []string{"-c", "echo out; echo err >&2"}
+
+	output, err := localFollow(nil, &j, nil)
+	if err != nil {
+		t.Fatalf("localFollow() failed: %s", err)
+	}
+	_, err = localFollow(nil, &j, nil)
+	if err == nil {
+		t.Fatalf("localFollow() succeeded with a job already followed")
+	}
+
+	resCh := make(chan advexec.Result, 1)
+	go func() {
+		defer CloseOutput(&j)
+		resCh <- runJobCmd(&cmd, &j)
+	}()
+	errCh := make(chan string)
+	go func() {
+		b, _ := ioutil.ReadAll(output.Stderr)
+		errCh <- string(b)
+	}()
+	stdout, err := ioutil.ReadAll(output.Stdout)
+	if err != nil {
+		t.Fatalf("unable to read the output: %s", err)
+	}
+	stderr := <-errCh
+	res := <-resCh
+	if res.Err != nil {
+		t.Fatalf("runJobCmd() failed: %s", res.Err)
+	}
+	if string(stdout) != "out\n" || stderr != "err\n" {
+		t.Fatalf("the job was followed with output %q and stderr %q", stdout, stderr)
+	}
+	if res.Stdout != "out\n" || res.Stderr != "err\n" {
+		t.Fatalf("runJobCmd() returned output %q and stderr %q", res.Stdout, res.Stderr)
+	}
+}
+
+func TestLocalFollowOneStream(t *testing.T) {
+	var j job.Job
+	var cmd advexec.Advcmd
+	var err error
+	cmd.BinPath, err = exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available, skipping")
+	}
+	// stderr does not fit in the buffer of its reader, which is never read
+	cmd.CmdArgs = []string{"-c", fmt.Sprintf("head -c %d /dev/zero >&2; echo out", 2*followBufferSize)}
+
+	output, err := localFollow(nil, &j, nil)
+	if err != nil {
+		t.Fatalf("localFollow() failed: %s", err)
+	}
+	resCh := make(chan advexec.Result, 1)
+	go func() {
+		defer CloseOutput(&j)
+		resCh <- runJobCmd(&cmd, &j)
+	}()
+
+	select {
+	case res := <-resCh:
+		if res.Err != nil {
+			t.Fatalf("runJobCmd() failed: %s", res.Err)
+		}
+		if res.Stdout != "out\n" || len(res.Stderr) != 2*followBufferSize {
+			t.Fatalf("runJobCmd() returned output %q and %d bytes of stderr", res.Stdout, len(res.Stderr))
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatalf("the job is blocked by the reader of stderr")
+	}
+	stdout, err := ioutil.ReadAll(output.Stdout)
+	if err != nil {
+		t.Fatalf("unable to read the output: %s", err)
+	}
+	if string(stdout) != "out\n" {
+		t.Fatalf("the job was followed with output %q", stdout)
+	}
+
+	// A reader that stops reading does not get anything else
+	err = output.Stderr.Close()
+	if err != nil {
+		t.Fatalf("unable to close stderr: %s", err)
+	}
+	_, err = output.Stderr.Read(make([]byte, 1))
+	if err == nil {
+		t.Fatalf("read from a closed reader succeeded")
+	}
+}
+
+func TestParseScontrolJobOutput(t *testing.T) {
+	output := "JobId=1234 JobName=bench UserId=user(1000) JobState=RUNNING Reason=None Dependency=(null) " +
+		"WorkDir=/scratch/run StdErr=/scratch/run/bench.err StdIn=/dev/null StdOut=/scratch/run/bench.out Power=\n"
+	files, err := parseScontrolJobOutput(output)
+	if err != nil {
+		t.Fatalf("parseScontrolJobOutput() failed: %s", err)
+	}
+	if files.State != "RUNNING" || files.Stdout != "/scratch/run/bench.out" || files.Stderr != "/scratch/run/bench.err" {
+		t.Fatalf("parseScontrolJobOutput() returned %+v", files)
+	}
+
+	_, err = parseScontrolJobOutput("No jobs in the system\n")
+	if err == nil {
+		t.Fatalf("parseScontrolJobOutput() succeeded with an invalid output")
+	}
+}
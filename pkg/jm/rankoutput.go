@@ -0,0 +1,77 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package jm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/job"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/mpi"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/rankoutput"
+)
+
+// getRankOutputDir returns the directory where the launcher writes the output of each rank of a job, next to the
+// output files of the job. Each attempt at running the job gets its own directory.
+func getRankOutputDir(j *job.Job) string {
+	j.SetTimestamp()
+	return filepath.Join(getJobOutputDir(j), getJobOutFilenamePrefix(j)+"-ranks")
+}
+
+// setRankOutputCfg sets the per-rank output of the MPI configuration of a job, creating the directory for the output
+// files of the ranks when needed
+func setRankOutputCfg(cfg *mpi.Config, j *job.Job) error {
+	if j.RankOutputMode == "" {
+		return nil
+	}
+	err := rankoutput.Validate(j.RankOutputMode)
+	if err != nil {
+		return err
+	}
+	cfg.RankOutput = j.RankOutputMode
+	if cfg.RankOutput != rankoutput.Files {
+		return nil
+	}
+
+	cfg.RankOutputDir = getRankOutputDir(j)
+	// Not all the launchers create the directory
+	err = os.MkdirAll(cfg.RankOutputDir, 0755)
+	if err != nil {
+		return fmt.Errorf("unable to create %s: %w", cfg.RankOutputDir, err)
+	}
+	return nil
+}
+
+// setRankOutput splits the output of a job per rank once it completed, from the labelled output of the job or from
+// the files of the ranks
+func setRankOutput(j *job.Job, res *advexec.Result) error {
+	if j.RankOutputMode == "" {
+		return nil
+	}
+	if j.MPICfg == nil {
+		return fmt.Errorf("per-rank output requires an MPI job")
+	}
+
+	var err error
+	switch j.RankOutputMode {
+	case rankoutput.Label:
+		l, errLauncher := mpi.GetLauncher(j.MPICfg)
+		if errLauncher != nil {
+			return fmt.Errorf("unable to get the MPI launcher: %w", errLauncher)
+		}
+		j.RankOutput, err = rankoutput.Parse(mpi.GetRankOutputFormat(j.MPICfg, l), res.Stdout, res.Stderr)
+	case rankoutput.Files:
+		j.RankOutput, err = rankoutput.ParseDir(getRankOutputDir(j))
+	default:
+		err = rankoutput.Validate(j.RankOutputMode)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to split the output of job %s per rank: %w", j.Name, err)
+	}
+	return nil
+}
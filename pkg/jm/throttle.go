@@ -0,0 +1,236 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package jm
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/job"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/sys"
+)
+
+const (
+	// DefaultPollInterval is the default time a throttler waits before checking again if jobs can be submitted
+	DefaultPollInterval = 30 * time.Second
+)
+
+// retryableSubmitErrors is the list of messages from the job manager that identify a rejected submission that can be
+// attempted again later
+var retryableSubmitErrors = []string{
+	"QOSMaxSubmitJobPerUserLimit",
+	"QOSMaxSubmitJobPerAccountLimit",
+	"AssocMaxSubmitJobLimit",
+	"MaxSubmitJobsPerUser",
+	"MaxSubmitJobsPerAccount",
+	"Slurm temporarily unable to accept job",
+	"Socket timed out",
+	"Resource temporarily unavailable",
+}
+
+// SubmitError is the error returned when the job manager rejects the submission of a job
+type SubmitError struct {
+	// Err is the error returned by the submission command
+	Err error
+
+	// Msg is the message explaining why the job manager rejected the job
+	Msg string
+
+	// Retryable specifies whether the submission can be attempted again later, e.g., once the number of jobs of the
+	// user is below the limit set by the site
+	Retryable bool
+}
+
+func (e *SubmitError) Error() string {
+	if e.Msg == "" {
+		return fmt.Sprintf("submission failed: %s", e.Err)
+	}
+	return fmt.Sprintf("submission failed: %s (%s)", e.Msg, e.Err)
+}
+
+func (e *SubmitError) Unwrap() error {
+	return e.Err
+}
+
+// newSubmitError creates a SubmitError from the error and stderr of a submission command
+func newSubmitError(err error, stderr string) *SubmitError {
+	e := &SubmitError{
+		Err: err,
+		Msg: strings.TrimSpace(stderr),
+	}
+	for _, msg := range retryableSubmitErrors {
+		if strings.Contains(stderr, msg) {
+			e.Retryable = true
+			break
+		}
+	}
+	return e
+}
+
+// IsRetryable checks whether an error is a rejected submission that can be attempted again later
+func IsRetryable(err error) bool {
+	var submitErr *SubmitError
+	if errors.As(err, &submitErr) {
+		return submitErr.Retryable
+	}
+	return false
+}
+
+// ThrottledResult is the result of the submission of a job through a throttler
+type ThrottledResult struct {
+	// Job is the job that was submitted
+	Job *job.Job
+
+	// Result is the result of the submission
+	Result advexec.Result
+}
+
+// Throttler submits jobs through a job manager while making sure that the user never has more than a given number
+// of jobs in a partition. Jobs that cannot be submitted right away are queued locally and submitted as soon as slots
+// free up. Jobs are submitted as non-blocking jobs. The job manager must be able to count the jobs of a user (e.g.,
+// Slurm).
+type Throttler struct {
+	// JM is the job manager used to submit the jobs
+	JM *JM
+
+	// SysCfg is the system configuration used to submit the jobs
+	SysCfg *sys.Config
+
+	// Partition is the partition in which the jobs are submitted
+	Partition string
+
+	// User is the user submitting the jobs
+	User string
+
+	// MaxJobs is the maximum number of jobs of the user in the partition
+	MaxJobs int
+
+	// PollInterval is the time to wait before checking again if jobs can be submitted
+	PollInterval time.Duration
+
+	// mu protects the local queue
+	mu sync.Mutex
+
+	// submitMu makes sure that the jobs are submitted by a single SubmitReady() at a time, so that the number of jobs
+	// of the user stays accurate
+	submitMu sync.Mutex
+
+	queue []*job.Job
+}
+
+// NewThrottler creates a throttler that keeps at most maxJobs jobs of a user in a partition
+func NewThrottler(jobmgr *JM, sysCfg *sys.Config, partition string, user string, maxJobs int) *Throttler {
+	t := new(Throttler)
+	t.JM = jobmgr
+	t.SysCfg = sysCfg
+	t.Partition = partition
+	t.User = user
+	t.MaxJobs = maxJobs
+	t.PollInterval = DefaultPollInterval
+	return t
+}
+
+// Add queues a job locally, it will be submitted by SubmitReady() or Run(). Jobs without partition are submitted to
+// the partition of the throttler; jobs that specify another partition are rejected since the throttler only counts
+// the jobs of its partition. The job is made non-blocking so that the throttler does not wait for its completion.
+func (t *Throttler) Add(j *job.Job) error {
+	if j.Partition != "" && j.Partition != t.Partition {
+		return fmt.Errorf("job %s is submitted to partition %s instead of %s", j.Name, j.Partition, t.Partition)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	j.Partition = t.Partition
+	j.NonBlocking = true
+	t.queue = append(t.queue, j)
+	return nil
+}
+
+// Len returns the number of jobs that are queued locally
+func (t *Throttler) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.queue)
+}
+
+// SubmitReady submits as many queued jobs as there are free slots and returns the results of the submissions. Jobs
+// rejected with a retryable error are kept in the queue. The queue is not locked while jobs are submitted, so jobs can
+// be added in the meantime.
+func (t *Throttler) SubmitReady() ([]ThrottledResult, error) {
+	t.submitMu.Lock()
+	defer t.submitMu.Unlock()
+
+	if t.MaxJobs <= 0 {
+		return nil, fmt.Errorf("invalid maximum number of jobs: %d", t.MaxJobs)
+	}
+
+	active, err := t.JM.NumJobs(t.Partition, t.User)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the number of jobs of %s: %w", t.User, err)
+	}
+
+	var results []ThrottledResult
+	for active < t.MaxJobs {
+		j := t.pop()
+		if j == nil {
+			break
+		}
+		res := t.JM.Submit(j, t.SysCfg)
+		if res.Err != nil && IsRetryable(res.Err) {
+			// The job manager does not accept more jobs for now, we will try again later
+			log.Printf("submission of job %s deferred: %s", j.Name, res.Err)
+			t.pushFront(j)
+			break
+		}
+		results = append(results, ThrottledResult{Job: j, Result: res})
+		if res.Err == nil {
+			active++
+		}
+	}
+
+	return results, nil
+}
+
+// pop removes the first job of the local queue and returns it, nil if the queue is empty
+func (t *Throttler) pop() *job.Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.queue) == 0 {
+		return nil
+	}
+	j := t.queue[0]
+	t.queue = t.queue[1:]
+	return j
+}
+
+// pushFront puts a job back at the head of the local queue
+func (t *Throttler) pushFront(j *job.Job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.queue = append([]*job.Job{j}, t.queue...)
+}
+
+// Run submits all the queued jobs, waiting for slots to free up when necessary, and returns the results of all the
+// submissions once the local queue is empty
+func (t *Throttler) Run() ([]ThrottledResult, error) {
+	var results []ThrottledResult
+	for {
+		res, err := t.SubmitReady()
+		results = append(results, res...)
+		if err != nil {
+			return results, err
+		}
+		if t.Len() == 0 {
+			return results, nil
+		}
+		time.Sleep(t.PollInterval)
+	}
+}
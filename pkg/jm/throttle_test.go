@@ -0,0 +1,171 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package jm
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/job"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/sys"
+)
+
+// fakeQueue simulates a job manager where jobs complete after a given number of queries
+type fakeQueue struct {
+	nextID     int
+	running    map[int]int
+	maxRunning int
+	rejectOnce bool
+	maxSeen    int
+}
+
+func (q *fakeQueue) tick() {
+	for id := range q.running {
+		q.running[id]--
+		if q.running[id] == 0 {
+			delete(q.running, id)
+		}
+	}
+}
+
+func newFakeJM(q *fakeQueue) *JM {
+	jobmgr := new(JM)
+	jobmgr.ID = "fake"
+	jobmgr.submitJM = func(j *job.Job, jobmgr *JM, sysCfg *sys.Config) advexec.Result {
+		var res advexec.Result
+		if q.rejectOnce {
+			q.rejectOnce = false
+			res.Err = newSubmitError(fmt.Errorf("exit status 1"), "sbatch: error: QOSMaxSubmitJobPerUserLimit\nsbatch: error: Batch job submission failed")
+			return res
+		}
+		q.nextID++
+		j.ID = q.nextID
+		q.running[j.ID] = 2
+		if len(q.running) > q.maxSeen {
+			q.maxSeen = len(q.running)
+		}
+		res.Stdout = slurmJobIDPrefix + strconv.Itoa(j.ID)
+		return res
+	}
+	jobmgr.numJobsJM = func(jobmgr *JM, partition string, user string) (int, error) {
+		q.tick()
+		return len(q.running), nil
+	}
+	return jobmgr
+}
+
+func TestThrottler(t *testing.T) {
+	q := &fakeQueue{running: make(map[int]int), rejectOnce: true}
+	var sysCfg sys.Config
+	throttler := NewThrottler(newFakeJM(q), &sysCfg, "batch", "user", 2)
+	throttler.PollInterval = time.Millisecond
+
+	for i := 0; i < 5; i++ {
+		err := throttler.Add(&job.Job{Name: "job" + strconv.Itoa(i)})
+		if err != nil {
+			t.Fatalf("Add() failed: %s", err)
+		}
+	}
+	if throttler.Len() != 5 {
+		t.Fatalf("%d jobs queued instead of 5", throttler.Len())
+	}
+
+	results, err := throttler.Run()
+	if err != nil {
+		t.Fatalf("Run() failed: %s", err)
+	}
+	if len(results) != 5 {
+		t.Fatalf("%d jobs submitted instead of 5", len(results))
+	}
+	for _, r := range results {
+		if r.Result.Err != nil {
+			t.Fatalf("submission of %s failed: %s", r.Job.Name, r.Result.Err)
+		}
+		if r.Job.Partition != "batch" {
+			t.Fatalf("job %s submitted to partition %s", r.Job.Name, r.Job.Partition)
+		}
+		if !r.Job.NonBlocking {
+			t.Fatalf("job %s submitted as a blocking job", r.Job.Name)
+		}
+	}
+	if q.maxSeen > 2 {
+		t.Fatalf("%d jobs were in the queue at the same time", q.maxSeen)
+	}
+	if throttler.Len() != 0 {
+		t.Fatalf("%d jobs still queued", throttler.Len())
+	}
+}
+
+func TestSubmitError(t *testing.T) {
+	err := newSubmitError(fmt.Errorf("exit status 1"), "sbatch: error: AssocMaxSubmitJobLimit")
+	if !IsRetryable(err) {
+		t.Fatalf("AssocMaxSubmitJobLimit is not reported as retryable")
+	}
+	err = newSubmitError(fmt.Errorf("exit status 1"), "sbatch: error: invalid partition specified: foo")
+	if IsRetryable(err) {
+		t.Fatalf("invalid partition is reported as retryable")
+	}
+	if IsRetryable(fmt.Errorf("wrapped: %w", newSubmitError(fmt.Errorf("exit status 1"), "Socket timed out"))) == false {
+		t.Fatalf("wrapped retryable error is not reported as retryable")
+	}
+}
+
+func TestThrottlerQueueUnlockedDuringSubmit(t *testing.T) {
+	var sysCfg sys.Config
+	q := &fakeQueue{running: make(map[int]int)}
+	jobmgr := newFakeJM(q)
+	throttler := NewThrottler(jobmgr, &sysCfg, "batch", "user", 2)
+	submit := jobmgr.submitJM
+	queued := -1
+	jobmgr.submitJM = func(j *job.Job, jobmgr *JM, sysCfg *sys.Config) advexec.Result {
+		// Deadlocks if the queue is locked during the submission
+		queued = throttler.Len()
+		return submit(j, jobmgr, sysCfg)
+	}
+
+	err := throttler.Add(&job.Job{Name: "job"})
+	if err != nil {
+		t.Fatalf("Add() failed: %s", err)
+	}
+	_, err = throttler.SubmitReady()
+	if err != nil {
+		t.Fatalf("SubmitReady() failed: %s", err)
+	}
+	if queued != 0 {
+		t.Fatalf("%d jobs queued during the submission instead of 0", queued)
+	}
+}
+
+func TestThrottlerOtherPartition(t *testing.T) {
+	var sysCfg sys.Config
+	q := &fakeQueue{running: make(map[int]int)}
+	throttler := NewThrottler(newFakeJM(q), &sysCfg, "batch", "user", 2)
+	err := throttler.Add(&job.Job{Name: "job", Partition: "debug"})
+	if err == nil {
+		t.Fatalf("Add() succeeded with a job submitted to another partition")
+	}
+	if throttler.Len() != 0 {
+		t.Fatalf("the job submitted to another partition was queued")
+	}
+}
+
+func TestThrottlerWithoutJobCount(t *testing.T) {
+	var sysCfg sys.Config
+	jobmgr := new(JM)
+	jobmgr.ID = NativeID
+	throttler := NewThrottler(jobmgr, &sysCfg, "batch", "user", 2)
+	err := throttler.Add(&job.Job{Name: "job", Partition: "batch"})
+	if err != nil {
+		t.Fatalf("Add() failed: %s", err)
+	}
+	_, err = throttler.SubmitReady()
+	if err == nil {
+		t.Fatalf("SubmitReady() succeeded with a job manager that cannot count jobs")
+	}
+}
@@ -8,9 +8,16 @@ package job
 
 import (
 	"bytes"
+	"fmt"
+	"io"
 
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/app"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/artifact"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/container"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/environ"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/expect"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/mpi"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/placement"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/sys"
 	"github.com/BTMichalowicz/go_util/pkg/timestamp"
 )
@@ -53,6 +60,14 @@ type Job struct {
 	// ErrBuffer is a buffer with the stderr of the job
 	ErrBuffer bytes.Buffer
 
+	// Stdout receives the output of the job as it runs, with job managers running the job locally (optional). It is
+	// closed once the last attempt of the job completes if it is a io.Closer.
+	Stdout io.Writer
+
+	// Stderr receives stderr of the job as it runs, with job managers running the job locally (optional). It is
+	// closed once the last attempt of the job completes if it is a io.Closer.
+	Stderr io.Writer
+
 	// internalGetOutput is the function to call to gather the output of the application based on the use of a given job manager
 	internalGetOutput GetOutputFn
 
@@ -68,22 +83,91 @@ type Job struct {
 	// Partition is the name of the partition to use with the jobmgr (optional)
 	Partition string
 
-	// Device is the network device to use to run the job
+	// Account is the account to charge the job to (optional)
+	Account string
+
+	// Device is the network device to use to run the job: a RDMA device and port (e.g., mlx5_0:1), or a network
+	// interface (e.g., eth0) with the tcp transport
 	Device string
 
+	// Transport is the transport to use to run the job: ucx, ofi, tcp or auto to select it, as well as the device,
+	// based on the devices of the submitting host (optional, the MPI implementation decides by default)
+	Transport string
+
+	// Provider is the libfabric provider to use with the ofi transport (e.g., verbs, cxi) (optional)
+	Provider string
+
+	// Container specifies the container in which the ranks of the job run (optional)
+	Container *container.Spec
+
+	// Placement specifies how the ranks are mapped and bound to the resources of the nodes (optional)
+	Placement *placement.Spec
+
+	// HostList is a compressed list of hosts (e.g., nid[001-004]) to use to run the job (optional)
+	HostList string
+
 	// RunDir is the path to the directory from which the job needs to be launched
 	RunDir string
 
+	// Artifacts specifies the files of the job to gather, with a description of the job, in a directory dedicated to
+	// the job (optional)
+	Artifacts *artifact.Spec
+
+	// ArtifactDir is the absolute path to the artifact directory of the job, once created. Batch systems write the
+	// output files of the job in it.
+	ArtifactDir string
+
+	// Command is the command that ran the job, set by the job manager (e.g., mpirun and its arguments)
+	Command []string
+
+	// TempFiles is the list of files the job manager created to run the job (e.g., hostfiles), removed by CleanUp
+	TempFiles []string
+
+	// RankOutputMode specifies whether the output of each rank is labelled with the rank (rankoutput.Label) or
+	// written to its own files (rankoutput.Files), to split the output of the job per rank once it completes
+	// (optional)
+	RankOutputMode string
+
+	// RankOutput is the output of each rank, stdout before stderr, once a blocking job with a RankOutputMode
+	// completes. Lines that no rank printed are associated to rankoutput.Unlabelled.
+	RankOutput map[int][]string
+
+	// Expectations is the list of expectations that the output of the job must meet, on top of the ones of its
+	// application (optional)
+	Expectations []expect.Expectation
+
 	// RequiredModules is the list of modules to load to be able to run the job
 	RequiredModules []string
 
+	// SpackLoad specifies whether the batch script loads the MPI implementation and the application installed with
+	// Spack using 'spack load' rather than by setting PATH and LD_LIBRARY_PATH
+	SpackLoad bool
+
+	// LoadedModules is the list of modules, with their version, that were loaded to run the job (e.g., openmpi/4.1.5)
+	LoadedModules []string
+
 	NonBlocking bool
 
+	// CustomEnv is a set of environment variables to set for the job (optional, kept for compatibility, Env is
+	// applied on top of it)
 	CustomEnv map[string]string
 
+	// Env is the environment of the job: ordered variables to set or to inherit from the submitting environment,
+	// applied by all the job managers and propagated to all the ranks (optional)
+	Env *environ.Env
+
 	ExecutionTimestamp string
 
 	MaxExecTime string
+
+	// Retry is the policy specifying if and how the job is submitted again after a failure (optional)
+	Retry *RetryPolicy
+
+	// Attempt is the number of the current attempt at running the job, starting at 1
+	Attempt int
+
+	// Attempts is the history of the attempts at running the job
+	Attempts []Attempt
 }
 
 // GetOutput is the function to call to gather the output (stdout) of the application after execution of the job
@@ -106,6 +190,17 @@ func (j *Job) SetErrorFn(fn GetErrorFn) {
 	j.internalGetError = fn
 }
 
+// Environment returns the environment of the job, merging CustomEnv and Env
+func (j *Job) Environment() (*environ.Env, error) {
+	env := environ.FromMap(j.CustomEnv)
+	env.Merge(j.Env)
+	err := env.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid environment: %w", err)
+	}
+	return env, nil
+}
+
 func (j *Job) SetTimestamp() {
 	if j.ExecutionTimestamp == "" {
 		j.ExecutionTimestamp = timestamp.Now()
@@ -0,0 +1,109 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package job
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// StateCompleted is the state of an attempt that succeeded
+	StateCompleted = "COMPLETED"
+
+	// StateFailed is the state of an attempt that failed without the job manager giving more details
+	StateFailed = "FAILED"
+)
+
+// DefaultRetryStates is a list of failure states that are usually caused by the system rather than the application
+var DefaultRetryStates = []string{"NODE_FAIL", "BOOT_FAIL", "PREEMPTED"}
+
+// RetryPolicy specifies if and how a job is submitted again after a failure
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times the job is submitted, including the first attempt
+	MaxAttempts int
+
+	// Backoff is the time to wait before submitting the job again after the first failure
+	Backoff time.Duration
+
+	// BackoffFactor multiplies the waiting time after each failure (the waiting time is constant when not set)
+	BackoffFactor float64
+
+	// States is the list of failure states (e.g., NODE_FAIL, TIMEOUT) that qualify for a new attempt
+	// (DefaultRetryStates when neither States nor ExitCodes are set)
+	States []string
+
+	// ExitCodes is the list of exit codes that qualify for a new attempt
+	ExitCodes []int
+
+	// Requeue asks the job manager to requeue the job itself when it supports it (e.g., sbatch --requeue)
+	Requeue bool
+}
+
+// Attempt gathers the details of one attempt at running a job
+type Attempt struct {
+	// Number is the number of the attempt, starting at 1
+	Number int
+
+	// Start is when the attempt was submitted
+	Start time.Time
+
+	// End is when the attempt completed
+	End time.Time
+
+	// State is the final state of the attempt (e.g., COMPLETED, FAILED, NODE_FAIL)
+	State string
+
+	// ExitCode is the exit code of the attempt
+	ExitCode int
+
+	// Err is the error returned by the job manager, if any
+	Err error
+
+	// Stdout is the output of the attempt
+	Stdout string
+
+	// Stderr is the error output of the attempt
+	Stderr string
+}
+
+// ShouldRetry checks whether an attempt that failed with a given state and exit code qualifies for a new attempt.
+// When the policy specifies neither states nor exit codes, only the failures caused by the system (DefaultRetryStates)
+// qualify.
+func (p *RetryPolicy) ShouldRetry(state string, exitCode int) bool {
+	if p == nil {
+		return false
+	}
+	states := p.States
+	if len(p.States) == 0 && len(p.ExitCodes) == 0 {
+		states = DefaultRetryStates
+	}
+	for _, s := range states {
+		if strings.EqualFold(s, state) {
+			return true
+		}
+	}
+	for _, c := range p.ExitCodes {
+		if c == exitCode {
+			return true
+		}
+	}
+	return false
+}
+
+// Delay returns the time to wait after a given failed attempt before submitting the job again
+func (p *RetryPolicy) Delay(attempt int) time.Duration {
+	if p == nil {
+		return 0
+	}
+	d := p.Backoff
+	if p.BackoffFactor > 0 {
+		for i := 1; i < attempt; i++ {
+			d = time.Duration(float64(d) * p.BackoffFactor)
+		}
+	}
+	return d
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package job
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	var nilPolicy *RetryPolicy
+	if nilPolicy.ShouldRetry(StateFailed, 1) {
+		t.Fatalf("a job without retry policy qualifies for a new attempt")
+	}
+
+	defaultPolicy := RetryPolicy{MaxAttempts: 3}
+	if !defaultPolicy.ShouldRetry("NODE_FAIL", 0) {
+		t.Fatalf("a system failure does not qualify for a new attempt with a policy without states or exit codes")
+	}
+	if defaultPolicy.ShouldRetry(StateFailed, 1) {
+		t.Fatalf("an application failure qualifies for a new attempt with a policy without states or exit codes")
+	}
+
+	p := RetryPolicy{
+		MaxAttempts:   3,
+		Backoff:       time.Second,
+		BackoffFactor: 2,
+		States:        DefaultRetryStates,
+		ExitCodes:     []int{75},
+	}
+	if !p.ShouldRetry("NODE_FAIL", 0) || !p.ShouldRetry(StateFailed, 75) {
+		t.Fatalf("a qualifying failure does not qualify for a new attempt")
+	}
+	if p.ShouldRetry(StateFailed, 1) || p.ShouldRetry("TIMEOUT", 0) {
+		t.Fatalf("a non-qualifying failure qualifies for a new attempt")
+	}
+
+	if p.Delay(1) != time.Second || p.Delay(2) != 2*time.Second || p.Delay(3) != 4*time.Second {
+		t.Fatalf("invalid delays: %s, %s, %s", p.Delay(1), p.Delay(2), p.Delay(3))
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package launcher
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/artifact"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/jm"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/job"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/mpi"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/sys"
+)
+
+// prepareArtifacts creates the artifact directory of a job before its submission, so that batch systems write the
+// output files of the job in it
+func prepareArtifacts(j *job.Job, sysCfg *sys.Config) error {
+	if j.Artifacts == nil {
+		return nil
+	}
+	j.SetTimestamp()
+	dir, err := artifact.CreateDir(j.Artifacts, sysCfg.ScratchDir, j.Name)
+	if err != nil {
+		return err
+	}
+	j.ArtifactDir = dir
+	return nil
+}
+
+// getJobMetadata returns the description of a job and of its execution
+func getJobMetadata(j *job.Job, jobmgr *jm.JM) *artifact.Metadata {
+	m := &artifact.Metadata{
+		Name:          j.Name,
+		ID:            j.ID,
+		JobManager:    jobmgr.ID,
+		NP:            j.NP,
+		NNodes:        j.NNodes,
+		Partition:     j.Partition,
+		Account:       j.Account,
+		App:           j.App.BinPath,
+		AppArgs:       j.App.BinArgs,
+		Command:       j.Command,
+		RunDir:        j.RunDir,
+		LoadedModules: j.LoadedModules,
+	}
+	env, err := j.Environment()
+	if err == nil {
+		for _, v := range env.Vars {
+			if v.Inherit {
+				m.Env = append(m.Env, v.Name)
+			} else {
+				m.Env = append(m.Env, v.Name+"="+v.Value)
+			}
+		}
+	}
+	if j.MPICfg != nil && j.MPICfg.Implem.ID != "" {
+		m.MPI = &artifact.MPIMetadata{
+			ID:         j.MPICfg.Implem.ID,
+			Version:    j.MPICfg.Implem.Version,
+			InstallDir: j.MPICfg.Implem.InstallDir,
+		}
+		l, err := mpi.GetLauncher(j.MPICfg)
+		if err == nil {
+			m.MPI.Launcher = l.Name
+		}
+	}
+	if j.Container != nil {
+		m.Container = j.Container.Image
+	}
+
+	for _, a := range j.Attempts {
+		am := artifact.AttemptMetadata{
+			Number:   a.Number,
+			Start:    a.Start,
+			End:      a.End,
+			State:    a.State,
+			ExitCode: a.ExitCode,
+		}
+		if a.Err != nil {
+			am.Error = a.Err.Error()
+		}
+		m.Attempts = append(m.Attempts, am)
+	}
+	if len(m.Attempts) > 0 {
+		first := m.Attempts[0]
+		last := m.Attempts[len(m.Attempts)-1]
+		m.Start = first.Start
+		m.End = last.End
+		m.Duration = last.End.Sub(first.Start).Seconds()
+		m.State = last.State
+		m.ExitCode = last.ExitCode
+		m.Error = last.Error
+	}
+	return m
+}
+
+// isLocalJobManager checks whether a job manager keeps the output of jobs in memory rather than in files
+func isLocalJobManager(jobmgr *jm.JM) bool {
+	return jobmgr.ID == jm.NativeID || jobmgr.ID == jm.PrunID
+}
+
+// collectArtifacts gathers the artifacts of a job once it completed: the files matching the patterns of the job, its
+// batch script, its output when the job manager did not write it to files, and its metadata. Non-blocking jobs only
+// get their batch script and metadata since they may still be running.
+func collectArtifacts(j *job.Job, jobmgr *jm.JM, execRes *advexec.Result) error {
+	if j.Artifacts == nil || j.ArtifactDir == "" {
+		return nil
+	}
+
+	m := getJobMetadata(j, jobmgr)
+	if !j.NonBlocking {
+		runDir := j.RunDir
+		if runDir == "" {
+			runDir = "."
+		}
+		_, err := artifact.Collect(j.Artifacts, runDir, j.ArtifactDir)
+		if err != nil {
+			return err
+		}
+
+		if isLocalJobManager(jobmgr) {
+			err = ioutil.WriteFile(filepath.Join(j.ArtifactDir, artifact.StdoutFile), []byte(execRes.Stdout), 0644)
+			if err != nil {
+				return fmt.Errorf("unable to save the output of job %s: %w", j.Name, err)
+			}
+			err = ioutil.WriteFile(filepath.Join(j.ArtifactDir, artifact.StderrFile), []byte(execRes.Stderr), 0644)
+			if err != nil {
+				return fmt.Errorf("unable to save stderr of job %s: %w", j.Name, err)
+			}
+		}
+	}
+
+	if j.BatchScript != "" && !strings.HasPrefix(j.BatchScript, j.ArtifactDir+string(os.PathSeparator)) {
+		name, err := artifact.AddFile(j.BatchScript, j.ArtifactDir)
+		if err != nil {
+			return err
+		}
+		m.BatchScript = name
+	}
+
+	files, err := artifact.Files(j.ArtifactDir)
+	if err != nil {
+		return err
+	}
+	m.Files = files
+	err = artifact.WriteMetadata(j.ArtifactDir, m)
+	if err != nil {
+		return err
+	}
+
+	if j.Artifacts.Bundle && !j.NonBlocking {
+		archive, err := artifact.Bundle(j.ArtifactDir)
+		if err != nil {
+			return err
+		}
+		log.Printf("artifacts of job %s archived in %s", j.Name, archive)
+	}
+	return nil
+}
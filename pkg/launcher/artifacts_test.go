@@ -0,0 +1,86 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package launcher
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/artifact"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/jm"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/job"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/sys"
+)
+
+func TestCollectArtifacts(t *testing.T) {
+	var sysCfg sys.Config
+	var err error
+	sysCfg.ScratchDir, err = ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(sysCfg.ScratchDir)
+
+	var j job.Job
+	j.Name = "bench"
+	j.NP = 2
+	j.RunDir = filepath.Join(sysCfg.ScratchDir, "run")
+	j.CustomEnv = map[string]string{"OMP_NUM_THREADS": "2"}
+	j.Artifacts = &artifact.Spec{Patterns: []string{"*.csv"}, Bundle: true}
+	err = os.MkdirAll(j.RunDir, 0755)
+	if err != nil {
+		t.Fatalf("unable to create %s: %s", j.RunDir, err)
+	}
+	err = ioutil.WriteFile(filepath.Join(j.RunDir, "results.csv"), []byte("1,2\n"), 0644)
+	if err != nil {
+		t.Fatalf("unable to write the results: %s", err)
+	}
+
+	err = prepareArtifacts(&j, &sysCfg)
+	if err != nil {
+		t.Fatalf("prepareArtifacts() failed: %s", err)
+	}
+	start := time.Now()
+	j.Command = []string{"/usr/bin/mpirun", "-np", "2", "bench"}
+	j.Attempts = []job.Attempt{
+		{Number: 1, Start: start, End: start.Add(time.Minute), State: job.StateFailed, ExitCode: 1, Err: fmt.Errorf("exit status 1")},
+		{Number: 2, Start: start.Add(2 * time.Minute), End: start.Add(3 * time.Minute), State: job.StateCompleted},
+	}
+	jobmgr := jm.JM{ID: jm.NativeID}
+	execRes := advexec.Result{Stdout: "done\n"}
+	err = collectArtifacts(&j, &jobmgr, &execRes)
+	if err != nil {
+		t.Fatalf("collectArtifacts() failed: %s", err)
+	}
+
+	m, err := artifact.ReadMetadata(j.ArtifactDir)
+	if err != nil {
+		t.Fatalf("unable to read the metadata: %s", err)
+	}
+	if m.State != job.StateCompleted || m.Duration != 180 || len(m.Attempts) != 2 || m.Attempts[0].Error != "exit status 1" {
+		t.Fatalf("invalid execution details: %+v", m)
+	}
+	if strings.Join(m.Env, " ") != "OMP_NUM_THREADS=2" || strings.Join(m.Command, " ") != "/usr/bin/mpirun -np 2 bench" {
+		t.Fatalf("invalid job details: %+v", m)
+	}
+	if strings.Join(m.Files, " ") != "results.csv stderr.txt stdout.txt" {
+		t.Fatalf("invalid artifacts: %v", m.Files)
+	}
+	output, err := ioutil.ReadFile(filepath.Join(j.ArtifactDir, artifact.StdoutFile))
+	if err != nil || string(output) != execRes.Stdout {
+		t.Fatalf("the output of the job was not saved")
+	}
+	_, err = os.Stat(j.ArtifactDir + artifact.BundleExt)
+	if err != nil {
+		t.Fatalf("the artifacts were not archived: %s", err)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package launcher
+
+import (
+	"log"
+
+	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/expect"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/job"
+)
+
+// getExpectations returns the expectations of a job: the ones of its application, then its own
+func getExpectations(j *job.Job) []expect.Expectation {
+	var exps []expect.Expectation
+	exps = append(exps, j.App.Expectations...)
+	return append(exps, j.Expectations...)
+}
+
+// getLastAttempt returns the last attempt at running a job, nil if the job was not submitted
+func getLastAttempt(j *job.Job) *job.Attempt {
+	if len(j.Attempts) == 0 {
+		return nil
+	}
+	return &j.Attempts[len(j.Attempts)-1]
+}
+
+// isExpectedFailure checks whether a job that failed ran until its end with an exit code that its expectations
+// check, in which case the expectations rather than the failure decide whether the job passes
+func isExpectedFailure(j *job.Job) bool {
+	if j.NonBlocking || !expect.ExpectsExitCode(getExpectations(j)) {
+		return false
+	}
+	a := getLastAttempt(j)
+	return a != nil && a.State == job.StateFailed && a.ExitCode > 0
+}
+
+// checkExpectations checks the output of a job that completed against its expectations and returns the ones that
+// failed. The output of non-blocking jobs is not known yet so it is not checked.
+func checkExpectations(j *job.Job, execRes *advexec.Result) []*expect.Failure {
+	exps := getExpectations(j)
+	if len(exps) == 0 {
+		return nil
+	}
+	if j.NonBlocking {
+		log.Printf("job %s is non-blocking, skipping the check of its output", j.Name)
+		return nil
+	}
+
+	out := &expect.Output{
+		Stdout: execRes.Stdout,
+		Stderr: execRes.Stderr,
+		NP:     j.NP,
+		Ranks:  j.RankOutput,
+	}
+	if a := getLastAttempt(j); a != nil {
+		out.ExitCode = a.ExitCode
+	}
+	return expect.CheckAll(exps, out)
+}
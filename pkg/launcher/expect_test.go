@@ -0,0 +1,40 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package launcher
+
+import (
+	"testing"
+
+	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/expect"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/job"
+)
+
+func TestCheckExpectations(t *testing.T) {
+	exitCode := 3
+	var j job.Job
+	j.NP = 2
+	j.App.Expectations = []expect.Expectation{{RankOutput: "#RANK/#NP"}}
+	j.Expectations = []expect.Expectation{{ExitCode: &exitCode}}
+	j.Attempts = []job.Attempt{{Number: 1, State: job.StateFailed, ExitCode: 3}}
+
+	if !isExpectedFailure(&j) {
+		t.Fatalf("isExpectedFailure() returned false for a job failing with the expected exit code")
+	}
+	failures := checkExpectations(&j, &advexec.Result{Stdout: "0/2\n1/2\n"})
+	if len(failures) != 0 {
+		t.Fatalf("checkExpectations() failed: %s", failures[0])
+	}
+	failures = checkExpectations(&j, &advexec.Result{Stdout: "0/2\n"})
+	if len(failures) != 1 || failures[0].Expectation.RankOutput != "#RANK/#NP" {
+		t.Fatalf("checkExpectations() returned %v instead of the failure of the rank template", failures)
+	}
+
+	j.NonBlocking = true
+	if isExpectedFailure(&j) || len(checkExpectations(&j, &advexec.Result{})) != 0 {
+		t.Fatalf("the output of a non-blocking job was checked")
+	}
+}
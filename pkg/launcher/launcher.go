@@ -7,12 +7,16 @@
 package launcher
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/exec"
+	"time"
 
 	"github.com/BTMichalowicz/go_exec/pkg/advexec"
 	"github.com/BTMichalowicz/go_exec/pkg/results"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/implem"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/jm"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/job"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/mpi"
@@ -32,54 +36,131 @@ func Load() (sys.Config, jm.JM, error) {
 	var cfg sys.Config
 	var jobmgr jm.JM
 
+	// Configuration files and environment variables come first so explicit settings always win
+	loadedCfg, err := sys.LoadConfig(nil)
+	if err != nil {
+		return cfg, jobmgr, fmt.Errorf("unable to load the configuration: %w", err)
+	}
+	cfg = *loadedCfg
+
 	/* Figure out the directory of this binary */
-	var err error
 	cfg.CurPath, err = os.Getwd()
 	if err != nil {
 		return cfg, jobmgr, fmt.Errorf("cannot detect current directory")
 	}
 
 	// Load the job manager component first
-	jobmgr = jm.Detect()
+	jobmgr = jm.DetectFromConfig(&cfg)
 
 	return cfg, jobmgr, nil
 }
 
-/*
-func checkOutput(output string, expected string) bool {
-	return strings.Contains(output, expected)
+// getExitState returns the final state and exit code of a job that completed. If the job manager cannot provide
+// them, they are derived from the result of the submission.
+func getExitState(j *job.Job, jobmgr *jm.JM, execRes *advexec.Result) (string, int) {
+	state, exitCode, err := jobmgr.JobExitState(j)
+	if err == nil {
+		return state, exitCode
+	}
+
+	if execRes.Err == nil {
+		return job.StateCompleted, 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(execRes.Err, &exitErr) {
+		return job.StateFailed, exitErr.ExitCode()
+	}
+	return job.StateFailed, -1
 }
 
-func checkJobOutput(output string, expectedOutput string, jobInfo *job.Job) bool {
-	if jobInfo.NP > 0 {
-		expected := strings.ReplaceAll(expectedOutput, "#NP", strconv.Itoa(jobInfo.NP))
-		for i := 0; i < jobInfo.NP; i++ {
-			curExpectedOutput := strings.ReplaceAll(expected, "#RANK", strconv.Itoa(i))
-			if checkOutput(output, curExpectedOutput) {
-				return true
+// submitWithRetries submits a job and, if it fails, submits it again as long as its retry policy allows it.
+// Non-blocking jobs are submitted only once since their completion is not known here; their retry policy can
+// still rely on the job manager to requeue them.
+func submitWithRetries(j *job.Job, jobmgr *jm.JM, sysCfg *sys.Config) advexec.Result {
+	var execRes advexec.Result
+
+	// Readers following the output of the job get io.EOF once the last attempt completes, even if the job cannot start
+	defer jm.CloseOutput(j)
+
+	maxAttempts := 1
+	if j.Retry != nil && j.Retry.MaxAttempts > 1 && !j.NonBlocking {
+		maxAttempts = j.Retry.MaxAttempts
+	}
+
+	// The batch script is generated for each attempt, unless the user provided one
+	userBatchScript := j.BatchScript
+	for attempt := 1; ; attempt++ {
+		j.Attempt = attempt
+		j.BatchScript = userBatchScript
+		// The ID of a previous attempt must not be mistaken for the one of this attempt if the submission fails
+		j.ID = 0
+
+		a := job.Attempt{
+			Number: attempt,
+			Start:  time.Now(),
+		}
+		execRes = jobmgr.Submit(j, sysCfg)
+		a.End = time.Now()
+		a.Err = execRes.Err
+		a.Stdout = execRes.Stdout
+		a.Stderr = execRes.Stderr
+		if !j.NonBlocking {
+			a.State, a.ExitCode = getExitState(j, jobmgr, &execRes)
+		}
+		j.Attempts = append(j.Attempts, a)
+
+		if execRes.Err == nil || attempt >= maxAttempts || !j.Retry.ShouldRetry(a.State, a.ExitCode) {
+			return execRes
+		}
+
+		// The next attempt gets a new batch script
+		if userBatchScript == "" && j.BatchScript != "" {
+			err := os.Remove(j.BatchScript)
+			if err != nil && !os.IsNotExist(err) {
+				log.Printf("unable to delete %s: %s", j.BatchScript, err)
 			}
 		}
-		return false
+
+		delay := j.Retry.Delay(attempt)
+		log.Printf("attempt %d of job %s failed (state: %s, exit code: %d), trying again in %s", attempt, j.Name, a.State, a.ExitCode, delay)
+		time.Sleep(delay)
 	}
-	return checkOutput(output, expectedOutput)
 }
 
-func expectedOutput(stdout string, stderr string, appInfo *app.Info, jobInfo *job.Job) bool {
-	if appInfo.ExpectedRankOutput == "" {
-		log.Println("App does not define any expected output, skipping check...")
-		return true
+// selectPreferredMPI returns the MPI implementation available from PATH that best matches the preferred MPI of the
+// configuration
+func selectPreferredMPI(constraint string) (*implem.Info, error) {
+	if constraint == "" {
+		return nil, fmt.Errorf("no MPI implementation specified and no preferred MPI configured")
 	}
-
-	// The output can be on stderr or stdout, we just cannot know in advanced.
-	// For instance, some MPI applications sends output to stderr by default
-	matched := checkJobOutput(stdout, appInfo.ExpectedRankOutput, jobInfo)
-	if !matched {
-		matched = checkJobOutput(stderr, appInfo.ExpectedRankOutput, jobInfo)
+	installs, err := mpi.Scan(&mpi.ScanOptions{UsePath: true})
+	if err != nil {
+		return nil, fmt.Errorf("unable to look for MPI implementations: %w", err)
+	}
+	i, err := implem.Select(installs, constraint)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find the preferred MPI implementation: %w", err)
 	}
+	return i, nil
+}
 
-	return matched
+// checkABI makes sure the application of a job was built against a MPI ABI compatible with the MPI implementation
+// of the job
+func checkABI(j *job.Job) error {
+	r, err := mpi.CheckABI(&j.App, &j.MPICfg.Implem)
+	if err != nil {
+		return fmt.Errorf("unable to check the MPI ABI of %s: %w", j.App.BinPath, err)
+	}
+	switch r.Compatibility {
+	case mpi.ABIIncompatible:
+		return fmt.Errorf("%s cannot run with %s %s: %s", j.App.BinPath, j.MPICfg.Implem.ID, j.MPICfg.Implem.Version, r.Reason)
+	case mpi.ABIMPICHCompatible:
+		log.Printf("%s relies on the MPICH ABI to run with %s %s: %s", j.App.BinPath, j.MPICfg.Implem.ID, j.MPICfg.Implem.Version, r.Reason)
+	case mpi.ABIUnknown:
+		log.Printf("WARNING: unable to check whether %s can run with %s %s: %s", j.App.BinPath, j.MPICfg.Implem.ID, j.MPICfg.Implem.Version, r.Reason)
+	}
+	return nil
 }
-*/
 
 // Run executes a job with a specific version of MPI on the host.
 // This is a blocking function, it returns when the job has completed
@@ -89,10 +170,42 @@ func Run(j *job.Job, hostMPI *mpi.Config, jobmgr *jm.JM, sysCfg *sys.Config, arg
 	expRes.Pass = true
 	errorMsg := ""
 
+	if hostMPI != nil && hostMPI.Implem.ID == "" && hostMPI.Implem.InstallDir == "" {
+		// The job needs MPI but the caller lets the configuration decide which one
+		i, err := selectPreferredMPI(sysCfg.Backend(jobmgr.ID).MPI)
+		if err != nil {
+			expRes.Pass = false
+			expRes.Note = fmt.Sprintf("[ERROR] %s\n", err)
+			execRes.Err = err
+			return expRes, execRes
+		}
+		selected := *hostMPI
+		selected.Implem = *i
+		hostMPI = &selected
+	}
+
 	if hostMPI != nil {
 		j.MPICfg = new(mpi.Config)
 		j.MPICfg.Implem = hostMPI.Implem
 		j.MPICfg.UserMpirunArgs = hostMPI.UserMpirunArgs
+		j.MPICfg.Hostfile = hostMPI.Hostfile
+		j.MPICfg.BindTo = hostMPI.BindTo
+		j.MPICfg.MapBy = hostMPI.MapBy
+		j.MPICfg.RankEnv = hostMPI.RankEnv
+		j.MPICfg.Launcher = hostMPI.Launcher
+		j.MPICfg.RequireIntegrity = hostMPI.RequireIntegrity
+		j.MPICfg.CheckABI = hostMPI.CheckABI
+	}
+
+	// The binary of a containerized application is within the image
+	if j.MPICfg != nil && j.MPICfg.CheckABI && j.Container == nil {
+		err := checkABI(j)
+		if err != nil {
+			expRes.Pass = false
+			expRes.Note = fmt.Sprintf("[ERROR] %s\n", err)
+			execRes.Err = err
+			return expRes, execRes
+		}
 	}
 
 	if len(args) == 0 {
@@ -107,15 +220,37 @@ func Run(j *job.Job, hostMPI *mpi.Config, jobmgr *jm.JM, sysCfg *sys.Config, arg
 		j.Args = append(j.Args, args...)
 	}
 
-	// We submit the job
-	execRes = jobmgr.Submit(j, sysCfg)
-	if execRes.Err != nil {
+	err := prepareArtifacts(j, sysCfg)
+	if err != nil {
+		expRes.Pass = false
+		expRes.Note = fmt.Sprintf("[ERROR] %s\n", err)
+		execRes.Err = err
+		return expRes, execRes
+	}
+
+	// We submit the job, possibly several times based on the job's retry policy
+	execRes = submitWithRetries(j, jobmgr, sysCfg)
+	if execRes.Err != nil && !isExpectedFailure(j) {
 		// The command simply failed and the Go runtime caught it
 		expRes.Pass = false
 		errorMsg = fmt.Sprintf("[ERROR] Command failed - stdout: %s - stderr: %s - err: %s\n", execRes.Stdout, execRes.Stderr, execRes.Err)
 		log.Printf("%s", errorMsg)
 	}
 
+	for _, f := range checkExpectations(j, &execRes) {
+		expRes.Pass = false
+		errorMsg += fmt.Sprintf("[ERROR] %s\n", f)
+		log.Printf("job %s: %s", j.Name, f)
+	}
+
+	// Artifacts are also gathered when the job fails, they help understanding why
+	err = collectArtifacts(j, jobmgr, &execRes)
+	if err != nil {
+		expRes.Pass = false
+		errorMsg += fmt.Sprintf("[ERROR] unable to collect the artifacts of job %s: %s\n", j.Name, err)
+		log.Printf("unable to collect the artifacts of job %s: %s", j.Name, err)
+	}
+
 	if !expRes.Pass {
 		expRes.Note = errorMsg
 	}
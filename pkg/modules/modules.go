@@ -0,0 +1,216 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package modules interacts with the environment modules (Lmod or Tcl Environment Modules) available on a system
+package modules
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+const (
+	// Lmod identifies Lmod
+	Lmod = "lmod"
+
+	// Tcl identifies the Tcl-based Environment Modules
+	Tcl = "tcl"
+
+	// LoadedModulesVar is the variable both Lmod and Environment Modules use to list the loaded modules
+	LoadedModulesVar = "LOADEDMODULES"
+)
+
+// System is the environment modules system available on a host
+type System struct {
+	// Kind is the kind of modules system (Lmod or Tcl)
+	Kind string
+
+	// Cmd is the path to the command the module shell function relies on (e.g., $LMOD_CMD or modulecmd.tcl)
+	Cmd string
+}
+
+// getenv returns the value of a variable from an environment
+func getenv(env []string, name string) string {
+	value := ""
+	for _, e := range env {
+		if strings.HasPrefix(e, name+"=") {
+			value = strings.TrimPrefix(e, name+"=")
+		}
+	}
+	return value
+}
+
+// Detect figures out which modules system is available from an environment (the environment of the process if
+// nil)
+func Detect(env []string) (*System, error) {
+	if env == nil {
+		env = os.Environ()
+	}
+
+	if cmd := getenv(env, "LMOD_CMD"); cmd != "" && util.FileExists(cmd) {
+		return &System{Kind: Lmod, Cmd: cmd}, nil
+	}
+	if cmd := getenv(env, "MODULES_CMD"); cmd != "" && util.FileExists(cmd) {
+		return &System{Kind: Tcl, Cmd: cmd}, nil
+	}
+	if home := getenv(env, "MODULESHOME"); home != "" {
+		cmd := filepath.Join(home, "libexec", "modulecmd.tcl")
+		if util.FileExists(cmd) {
+			return &System{Kind: Tcl, Cmd: cmd}, nil
+		}
+	}
+	// Environment Modules 3.x relies on a compiled modulecmd
+	if cmd, err := exec.LookPath("modulecmd"); err == nil {
+		return &System{Kind: Tcl, Cmd: cmd}, nil
+	}
+	return nil, fmt.Errorf("no environment modules system found")
+}
+
+// run executes a sub-command of the module command, for instance "avail"
+func (s *System) run(args ...string) advexec.Result {
+	var cmd advexec.Advcmd
+	cmd.BinPath = s.Cmd
+	cmd.CmdArgs = append([]string{"bash"}, args...)
+	return cmd.Run()
+}
+
+// parseTerseList parses the terse output (-t) of the avail or spider sub-commands, e.g., "openmpi/4.1.5(default)"
+func parseTerseList(output string) []string {
+	var mods []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		// Directories of module files end with a colon
+		if line == "" || strings.HasSuffix(line, ":") {
+			continue
+		}
+		if idx := strings.Index(line, "("); idx != -1 {
+			line = line[:idx]
+		}
+		mods = append(mods, strings.TrimSuffix(line, "/"))
+	}
+	return mods
+}
+
+// matches checks whether a module of a list is the requested module, e.g., openmpi matches openmpi/4.1.5 but
+// openmpi/4.1 does not match openmpi/4.1.5
+func matches(mods []string, name string) bool {
+	for _, m := range mods {
+		if m == name || strings.HasPrefix(m, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Avail returns the modules of a given name that can be loaded, for instance openmpi/4.1.5 and openmpi/5.0.0 for
+// openmpi
+func (s *System) Avail(name string) ([]string, error) {
+	args := []string{"avail", "-t", name}
+	if s.Kind == Lmod {
+		args = []string{"-t", "avail", name}
+	}
+	res := s.run(args...)
+	if res.Err != nil {
+		return nil, fmt.Errorf("unable to list the available modules: %w - stderr: %s", res.Err, res.Stderr)
+	}
+	// Lists are displayed on stderr
+	return parseTerseList(res.Stderr), nil
+}
+
+// spider returns the modules of a given name that exist in the Lmod hierarchy, including the ones that can only be
+// loaded after other modules (e.g., a MPI built with a given compiler)
+func (s *System) spider(name string) ([]string, error) {
+	res := s.run("-t", "spider", name)
+	if res.Err != nil {
+		return nil, fmt.Errorf("unable to look for modules: %w - stderr: %s", res.Err, res.Stderr)
+	}
+	return parseTerseList(res.Stderr), nil
+}
+
+// Check makes sure the requested modules exist
+func (s *System) Check(mods []string) error {
+	var missing []string
+	for _, m := range mods {
+		avail, err := s.Avail(m)
+		if err != nil {
+			return err
+		}
+		if matches(avail, m) {
+			continue
+		}
+		if s.Kind == Lmod {
+			found, err := s.spider(m)
+			if err != nil {
+				return err
+			}
+			if matches(found, m) {
+				continue
+			}
+		}
+		missing = append(missing, m)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("unknown module(s): %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// parseEnv parses the output of 'env -0'
+func parseEnv(output string) []string {
+	var env []string
+	for _, e := range strings.Split(output, "\x00") {
+		if strings.Contains(e, "=") {
+			env = append(env, e)
+		}
+	}
+	return env
+}
+
+// Environment returns the environment resulting from purging the modules and loading a list of modules, as a
+// batch script would do. The shell code generated by the module command is evaluated by bash so the environment
+// is exactly the one the module function gives.
+func (s *System) Environment(mods []string) ([]string, error) {
+	var cmd advexec.Advcmd
+	var err error
+	cmd.BinPath, err = exec.LookPath("bash")
+	if err != nil {
+		return nil, fmt.Errorf("bash not found")
+	}
+	script := `eval "$("$1" bash purge)" && eval "$("$1" bash load "${@:2}")" && env -0`
+	cmd.CmdArgs = append([]string{"-c", script, "bash", s.Cmd}, mods...)
+	res := cmd.Run()
+	if res.Err != nil {
+		return nil, fmt.Errorf("unable to load module(s) %s: %w - stderr: %s", strings.Join(mods, " "), res.Err, res.Stderr)
+	}
+	return parseEnv(res.Stdout), nil
+}
+
+// LoadedModules returns the list of modules loaded in an environment, with their version (e.g., openmpi/4.1.5)
+func LoadedModules(env []string) []string {
+	return ParseLoadedModules(getenv(env, LoadedModulesVar))
+}
+
+// ParseLoadedModules parses the value of LoadedModulesVar, e.g., gcc/12.2.0:openmpi/4.1.5
+func ParseLoadedModules(value string) []string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ":")
+}
+
+// ScriptCmds returns the shell commands loading a list of modules in a script
+func ScriptCmds(mods []string) string {
+	if len(mods) == 0 {
+		return ""
+	}
+	return "module purge\nmodule load " + strings.Join(mods, " ") + "\n"
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package modules
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeLmod mimics $LMOD_CMD: lists are displayed on stderr and shell code on stdout
+const fakeLmod = `#!/bin/sh
+shift
+case "$1" in
+-t)
+	case "$2" in
+	avail)
+		echo "/opt/modulefiles/Core:" >&2
+		echo "gcc/12.2.0(default)" >&2
+		echo "gcc/13.1.0" >&2
+		;;
+	spider)
+		echo "gcc/12.2.0" >&2
+		echo "openmpi/4.1.5" >&2
+		;;
+	esac
+	;;
+purge)
+	echo "unset LOADEDMODULES;"
+	;;
+load)
+	shift
+	for m in "$@"; do
+		case "$m" in
+		gcc|openmpi) ;;
+		*) echo "Lmod has detected the following error: unknown module $m" >&2; echo "false;"; exit 0 ;;
+		esac
+	done
+	echo "LOADEDMODULES=gcc/12.2.0:openmpi/4.1.5; export LOADEDMODULES;"
+	echo "MPI_HOME='/opt/openmpi 4.1.5'; export MPI_HOME;"
+	;;
+esac
+`
+
+func TestLmod(t *testing.T) {
+	dir, err := ioutil.TempDir("", "modules-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	lmodCmd := filepath.Join(dir, "lmod")
+	err = ioutil.WriteFile(lmodCmd, []byte(fakeLmod), 0755)
+	if err != nil {
+		t.Fatalf("unable to create %s: %s", lmodCmd, err)
+	}
+
+	s, err := Detect([]string{"LMOD_CMD=" + lmodCmd})
+	if err != nil {
+		t.Fatalf("Detect() failed: %s", err)
+	}
+	if s.Kind != Lmod || s.Cmd != lmodCmd {
+		t.Fatalf("Detect() returned %s (%s) instead of %s (%s)", s.Kind, s.Cmd, Lmod, lmodCmd)
+	}
+
+	avail, err := s.Avail("gcc")
+	if err != nil {
+		t.Fatalf("Avail() failed: %s", err)
+	}
+	if strings.Join(avail, " ") != "gcc/12.2.0 gcc/13.1.0" {
+		t.Fatalf("Avail() returned %s", strings.Join(avail, " "))
+	}
+
+	// openmpi is only found by spider, e.g., because it depends on a compiler
+	err = s.Check([]string{"gcc", "openmpi"})
+	if err != nil {
+		t.Fatalf("Check() failed: %s", err)
+	}
+	err = s.Check([]string{"gcc/12", "mpich"})
+	if err == nil || !strings.Contains(err.Error(), "gcc/12, mpich") {
+		t.Fatalf("Check() did not report the unknown modules: %v", err)
+	}
+
+	env, err := s.Environment([]string{"gcc", "openmpi"})
+	if err != nil {
+		t.Fatalf("Environment() failed: %s", err)
+	}
+	if getenv(env, "MPI_HOME") != "/opt/openmpi 4.1.5" {
+		t.Fatalf("Environment() returned MPI_HOME=%s", getenv(env, "MPI_HOME"))
+	}
+	loaded := LoadedModules(env)
+	if strings.Join(loaded, " ") != "gcc/12.2.0 openmpi/4.1.5" {
+		t.Fatalf("LoadedModules() returned %s", strings.Join(loaded, " "))
+	}
+
+	_, err = s.Environment([]string{"mpich"})
+	if err == nil {
+		t.Fatalf("Environment() succeeded with an unknown module")
+	}
+}
+
+func TestDetectTcl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "modules-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	modulecmd := filepath.Join(dir, "libexec", "modulecmd.tcl")
+	err = os.MkdirAll(filepath.Dir(modulecmd), 0755)
+	if err != nil {
+		t.Fatalf("unable to create directory: %s", err)
+	}
+	err = ioutil.WriteFile(modulecmd, []byte("#!/bin/sh\n"), 0755)
+	if err != nil {
+		t.Fatalf("unable to create %s: %s", modulecmd, err)
+	}
+
+	s, err := Detect([]string{"MODULESHOME=" + dir, "LMOD_CMD=" + filepath.Join(dir, "missing")})
+	if err != nil {
+		t.Fatalf("Detect() failed: %s", err)
+	}
+	if s.Kind != Tcl || s.Cmd != modulecmd {
+		t.Fatalf("Detect() returned %s (%s) instead of %s (%s)", s.Kind, s.Cmd, Tcl, modulecmd)
+	}
+}
@@ -0,0 +1,340 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package mpi
+
+import (
+	"debug/elf"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/app"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/implem"
+)
+
+const (
+	// OpenMPIABI is the ABI family of Open MPI
+	OpenMPIABI = "openmpi"
+
+	// MPICHABI is the ABI family shared by the implementations of the MPICH ABI compatibility initiative (MPICH,
+	// MVAPICH2, Intel MPI, HPE Cray MPICH)
+	MPICHABI = "mpich-abi"
+
+	// SpectrumMPIABI is the ABI family of IBM Spectrum MPI
+	SpectrumMPIABI = "spectrummpi"
+
+	// LegacyMPICHABI is the ABI family of the MPICH-derived implementations that predate the MPICH ABI
+	// compatibility initiative
+	LegacyMPICHABI = "mpich-legacy"
+)
+
+// ABICompatibility is the result of the comparison between the ABI of an application and the ABI of a MPI
+// implementation
+type ABICompatibility int
+
+const (
+	// ABIIncompatible means the application cannot run with the MPI implementation
+	ABIIncompatible ABICompatibility = iota
+
+	// ABICompatible means the application can run with the MPI implementation as is
+	ABICompatible
+
+	// ABIMPICHCompatible means the application was built against another member of the MPICH ABI compatibility
+	// initiative; it can run with the MPI implementation but the library it needs may have to be remapped (e.g.,
+	// libmpich.so.12 to libmpi.so.12)
+	ABIMPICHCompatible
+
+	// ABIUnknown means the ABI of the application or of the MPI implementation cannot be identified, the
+	// application may or may not run with the MPI implementation
+	ABIUnknown
+)
+
+func (c ABICompatibility) String() string {
+	switch c {
+	case ABICompatible:
+		return "compatible"
+	case ABIMPICHCompatible:
+		return "MPICH-ABI compatible"
+	case ABIUnknown:
+		return "unknown"
+	default:
+		return "incompatible"
+	}
+}
+
+// AppABI describes the MPI ABI an application binary was linked against
+type AppABI struct {
+	// Family is the ABI family of the application (e.g., OpenMPIABI, MPICHABI), empty when unknown
+	Family string
+
+	// Implem is the ID of the MPI implementation the application was built with, when the soname is specific to one
+	// implementation (e.g., libmpich.so.12 for MPICH) (optional)
+	Implem string
+
+	// Soname is the soname of the MPI library the application needs (e.g., libmpi.so.40)
+	Soname string
+
+	// SymbolVersions is the list of versions of the MPI symbols the application imports, if any
+	SymbolVersions []string
+}
+
+// ABIReport is the result of the ABI check of an application against a MPI implementation
+type ABIReport struct {
+	// App is the ABI of the application
+	App *AppABI
+
+	// HostFamily is the ABI family of the MPI implementation
+	HostFamily string
+
+	// HostSonames is the list of sonames of the MPI libraries the implementation provides
+	HostSonames []string
+
+	// Compatibility is the result of the check
+	Compatibility ABICompatibility
+
+	// Reason explains the result of the check
+	Reason string
+}
+
+func (r *ABIReport) String() string {
+	return fmt.Sprintf("%s (%s)", r.Compatibility, r.Reason)
+}
+
+// isMPISoname checks whether a soname is the soname of a MPI library (libmpi_mpifh, libmpi_cxx, etc. excluded)
+func isMPISoname(soname string) bool {
+	for _, prefix := range []string{"libmpi.so", "libmpich.so", "libmpi_cray.so", "libmpi_ibm.so"} {
+		if strings.HasPrefix(soname, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyAppABI figures out the ABI of an application from the libraries and symbols it imports
+func classifyAppABI(needed []string, symbols []elf.ImportedSymbol) (*AppABI, error) {
+	a := new(AppABI)
+	for _, lib := range needed {
+		if isMPISoname(lib) {
+			a.Soname = lib
+			break
+		}
+	}
+	if a.Soname == "" {
+		return nil, fmt.Errorf("no MPI library found")
+	}
+
+	// Open MPI handles are pointers to global objects (e.g., MPI_COMM_WORLD is &ompi_mpi_comm_world) so Open MPI
+	// applications always import ompi_* symbols, while MPICH handles are integer constants.
+	ompiSymbols := false
+	seen := make(map[string]bool)
+	for _, s := range symbols {
+		if strings.HasPrefix(s.Name, "ompi_") {
+			ompiSymbols = true
+		}
+		if s.Library == a.Soname && s.Version != "" && !seen[s.Version] {
+			seen[s.Version] = true
+			a.SymbolVersions = append(a.SymbolVersions, s.Version)
+		}
+	}
+	sort.Strings(a.SymbolVersions)
+
+	switch {
+	case strings.HasPrefix(a.Soname, "libmpi_ibm.so"):
+		a.Family = SpectrumMPIABI
+		a.Implem = implem.SPECTRUMMPI
+	case strings.HasPrefix(a.Soname, "libmpi_cray.so"):
+		a.Family = MPICHABI
+		a.Implem = implem.CRAYMPICH
+	case strings.HasPrefix(a.Soname, "libmpich.so"):
+		a.Family = MPICHABI
+		a.Implem = implem.MPICH
+	case ompiSymbols:
+		a.Family = OpenMPIABI
+		a.Implem = implem.OMPI
+	case a.Soname == "libmpi.so.12":
+		a.Family = MPICHABI
+	}
+	return a, nil
+}
+
+// GetAppABI inspects the ELF binary of an application to figure out the MPI ABI it was linked against
+func GetAppABI(path string) (*AppABI, error) {
+	ef, err := elf.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %w", path, err)
+	}
+	defer ef.Close()
+
+	needed, err := ef.ImportedLibraries()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the libraries %s depends on: %w", path, err)
+	}
+	symbols, err := ef.ImportedSymbols()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the symbols %s imports: %w", path, err)
+	}
+	a, err := classifyAppABI(needed, symbols)
+	if err != nil {
+		return nil, fmt.Errorf("unable to identify the MPI ABI of %s: %w", path, err)
+	}
+	return a, nil
+}
+
+// getHostABIFamily returns the ABI family of a MPI implementation, empty when unknown
+func getHostABIFamily(i *implem.Info) string {
+	v, err := i.ParsedVersion()
+	atLeast := func(min string) bool {
+		if err != nil {
+			// Without a usable version, we assume a recent implementation
+			return true
+		}
+		m, _ := implem.ParseVersion(min)
+		return v.Compare(m) >= 0
+	}
+
+	switch i.ID {
+	case implem.OMPI:
+		return OpenMPIABI
+	case implem.SPECTRUMMPI:
+		return SpectrumMPIABI
+	case implem.MPICH:
+		if atLeast("3.1") {
+			return MPICHABI
+		}
+		return LegacyMPICHABI
+	case implem.MVAPICH2:
+		if atLeast("2.0") {
+			return MPICHABI
+		}
+		return LegacyMPICHABI
+	case implem.INTELMPI:
+		if atLeast("5.0") {
+			return MPICHABI
+		}
+		return LegacyMPICHABI
+	case implem.CRAYMPICH:
+		if atLeast("7.0") {
+			return MPICHABI
+		}
+		return LegacyMPICHABI
+	}
+	return ""
+}
+
+// getExpectedSonames returns the sonames of the MPI libraries an implementation is expected to provide, based on
+// its version
+func getExpectedSonames(i *implem.Info, family string) []string {
+	switch family {
+	case OpenMPIABI:
+		v, err := i.ParsedVersion()
+		switch {
+		case err != nil || v.Release[0] >= 3:
+			return []string{"libmpi.so.40"}
+		case v.Release[0] == 2:
+			return []string{"libmpi.so.20"}
+		default:
+			return []string{"libmpi.so.12"}
+		}
+	case SpectrumMPIABI:
+		return []string{"libmpi_ibm.so.3"}
+	case MPICHABI:
+		switch i.ID {
+		case implem.CRAYMPICH:
+			return []string{"libmpi_cray.so.12"}
+		case implem.MPICH, implem.MVAPICH2:
+			return []string{"libmpi.so.12", "libmpich.so.12"}
+		default:
+			return []string{"libmpi.so.12"}
+		}
+	}
+	return nil
+}
+
+// getHostSonames returns the sonames of the MPI libraries provided by an implementation. When the libraries cannot
+// be found, the sonames expected for the implementation are returned.
+func getHostSonames(i *implem.Info, family string) []string {
+	libDirs := []string{i.LibDir}
+	if i.LibDir == "" {
+		libDirs = []string{filepath.Join(i.InstallDir, "lib"), filepath.Join(i.InstallDir, "lib64")}
+	}
+
+	var sonames []string
+	seen := make(map[string]bool)
+	for _, dir := range libDirs {
+		files, _ := filepath.Glob(filepath.Join(dir, "lib*.so.*"))
+		for _, f := range files {
+			// We only keep the sonames, i.e., libmpi.so.40 but not libmpi.so.40.30.5
+			name := filepath.Base(f)
+			idx := strings.Index(name, ".so.")
+			if idx == -1 || strings.Contains(name[idx+len(".so."):], ".") {
+				continue
+			}
+			if isMPISoname(name) && !seen[name] {
+				seen[name] = true
+				sonames = append(sonames, name)
+			}
+		}
+	}
+	if len(sonames) == 0 {
+		return getExpectedSonames(i, family)
+	}
+	sort.Strings(sonames)
+	return sonames
+}
+
+// compareABI compares the ABI of an application with the ABI family and sonames of a MPI implementation
+func compareABI(a *AppABI, hostID string, hostFamily string, hostSonames []string) *ABIReport {
+	r := &ABIReport{
+		App:           a,
+		HostFamily:    hostFamily,
+		HostSonames:   hostSonames,
+		Compatibility: ABIIncompatible,
+	}
+
+	provided := false
+	for _, soname := range hostSonames {
+		if soname == a.Soname {
+			provided = true
+			break
+		}
+	}
+
+	switch {
+	case a.Family == "":
+		r.Compatibility = ABIUnknown
+		r.Reason = fmt.Sprintf("unable to identify the MPI ABI of %s", a.Soname)
+	case hostFamily == "":
+		r.Compatibility = ABIUnknown
+		r.Reason = fmt.Sprintf("unable to identify the MPI ABI of %s", hostID)
+	case a.Family != hostFamily:
+		r.Reason = fmt.Sprintf("application built against the %s ABI but %s provides the %s ABI", a.Family, hostID, hostFamily)
+	case a.Family == MPICHABI && (!provided || (a.Implem != "" && a.Implem != hostID)):
+		r.Compatibility = ABIMPICHCompatible
+		r.Reason = fmt.Sprintf("application needs %s, %s provides %s", a.Soname, hostID, strings.Join(hostSonames, ", "))
+	case !provided:
+		r.Reason = fmt.Sprintf("application needs %s but %s provides %s", a.Soname, hostID, strings.Join(hostSonames, ", "))
+	default:
+		r.Compatibility = ABICompatible
+		r.Reason = fmt.Sprintf("%s provided by %s", a.Soname, hostID)
+	}
+	return r
+}
+
+// CheckABI checks whether an application can run with a given MPI implementation based on the MPI ABI its binary
+// was linked against
+func CheckABI(a *app.Info, i *implem.Info) (*ABIReport, error) {
+	if a == nil || i == nil || a.BinPath == "" {
+		return nil, fmt.Errorf("invalid parameter(s)")
+	}
+
+	appABI, err := GetAppABI(a.BinPath)
+	if err != nil {
+		return nil, err
+	}
+	family := getHostABIFamily(i)
+	return compareABI(appABI, i.ID, family, getHostSonames(i, family)), nil
+}
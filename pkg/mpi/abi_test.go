@@ -0,0 +1,182 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package mpi
+
+import (
+	"debug/elf"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/app"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/implem"
+)
+
+func TestClassifyAppABI(t *testing.T) {
+	tests := []struct {
+		name           string
+		needed         []string
+		symbols        []elf.ImportedSymbol
+		expectedFamily string
+		expectedImplem string
+		expectedSoname string
+		expectedErr    bool
+	}{
+		{
+			name:           "openmpi",
+			needed:         []string{"libmpi.so.40", "libc.so.6"},
+			symbols:        []elf.ImportedSymbol{{Name: "MPI_Init", Library: "libmpi.so.40"}, {Name: "ompi_mpi_comm_world", Library: "libmpi.so.40"}},
+			expectedFamily: OpenMPIABI,
+			expectedImplem: implem.OMPI,
+			expectedSoname: "libmpi.so.40",
+		},
+		{
+			name:           "mpichABI",
+			needed:         []string{"libmpi_mpifh.so.12", "libmpi.so.12", "libc.so.6"},
+			symbols:        []elf.ImportedSymbol{{Name: "MPI_Init", Library: "libmpi.so.12"}},
+			expectedFamily: MPICHABI,
+			expectedSoname: "libmpi.so.12",
+		},
+		{
+			name:           "libmpich",
+			needed:         []string{"libmpich.so.12"},
+			expectedFamily: MPICHABI,
+			expectedImplem: implem.MPICH,
+			expectedSoname: "libmpich.so.12",
+		},
+		{
+			name:           "cray",
+			needed:         []string{"libmpi_cray.so.12"},
+			expectedFamily: MPICHABI,
+			expectedImplem: implem.CRAYMPICH,
+			expectedSoname: "libmpi_cray.so.12",
+		},
+		{
+			name:        "notMPI",
+			needed:      []string{"libc.so.6"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := classifyAppABI(tt.needed, tt.symbols)
+			if tt.expectedErr {
+				if err == nil {
+					t.Fatalf("classifyAppABI() succeeded but was expected to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("classifyAppABI() failed: %s", err)
+			}
+			if a.Family != tt.expectedFamily || a.Implem != tt.expectedImplem || a.Soname != tt.expectedSoname {
+				t.Fatalf("classifyAppABI() returned %s/%s/%s instead of %s/%s/%s", a.Family, a.Implem, a.Soname, tt.expectedFamily, tt.expectedImplem, tt.expectedSoname)
+			}
+		})
+	}
+}
+
+func TestCompareABI(t *testing.T) {
+	tests := []struct {
+		name     string
+		app      AppABI
+		host     implem.Info
+		expected ABICompatibility
+	}{
+		{
+			name:     "sameOpenMPI",
+			app:      AppABI{Family: OpenMPIABI, Implem: implem.OMPI, Soname: "libmpi.so.40"},
+			host:     implem.Info{ID: implem.OMPI, Version: "4.1.5"},
+			expected: ABICompatible,
+		},
+		{
+			name:     "oldOpenMPI",
+			app:      AppABI{Family: OpenMPIABI, Implem: implem.OMPI, Soname: "libmpi.so.40"},
+			host:     implem.Info{ID: implem.OMPI, Version: "2.1.6"},
+			expected: ABIIncompatible,
+		},
+		{
+			name:     "openmpiOnMPICH",
+			app:      AppABI{Family: OpenMPIABI, Implem: implem.OMPI, Soname: "libmpi.so.40"},
+			host:     implem.Info{ID: implem.MPICH, Version: "4.1.2"},
+			expected: ABIIncompatible,
+		},
+		{
+			name:     "mpichOnMPICH",
+			app:      AppABI{Family: MPICHABI, Soname: "libmpi.so.12"},
+			host:     implem.Info{ID: implem.MPICH, Version: "4.1.2"},
+			expected: ABICompatible,
+		},
+		{
+			name:     "mpichOnIntelMPI",
+			app:      AppABI{Family: MPICHABI, Implem: implem.MPICH, Soname: "libmpich.so.12"},
+			host:     implem.Info{ID: implem.INTELMPI, Version: "2021.9"},
+			expected: ABIMPICHCompatible,
+		},
+		{
+			name:     "mpichOnOldMPICH",
+			app:      AppABI{Family: MPICHABI, Soname: "libmpi.so.12"},
+			host:     implem.Info{ID: implem.MPICH, Version: "3.0.4"},
+			expected: ABIIncompatible,
+		},
+		{
+			name:     "unknownApp",
+			app:      AppABI{Soname: "libmpi.so"},
+			host:     implem.Info{ID: implem.MPICH, Version: "4.1.2"},
+			expected: ABIUnknown,
+		},
+		{
+			name:     "unknownHost",
+			app:      AppABI{Family: MPICHABI, Soname: "libmpi.so.12"},
+			host:     implem.Info{ID: "unknown-mpi", Version: "1.0"},
+			expected: ABIUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			family := getHostABIFamily(&tt.host)
+			r := compareABI(&tt.app, tt.host.ID, family, getExpectedSonames(&tt.host, family))
+			if r.Compatibility != tt.expected {
+				t.Fatalf("compareABI() returned %s instead of %s", r, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetHostSonames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mpi-abi-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	libDir := filepath.Join(dir, "lib")
+	err = os.MkdirAll(libDir, 0755)
+	if err != nil {
+		t.Fatalf("unable to create %s: %s", libDir, err)
+	}
+	for _, f := range []string{"libmpi.so", "libmpi.so.12", "libmpi.so.12.1.8", "libmpicxx.so.12", "libmpich.so.12"} {
+		err = ioutil.WriteFile(filepath.Join(libDir, f), nil, 0644)
+		if err != nil {
+			t.Fatalf("unable to create %s: %s", f, err)
+		}
+	}
+
+	i := implem.Info{ID: implem.MPICH, Version: "4.1.2", InstallDir: dir}
+	sonames := getHostSonames(&i, MPICHABI)
+	if len(sonames) != 2 || sonames[0] != "libmpi.so.12" || sonames[1] != "libmpich.so.12" {
+		t.Fatalf("getHostSonames() returned %v", sonames)
+	}
+
+	// A file that is not an ELF binary cannot be checked
+	_, err = CheckABI(&app.Info{BinPath: filepath.Join(libDir, "libmpi.so")}, &i)
+	if err == nil {
+		t.Fatalf("CheckABI() succeeded with an invalid binary")
+	}
+}
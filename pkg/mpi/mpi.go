@@ -11,15 +11,24 @@ import (
 	"log"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 
 	"github.com/BTMichalowicz/go_exec/pkg/manifest"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/intelmpi"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/mpich"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/mvapich2"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/network"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/openmpi"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/slurm"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/vendormpi"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/app"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/hostlist"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/implem"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/mpimanifest"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/placement"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/rankoutput"
 	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/sys"
+	"github.com/BTMichalowicz/go_util/pkg/util"
 )
 
 // Config represents a configuration of MPI for a target platform
@@ -31,6 +40,37 @@ type Config struct {
 
 	// UserMpirunArgs is a list of extra arguments defined by the user to pass to the mpirun commands
 	UserMpirunArgs []string
+
+	// Hostfile is the path to the hostfile to use to start the ranks (optional)
+	Hostfile string
+
+	// BindTo specifies the unit processes are bound to (e.g., core, socket) (optional)
+	BindTo string
+
+	// MapBy specifies how processes are mapped (e.g., socket, node) (optional)
+	MapBy string
+
+	// RequireIntegrity specifies whether jobs must be refused when the MPI installation does not match its manifest
+	RequireIntegrity bool
+
+	// CheckABI specifies whether jobs must be refused when the application was not built against a MPI ABI
+	// compatible with the implementation
+	CheckABI bool
+
+	// Launcher is the name of the launcher to use when the implementation provides several of them, e.g.,
+	// mpiexec.hydra instead of mpirun_rsh with MVAPICH2 (optional)
+	Launcher string
+
+	// RankEnv is the list of environment variables to propagate to all the ranks, either as KEY=VALUE or KEY to
+	// propagate the value from the environment of the launcher (optional)
+	RankEnv []string
+
+	// RankOutput specifies whether the launcher labels the output of each rank (rankoutput.Label) or writes it to
+	// RankOutputDir (rankoutput.Files) (optional)
+	RankOutput string
+
+	// RankOutputDir is the directory where the launcher writes the output of each rank with rankoutput.Files
+	RankOutputDir string
 }
 
 // GetPathToMpirun returns the path to mpirun based a configuration of MPI
@@ -53,6 +93,59 @@ func GetPathToMpirun(mpiCfg *implem.Info) (string, error) {
 	return path, nil
 }
 
+// Launcher describes the command used to start the ranks of a MPI job
+type Launcher struct {
+	// Name is the name of the command (e.g., mpirun, mpirun_rsh, srun)
+	Name string
+
+	// Path is the path to the command
+	Path string
+
+	// NPFlag is the flag used to specify the number of ranks (e.g., -np)
+	NPFlag string
+}
+
+// GetLauncher returns the command to use to start the ranks of a job with a given MPI configuration
+func GetLauncher(cfg *Config) (*Launcher, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("invalid parameter(s)")
+	}
+	myHostMPICfg := &cfg.Implem
+
+	l := new(Launcher)
+	l.Name = "mpirun"
+	l.NPFlag = "-np"
+	switch {
+	case cfg.Launcher != "":
+		l.Name = cfg.Launcher
+	case myHostMPICfg.ID == implem.MVAPICH2:
+		l.Name = mvapich2.RshLauncher
+	case vendormpi.IsVendorMPI(myHostMPICfg.ID):
+		l.Name = vendormpi.GetLauncher(myHostMPICfg.ID)
+	}
+	if l.Name == "srun" {
+		l.NPFlag = "-n"
+	}
+	if myHostMPICfg.ID == implem.MVAPICH2 && l.Name == mvapich2.HydraLauncher && myHostMPICfg.InstallDir != "" && !mvapich2.HasHydra(myHostMPICfg.InstallDir) {
+		return nil, fmt.Errorf("%s does not provide %s", myHostMPICfg.InstallDir, l.Name)
+	}
+
+	// Vendor implementations do not always provide their launcher in their install directory (e.g., srun)
+	l.Path = filepath.Join(myHostMPICfg.InstallDir, "bin", l.Name)
+	if myHostMPICfg.InstallDir == "" || !util.FileExists(l.Path) {
+		if !vendormpi.IsVendorMPI(myHostMPICfg.ID) {
+			return l, nil
+		}
+		var err error
+		l.Path, err = exec.LookPath(l.Name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to find %s: %w", l.Name, err)
+		}
+	}
+
+	return l, nil
+}
+
 // GetMpirunArgs returns the arguments required by a mpirun
 func GetMpirunArgs(myHostMPICfg *implem.Info, app *app.Info, sysCfg *sys.Config, netCfg *network.Config, mpirunArgs []string) ([]string, error) {
 	var extraArgs []string
@@ -63,15 +156,226 @@ func GetMpirunArgs(myHostMPICfg *implem.Info, app *app.Info, sysCfg *sys.Config,
 		extraArgs = append(extraArgs, openmpi.GetExtraMpirunArgs(sysCfg, netCfg, mpirunArgs)...)
 	case implem.MVAPICH2:
 		extraArgs = append(extraArgs, mvapich2.GetExtraMpirunArgs(sysCfg, netCfg, mpirunArgs)...)
+	case implem.MPICH:
+		extraArgs = append(extraArgs, mpich.GetExtraMpirunArgs(sysCfg, netCfg, mpirunArgs)...)
+	case implem.INTELMPI:
+		extraArgs = append(extraArgs, intelmpi.GetExtraMpirunArgs(sysCfg, netCfg, mpirunArgs)...)
+	case implem.CRAYMPICH, implem.SPECTRUMMPI, implem.HPEMPT:
+		// Vendor implementations are configured through their environment
+		extraArgs = append(extraArgs, mpirunArgs...)
 	}
 
 	return extraArgs, nil
 }
 
-// CheckIntegrity checks if a given installation of MPI has been compromised
+// isHydra checks whether the ranks of a job are started with the Hydra process manager
+func isHydra(myHostMPICfg *implem.Info, l *Launcher) bool {
+	switch myHostMPICfg.ID {
+	case implem.MPICH, implem.INTELMPI:
+		return true
+	case implem.MVAPICH2:
+		return l.Name != mvapich2.RshLauncher
+	}
+	return false
+}
+
+// getPlacementArgs returns the launcher arguments to place the ranks of a job according to a placement spec, for
+// launchers that handle the placement through their arguments
+func getPlacementArgs(cfg *Config, l *Launcher, spec *placement.Spec) ([]string, error) {
+	if spec.IsEmpty() {
+		return nil, nil
+	}
+	switch {
+	case l.Name == "srun":
+		return slurm.GetPlacementArgs(spec), nil
+	case cfg.Implem.ID == implem.OMPI || cfg.Implem.ID == implem.SPECTRUMMPI:
+		return openmpi.GetPlacementArgs(spec), nil
+	case isHydra(&cfg.Implem, l):
+		return mpich.GetPlacementArgs(spec)
+	}
+	return nil, fmt.Errorf("placement of the ranks is not supported with %s", cfg.Implem.ID)
+}
+
+// GetLaunchArgs returns all the arguments to give to a launcher between the number of ranks and the application:
+// hostfile, placement, binding, mapping, environment of the ranks and mpirun arguments, in the order the launcher
+// expects them. spec is optional.
+func GetLaunchArgs(cfg *Config, l *Launcher, spec *placement.Spec, app *app.Info, sysCfg *sys.Config, netCfg *network.Config, hostfile string) ([]string, error) {
+	if cfg == nil || l == nil {
+		return nil, fmt.Errorf("invalid parameter(s)")
+	}
+	if !spec.IsEmpty() && spec.BindTo != "" && cfg.BindTo != "" {
+		return nil, fmt.Errorf("binding specified both in the MPI configuration and the placement of the ranks")
+	}
+
+	var args []string
+	hydra := isHydra(&cfg.Implem, l)
+	if hostfile != "" {
+		if hydra {
+			args = append(args, mpich.GetHostfileArgs(hostfile)...)
+		} else {
+			args = append(args, "-hostfile", hostfile)
+		}
+	}
+
+	outputArgs, err := getRankOutputArgs(cfg, l)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Implem.ID == implem.MVAPICH2 {
+		// MVAPICH2 handles the placement through the environment, except for the number of ranks per node which,
+		// with mpirun_rsh, is given by the hostfile
+		env, err := mvapich2.GetPlacementEnv(spec)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, mvapich2.GetBindingEnv(cfg.BindTo, cfg.MapBy)...)
+		env = append(env, cfg.RankEnv...)
+		if !hydra {
+			// mpirun_rsh expects its options before the environment variables
+			args = append(args, cfg.UserMpirunArgs...)
+			return append(args, mvapich2.GetRshEnvArgs(mvapich2.GetEnv(netCfg, env))...), nil
+		}
+		if !spec.IsEmpty() && spec.RanksPerNode > 0 {
+			args = append(args, "-ppn", strconv.Itoa(spec.RanksPerNode))
+		}
+		args = append(args, mpich.GetEnvArgs(mvapich2.GetEnv(netCfg, env))...)
+		args = append(args, outputArgs...)
+		return append(args, cfg.UserMpirunArgs...), nil
+	}
+
+	placementArgs, err := getPlacementArgs(cfg, l, spec)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, placementArgs...)
+
+	switch {
+	case hydra:
+		args = append(args, mpich.GetBindingArgs(cfg.BindTo, cfg.MapBy)...)
+		args = append(args, mpich.GetEnvArgs(cfg.RankEnv)...)
+	case cfg.BindTo != "" || cfg.MapBy != "":
+		return nil, fmt.Errorf("binding and mapping settings are not supported with %s", cfg.Implem.ID)
+	case l.Name == "srun":
+		args = append(args, slurm.GetEnvArgs(cfg.RankEnv)...)
+	case cfg.Implem.ID == implem.OMPI || cfg.Implem.ID == implem.SPECTRUMMPI:
+		args = append(args, openmpi.GetEnvArgs(cfg.RankEnv)...)
+	case len(cfg.RankEnv) > 0:
+		return nil, fmt.Errorf("environment settings are not supported with %s", cfg.Implem.ID)
+	}
+	args = append(args, outputArgs...)
+
+	mpirunArgs, err := GetMpirunArgs(&cfg.Implem, app, sysCfg, netCfg, cfg.UserMpirunArgs)
+	if err != nil {
+		return nil, err
+	}
+	return append(args, mpirunArgs...), nil
+}
+
+// GetRankOutputFormat returns the format of the labels the launcher of a job prefixes the output of the ranks with
+// (see rankoutput.Parse), an empty string if it cannot label them
+func GetRankOutputFormat(cfg *Config, l *Launcher) string {
+	switch {
+	case l.Name == "srun":
+		return rankoutput.SrunFormat
+	case isHydra(&cfg.Implem, l):
+		return rankoutput.HydraFormat
+	case cfg.Implem.ID == implem.OMPI, cfg.Implem.ID == implem.SPECTRUMMPI:
+		return rankoutput.OpenMPIFormat
+	}
+	return ""
+}
+
+// getRankOutputArgs returns the launcher arguments to label the output of each rank or to write it to a directory
+func getRankOutputArgs(cfg *Config, l *Launcher) ([]string, error) {
+	if cfg.RankOutput == "" {
+		return nil, nil
+	}
+	err := rankoutput.Validate(cfg.RankOutput)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.RankOutput == rankoutput.Files && cfg.RankOutputDir == "" {
+		return nil, fmt.Errorf("undefined directory for the output of the ranks")
+	}
+
+	switch GetRankOutputFormat(cfg, l) {
+	case rankoutput.SrunFormat:
+		return slurm.GetRankOutputArgs(cfg.RankOutput, cfg.RankOutputDir), nil
+	case rankoutput.HydraFormat:
+		return mpich.GetRankOutputArgs(cfg.RankOutput, cfg.RankOutputDir), nil
+	case rankoutput.OpenMPIFormat:
+		return openmpi.GetRankOutputArgs(cfg.RankOutput, cfg.RankOutputDir), nil
+	}
+	return nil, fmt.Errorf("per-rank output is not supported with %s", l.Name)
+}
+
+// PropagatesEnv checks whether a launcher can set environment variables for all the ranks of a job (see
+// Config.RankEnv)
+func PropagatesEnv(cfg *Config, l *Launcher) bool {
+	switch {
+	case cfg.Implem.ID == implem.MVAPICH2, isHydra(&cfg.Implem, l), l.Name == "srun":
+		return true
+	case cfg.Implem.ID == implem.OMPI, cfg.Implem.ID == implem.SPECTRUMMPI:
+		return true
+	}
+	return false
+}
+
+// GetHostfileFormat returns the format of the hostfiles expected by a given MPI implementation
+func GetHostfileFormat(myHostMPICfg *implem.Info) hostlist.Format {
+	switch myHostMPICfg.ID {
+	case implem.OMPI:
+		return hostlist.SlotsFormat
+	case implem.MPICH, implem.MVAPICH2, implem.INTELMPI:
+		return hostlist.ColonFormat
+	}
+	return hostlist.PlainFormat
+}
+
+// GenerateManifest creates the manifest of a MPI installation and saves it in its install directory
+func GenerateManifest(i *implem.Info) (*mpimanifest.Manifest, error) {
+	if i == nil || i.InstallDir == "" {
+		return nil, fmt.Errorf("invalid parameter(s)")
+	}
+	m, err := mpimanifest.Generate(i.InstallDir)
+	if err != nil {
+		return nil, err
+	}
+	m.ID = i.ID
+	m.Version = i.Version
+	err = m.Write(filepath.Join(i.InstallDir, mpimanifest.FileName))
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// VerifyManifest compares a MPI installation with the manifest saved in its install directory
+func VerifyManifest(basedir string) (*mpimanifest.Report, error) {
+	m, err := mpimanifest.Load(filepath.Join(basedir, mpimanifest.FileName))
+	if err != nil {
+		return nil, err
+	}
+	return m.Verify(basedir)
+}
+
+// CheckIntegrity checks if a given installation of MPI has been compromised, based on the manifest created by
+// GenerateManifest or, if the installation does not have one, on a mpi.MANIFEST file
 func CheckIntegrity(basedir string) error {
 	log.Println("* Checking intergrity of MPI...")
 
+	if util.FileExists(filepath.Join(basedir, mpimanifest.FileName)) {
+		r, err := VerifyManifest(basedir)
+		if err != nil {
+			return err
+		}
+		if !r.OK() {
+			return fmt.Errorf("%s does not match its manifest:\n%s", basedir, r)
+		}
+		return nil
+	}
+
 	mpiManifest := filepath.Join(basedir, "mpi.MANIFEST")
 	return manifest.Check(mpiManifest)
 }
@@ -81,7 +385,17 @@ func CheckIntegrity(basedir string) error {
 func Detect() (*implem.Info, error) {
 	mpirunPath, err := exec.LookPath("mpirun")
 	if err != nil {
-		return nil, err
+		// Vendor implementations do not necessarily provide mpirun (e.g., Cray MPICH relies on srun)
+		v, errVendor := vendormpi.DetectFromEnv(nil)
+		if errVendor != nil {
+			return nil, err
+		}
+		mpiInfo := new(implem.Info)
+		mpiInfo.ID = v.ID
+		mpiInfo.Version = v.Version
+		mpiInfo.InstallDir = v.InstallDir
+		mpiInfo.LibDir = v.LibDir
+		return mpiInfo, nil
 	}
 
 	mpiInfo := new(implem.Info)
@@ -98,6 +412,16 @@ func Detect() (*implem.Info, error) {
 
 func DetectFromDir(dir string) (implem.Info, error) {
 	var m implem.Info
+	// Vendor implementations are identified by their libraries, they must be checked first since some of them
+	// are derived from other implementations (e.g., Spectrum MPI is derived from Open MPI)
+	v, err := vendormpi.DetectFromDir(dir)
+	if err == nil {
+		m.ID = v.ID
+		m.Version = v.Version
+		m.InstallDir = dir
+		m.LibDir = v.LibDir
+		return m, nil
+	}
 	id, version, err := openmpi.DetectFromDir(dir, nil)
 	if err == nil {
 		m.ID = id
@@ -113,6 +437,14 @@ func DetectFromDir(dir string) (implem.Info, error) {
 		m.InstallDir = dir
 		return m, nil
 	}
+	// Intel MPI is also derived from MPICH
+	id, version, err = intelmpi.DetectFromDir(dir, nil)
+	if err == nil {
+		m.ID = id
+		m.Version = version
+		m.InstallDir = dir
+		return m, nil
+	}
 	id, version, err = mpich.DetectFromDir(dir, nil)
 	if err == nil {
 		m.ID = id
@@ -123,3 +455,19 @@ func DetectFromDir(dir string) (implem.Info, error) {
 
 	return m, fmt.Errorf("unable to detect any supported MPI implementation from %s", dir)
 }
+
+// SelectFromDirs detects the MPI implementations installed in a list of directories and returns the most recent one
+// that satisfies a constraint expression (e.g., openmpi>=4.1,<5). Directories without a supported MPI
+// implementation are ignored.
+func SelectFromDirs(dirs []string, constraint string) (*implem.Info, error) {
+	var installs []implem.Info
+	for _, dir := range dirs {
+		i, err := DetectFromDir(dir)
+		if err != nil {
+			log.Printf("ignoring %s: %s", dir, err)
+			continue
+		}
+		installs = append(installs, i)
+	}
+	return implem.Select(installs, constraint)
+}
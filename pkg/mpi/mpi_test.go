@@ -0,0 +1,195 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package mpi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/network"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/implem"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/placement"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/rankoutput"
+)
+
+func TestGetLaunchArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      Config
+		spec     *placement.Spec
+		launcher *Launcher
+		netCfg   *network.Config
+		hostfile string
+		expected string
+	}{
+		{
+			name: "mpich",
+			cfg: Config{
+				Implem:  implem.Info{ID: implem.MPICH},
+				BindTo:  "core",
+				MapBy:   "socket",
+				RankEnv: []string{"OMP_NUM_THREADS=2", "HOME"},
+			},
+			netCfg:   &network.Config{Device: "mlx5_0:1"},
+			hostfile: "/tmp/hosts",
+			expected: "-f /tmp/hosts -bind-to core -map-by socket -genv OMP_NUM_THREADS 2 -genvlist HOME -genv UCX_NET_DEVICES mlx5_0:1",
+		},
+		{
+			name: "mvapich2 mpirun_rsh",
+			cfg: Config{
+				Implem:         implem.Info{ID: implem.MVAPICH2},
+				UserMpirunArgs: []string{"-ssh"},
+				RankEnv:        []string{"MV2_USE_RDMA_CM=1"},
+			},
+			hostfile: "/tmp/hosts",
+			expected: "-hostfile /tmp/hosts -ssh MV2_HOMOGENEOUS_CLUSTER=1 MV2_USE_RDMA_CM=1 MV2_CPU_BINDING_POLICY=hybrid MV2_HYBRID_BINDING_POLICY=spread",
+		},
+		{
+			name: "mvapich2 hydra",
+			cfg: Config{
+				Implem:   implem.Info{ID: implem.MVAPICH2},
+				Launcher: "mpiexec.hydra",
+				RankEnv:  []string{"MV2_CPU_BINDING_POLICY=scatter", "MV2_HYBRID_BINDING_POLICY=linear"},
+			},
+			hostfile: "/tmp/hosts",
+			expected: "-f /tmp/hosts -genv MV2_HOMOGENEOUS_CLUSTER 1 -genv MV2_USE_RDMA_CM 0 -genv MV2_CPU_BINDING_POLICY scatter -genv MV2_HYBRID_BINDING_POLICY linear",
+		},
+		{
+			name:     "openmpi placement",
+			cfg:      Config{Implem: implem.Info{ID: implem.OMPI}},
+			spec:     &placement.Spec{RanksPerSocket: 2, ThreadsPerRank: 4, BindTo: placement.Core, Oversubscribe: true},
+			expected: "--map-by ppr:2:socket:PE=4 --bind-to core --oversubscribe --mca btl ^openib --mca pml ucx",
+		},
+		{
+			name:     "mpich placement",
+			cfg:      Config{Implem: implem.Info{ID: implem.MPICH}},
+			spec:     &placement.Spec{RanksPerNode: 8, ThreadsPerRank: 2},
+			expected: "-ppn 8 -bind-to core:2",
+		},
+		{
+			name:     "mvapich2 placement",
+			cfg:      Config{Implem: implem.Info{ID: implem.MVAPICH2}, RankEnv: []string{"MV2_CPU_BINDING_LEVEL=socket"}},
+			spec:     &placement.Spec{RanksPerNode: 8, BindTo: placement.Core, ThreadsPerRank: 2},
+			expected: "MV2_HOMOGENEOUS_CLUSTER=1 MV2_USE_RDMA_CM=0 MV2_CPU_BINDING_POLICY=hybrid MV2_HYBRID_BINDING_POLICY=spread MV2_CPU_BINDING_LEVEL=socket MV2_THREADS_PER_PROCESS=2",
+		},
+		{
+			name:     "openmpi environment",
+			cfg:      Config{Implem: implem.Info{ID: implem.OMPI}, RankEnv: []string{"OMP_NUM_THREADS=2", "HOME"}},
+			expected: "-x OMP_NUM_THREADS=2 -x HOME --mca btl ^openib --mca pml ucx",
+		},
+		{
+			name:     "srun environment",
+			cfg:      Config{Implem: implem.Info{ID: implem.CRAYMPICH}, RankEnv: []string{"OMP_NUM_THREADS=2", "HOME", "A=1"}},
+			launcher: &Launcher{Name: "srun", NPFlag: "-n"},
+			expected: "--export=ALL,OMP_NUM_THREADS=2,A=1",
+		},
+		{
+			name:     "srun placement",
+			cfg:      Config{Implem: implem.Info{ID: implem.CRAYMPICH}},
+			launcher: &Launcher{Name: "srun", NPFlag: "-n"},
+			spec:     &placement.Spec{RanksPerNode: 4, ThreadsPerRank: 2, BindTo: placement.NUMA},
+			expected: "--ntasks-per-node=4 --cpus-per-task=2 --distribution=block:block --cpu-bind=ldoms",
+		},
+		{
+			name:     "openmpi labelled output",
+			cfg:      Config{Implem: implem.Info{ID: implem.OMPI}, RankOutput: rankoutput.Label},
+			expected: "--tag-output --mca btl ^openib --mca pml ucx",
+		},
+		{
+			name:     "mpich per-rank output files",
+			cfg:      Config{Implem: implem.Info{ID: implem.MPICH}, RankOutput: rankoutput.Files, RankOutputDir: "/tmp/ranks"},
+			expected: "-outfile-pattern /tmp/ranks/rank.%r.out -errfile-pattern /tmp/ranks/rank.%r.err",
+		},
+		{
+			name:     "srun per-rank output files",
+			cfg:      Config{Implem: implem.Info{ID: implem.CRAYMPICH}, RankOutput: rankoutput.Files, RankOutputDir: "/tmp/ranks"},
+			launcher: &Launcher{Name: "srun", NPFlag: "-n"},
+			expected: "--output=/tmp/ranks/rank.%t.out --error=/tmp/ranks/rank.%t.err",
+		},
+	}
+
+	for _, tt := range tests {
+		l := tt.launcher
+		if l == nil {
+			var err error
+			l, err = GetLauncher(&tt.cfg)
+			if err != nil {
+				t.Fatalf("%s: GetLauncher() failed: %s", tt.name, err)
+			}
+		}
+		args, err := GetLaunchArgs(&tt.cfg, l, tt.spec, nil, nil, tt.netCfg, tt.hostfile)
+		if err != nil {
+			t.Fatalf("%s: GetLaunchArgs() failed: %s", tt.name, err)
+		}
+		if strings.Join(args, " ") != tt.expected {
+			t.Fatalf("%s: GetLaunchArgs() returned %s instead of %s", tt.name, strings.Join(args, " "), tt.expected)
+		}
+	}
+}
+
+func TestGetLaunchArgsUnsupported(t *testing.T) {
+	var cfg Config
+	cfg.Implem.ID = implem.OMPI
+	cfg.BindTo = "core"
+	l, err := GetLauncher(&cfg)
+	if err != nil {
+		t.Fatalf("GetLauncher() failed: %s", err)
+	}
+	_, err = GetLaunchArgs(&cfg, l, nil, nil, nil, nil, "")
+	if err == nil {
+		t.Fatalf("GetLaunchArgs() succeeded with an unsupported implementation")
+	}
+}
+
+func TestGetLaunchArgsPlacementErrors(t *testing.T) {
+	var cfg Config
+	cfg.Implem.ID = implem.MPICH
+	cfg.BindTo = "core"
+	l, err := GetLauncher(&cfg)
+	if err != nil {
+		t.Fatalf("GetLauncher() failed: %s", err)
+	}
+	_, err = GetLaunchArgs(&cfg, l, &placement.Spec{BindTo: placement.Socket}, nil, nil, nil, "")
+	if err == nil {
+		t.Fatalf("GetLaunchArgs() succeeded with conflicting bindings")
+	}
+	cfg.BindTo = ""
+	_, err = GetLaunchArgs(&cfg, l, &placement.Spec{RanksPerSocket: 2}, nil, nil, nil, "")
+	if err == nil {
+		t.Fatalf("GetLaunchArgs() succeeded with an unsupported placement")
+	}
+}
+
+func TestGetLaunchArgsRankOutputErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{
+			name: "mpirun_rsh",
+			cfg:  Config{Implem: implem.Info{ID: implem.MVAPICH2}, RankOutput: rankoutput.Label},
+		},
+		{
+			name: "invalid mode",
+			cfg:  Config{Implem: implem.Info{ID: implem.OMPI}, RankOutput: "ranks"},
+		},
+		{
+			name: "undefined directory",
+			cfg:  Config{Implem: implem.Info{ID: implem.OMPI}, RankOutput: rankoutput.Files},
+		},
+	}
+
+	for _, tt := range tests {
+		l, err := GetLauncher(&tt.cfg)
+		if err != nil {
+			t.Fatalf("%s: GetLauncher() failed: %s", tt.name, err)
+		}
+		_, err = GetLaunchArgs(&tt.cfg, l, nil, nil, nil, nil, "/tmp/hosts")
+		if err == nil {
+			t.Fatalf("%s: GetLaunchArgs() succeeded", tt.name)
+		}
+	}
+}
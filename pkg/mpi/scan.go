@@ -0,0 +1,307 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package mpi
+
+import (
+	"debug/elf"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/mpich"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/mvapich2"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/openmpi"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/buildinfo"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/implem"
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+const (
+	// DefaultScanDepth is the default number of directory levels explored below each root, enough for module-style
+	// layouts such as <root>/<name>/<version>/<compiler>
+	DefaultScanDepth = 4
+
+	// DefaultProbeTimeout is the default time allowed to detect the MPI implementation of a directory
+	DefaultProbeTimeout = 30 * time.Second
+)
+
+// launcherNames is the list of commands that identify the bin directory of a MPI installation
+var launcherNames = []string{"mpirun", "mpiexec", "mpirun_rsh"}
+
+// networkLibPrefixes is the list of prefixes of the network libraries reported in an inventory
+var networkLibPrefixes = []string{
+	"libucp", "libucs", "libuct", "libfabric", "libibverbs", "librdmacm", "libpsm2", "libpsm_infinipath",
+	"libpmix", "libpmi", "libpmi2", "libhcoll", "libmxm", "libugni", "libcxi", "libportals",
+}
+
+// ScanOptions specifies where and how to look for MPI installations
+type ScanOptions struct {
+	// Roots is the list of directories to explore
+	Roots []string
+
+	// UsePath specifies whether the directories of PATH are also checked
+	UsePath bool
+
+	// MaxDepth is the number of directory levels explored below each root (DefaultScanDepth by default)
+	MaxDepth int
+
+	// Concurrency is the number of directories probed at the same time (number of CPUs by default)
+	Concurrency int
+
+	// Timeout is the time allowed to probe a directory (DefaultProbeTimeout by default)
+	Timeout time.Duration
+}
+
+// isInstallDir checks whether a directory looks like the install directory of a MPI implementation, i.e., it
+// provides a launcher or a MPI library
+func isInstallDir(dir string) bool {
+	for _, name := range launcherNames {
+		if util.FileExists(filepath.Join(dir, "bin", name)) {
+			return true
+		}
+	}
+	libs, _ := filepath.Glob(filepath.Join(dir, "lib*", "libmpi*.so*"))
+	return len(libs) > 0
+}
+
+// findInstallDirs returns the directories below root that look like MPI installations. Symbolic links to
+// directories are not followed and the directories of an installation are not explored further.
+func findInstallDirs(root string, maxDepth int) ([]string, error) {
+	root = filepath.Clean(root)
+	if _, err := os.Stat(root); err != nil {
+		return nil, fmt.Errorf("unable to access %s: %w", root, err)
+	}
+
+	var dirs []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Directories we cannot read are simply skipped
+			if info != nil && info.IsDir() && path != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if isInstallDir(path) {
+			dirs = append(dirs, path)
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != "." && strings.Count(rel, string(filepath.Separator))+1 >= maxDepth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to explore %s: %w", root, err)
+	}
+	return dirs, nil
+}
+
+// findPathInstallDirs returns the install directories of the launchers available from PATH
+func findPathInstallDirs() []string {
+	var dirs []string
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if filepath.Base(dir) != "bin" {
+			continue
+		}
+		installDir := filepath.Dir(dir)
+		if isInstallDir(installDir) {
+			dirs = append(dirs, installDir)
+		}
+	}
+	return dirs
+}
+
+// getBuildInfo returns the details about how a MPI implementation was built, when the implementation reports them
+func getBuildInfo(i *implem.Info) (*buildinfo.Info, error) {
+	switch i.ID {
+	case implem.OMPI:
+		return openmpi.GetBuildInfo(i.InstallDir, nil)
+	case implem.MPICH:
+		return mpich.GetBuildInfo(i.InstallDir, nil)
+	case implem.MVAPICH2:
+		return mvapich2.GetBuildInfo(i.InstallDir, nil)
+	}
+	return nil, nil
+}
+
+func isNetworkLib(name string) bool {
+	for _, prefix := range networkLibPrefixes {
+		if strings.HasPrefix(name, prefix+".") || strings.HasPrefix(name, prefix+"-") || strings.HasPrefix(name, prefix+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+// getNetworkLibs returns the network libraries the MPI libraries of an implementation depend on. Open MPI components
+// are also checked since most network libraries are loaded by them rather than by libmpi itself.
+func getNetworkLibs(i *implem.Info) []string {
+	libDir := i.LibDir
+	if libDir == "" {
+		libDir = filepath.Join(i.InstallDir, "lib")
+	}
+	files, _ := filepath.Glob(filepath.Join(libDir, "libmpi*.so*"))
+	components, _ := filepath.Glob(filepath.Join(libDir, "openmpi", "mca_*.so"))
+	files = append(files, components...)
+
+	var libs []string
+	seen := make(map[string]bool)
+	for _, f := range files {
+		ef, err := elf.Open(f)
+		if err != nil {
+			continue
+		}
+		needed, err := ef.ImportedLibraries()
+		ef.Close()
+		if err != nil {
+			continue
+		}
+		for _, lib := range needed {
+			if isNetworkLib(lib) && !seen[lib] {
+				seen[lib] = true
+				libs = append(libs, lib)
+			}
+		}
+	}
+	sort.Strings(libs)
+	return libs
+}
+
+// Inspect completes the details of a detected MPI implementation: launcher, build details and network libraries.
+// Details that cannot be figured out are left empty.
+func Inspect(i *implem.Info) error {
+	if i == nil {
+		return fmt.Errorf("invalid parameter(s)")
+	}
+
+	l, err := GetLauncher(&Config{Implem: *i})
+	if err == nil && util.FileExists(l.Path) {
+		i.Launcher = l.Path
+	}
+	i.Build, err = getBuildInfo(i)
+	if err != nil {
+		log.Printf("unable to get the build details of %s: %s", i.InstallDir, err)
+	}
+	if i.Build != nil {
+		i.ConfigureOptions = i.Build.ConfigureFlags
+	}
+	i.NetworkLibs = getNetworkLibs(i)
+	return nil
+}
+
+// probe detects and inspects the MPI implementation installed in a directory. Commands cannot be interrupted so
+// if the probe does not complete in time, it is abandoned and left to complete in the background.
+func probe(dir string, timeout time.Duration) *implem.Info {
+	done := make(chan *implem.Info, 1)
+	go func() {
+		info, err := DetectFromDir(dir)
+		if err != nil {
+			done <- nil
+			return
+		}
+		Inspect(&info)
+		done <- &info
+	}()
+
+	select {
+	case info := <-done:
+		return info
+	case <-time.After(timeout):
+		log.Printf("detection of the MPI implementation in %s timed out after %s", dir, timeout)
+		return nil
+	}
+}
+
+// Scan looks for all the MPI implementations installed under a set of directories and returns their details,
+// sorted by install directory
+func Scan(opts *ScanOptions) ([]implem.Info, error) {
+	if opts == nil {
+		return nil, fmt.Errorf("invalid parameter(s)")
+	}
+	maxDepth := opts.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultScanDepth
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultProbeTimeout
+	}
+
+	var candidates []string
+	for _, root := range opts.Roots {
+		dirs, err := findInstallDirs(root, maxDepth)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, dirs...)
+	}
+	if opts.UsePath {
+		candidates = append(candidates, findPathInstallDirs()...)
+	}
+
+	// The same installation can be reached from several roots or through symbolic links
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		path, err := filepath.EvalSymlinks(c)
+		if err != nil {
+			path = filepath.Clean(c)
+		}
+		if !seen[path] {
+			seen[path] = true
+			dirs = append(dirs, path)
+		}
+	}
+
+	jobs := make(chan string)
+	results := make(chan *implem.Info)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for dir := range jobs {
+				if info := probe(dir, timeout); info != nil {
+					results <- info
+				}
+			}
+		}()
+	}
+	go func() {
+		for _, dir := range dirs {
+			jobs <- dir
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	var inventory []implem.Info
+	for info := range results {
+		inventory = append(inventory, *info)
+	}
+	sort.Slice(inventory, func(a, b int) bool {
+		return inventory[a].InstallDir < inventory[b].InstallDir
+	})
+	return inventory, nil
+}
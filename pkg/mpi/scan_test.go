@@ -0,0 +1,103 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package mpi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/testutil"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/implem"
+)
+
+// writeScript creates an executable shell script in the bin directory of a fake installation
+func writeScript(t *testing.T, installDir string, name string, content string) {
+	testutil.WriteFile(t, filepath.Join(installDir, "bin", name), "#!/bin/sh\n"+content+"\n", 0755)
+}
+
+func TestScan(t *testing.T) {
+	root, err := ioutil.TempDir("", "mpi-scan-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	ompiDir := filepath.Join(root, "openmpi", "4.1.5")
+	writeScript(t, ompiDir, "mpirun", "exit 0")
+	writeScript(t, ompiDir, "ompi_info", `if [ "$1" = "-c" ]; then
+  echo "  Configure command line: '--prefix=`+ompiDir+`' '--with-ucx'"
+else
+  echo "Open MPI v4.1.5"
+fi`)
+
+	mpichDir := filepath.Join(root, "mpich", "4.1.2", "gcc-12")
+	writeScript(t, mpichDir, "mpirun", `echo "HYDRA build details:"
+echo "    Version:                                 4.1.2"
+echo "    Configure options:                       '--prefix=`+mpichDir+`' '--with-device=ch4:ofi'"`)
+
+	// Installations that are too slow to answer, hidden or too deep are ignored
+	writeScript(t, filepath.Join(root, "slow", "1.0"), "ompi_info", "sleep 5")
+	writeScript(t, filepath.Join(root, "slow", "1.0"), "mpirun", "exit 0")
+	writeScript(t, filepath.Join(root, ".hidden", "1.0"), "mpirun", "exit 1")
+	writeScript(t, filepath.Join(root, "a", "b", "c", "d", "e"), "mpirun", "exit 1")
+	err = os.MkdirAll(filepath.Join(root, "empty", "1.0"), 0755)
+	if err != nil {
+		t.Fatalf("unable to create directory: %s", err)
+	}
+
+	opts := ScanOptions{
+		Roots:       []string{root, filepath.Join(root, "openmpi")},
+		Concurrency: 2,
+		Timeout:     time.Second,
+	}
+	inventory, err := Scan(&opts)
+	if err != nil {
+		t.Fatalf("Scan() failed: %s", err)
+	}
+	if len(inventory) != 2 {
+		t.Fatalf("Scan() found %d installations instead of 2: %v", len(inventory), inventory)
+	}
+
+	expected := []implem.Info{
+		{ID: implem.MPICH, Version: "4.1.2", InstallDir: mpichDir},
+		{ID: implem.OMPI, Version: "4.1.5", InstallDir: ompiDir},
+	}
+	for idx, e := range expected {
+		i := inventory[idx]
+		installDir, _ := filepath.EvalSymlinks(e.InstallDir)
+		if i.ID != e.ID || i.Version != e.Version || i.InstallDir != installDir {
+			t.Fatalf("Scan() returned %s %s in %s instead of %s %s in %s", i.ID, i.Version, i.InstallDir, e.ID, e.Version, installDir)
+		}
+		if i.Launcher != filepath.Join(installDir, "bin", "mpirun") {
+			t.Fatalf("invalid launcher for %s: %s", i.ID, i.Launcher)
+		}
+		if len(i.ConfigureOptions) != 2 || i.ConfigureOptions[0] != "--prefix="+e.InstallDir {
+			t.Fatalf("invalid configure options for %s: %s", i.ID, strings.Join(i.ConfigureOptions, " "))
+		}
+	}
+
+	_, err = Scan(&ScanOptions{Roots: []string{filepath.Join(root, "does-not-exist")}})
+	if err == nil {
+		t.Fatalf("Scan() succeeded with an invalid root")
+	}
+}
+
+func TestIsNetworkLib(t *testing.T) {
+	for _, lib := range []string{"libucp.so.0", "libfabric.so.1", "libibverbs.so.1", "libpmix.so.2"} {
+		if !isNetworkLib(lib) {
+			t.Fatalf("%s is not detected as a network library", lib)
+		}
+	}
+	for _, lib := range []string{"libc.so.6", "libpmi2dummy", "libucx-foo"} {
+		if isNetworkLib(lib) {
+			t.Fatalf("%s is detected as a network library", lib)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package mpimanifest creates and verifies manifests of MPI installations, i.e., the list of the files of an
+// installation with their checksum, so that modified installations can be detected
+package mpimanifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// FileName is the name of the manifest in the install directory of a MPI implementation
+	FileName = "mpi.manifest.json"
+)
+
+// Dirs is the list of directories of an installation that are included in a manifest
+var Dirs = []string{"bin", "lib", "lib64"}
+
+// Entry is a file of a manifest
+type Entry struct {
+	// Path is the path of the file relative to the install directory
+	Path string
+
+	// SHA256 is the SHA-256 checksum of the content of the file
+	SHA256 string
+
+	// Size is the size of the file in bytes
+	Size int64
+}
+
+// Manifest describes the content of a MPI installation
+type Manifest struct {
+	// ID is the identifier of the MPI implementation
+	ID string
+
+	// Version is the version of the MPI implementation
+	Version string
+
+	// InstallDir is the directory the manifest was generated from
+	InstallDir string
+
+	// Timestamp is the time the manifest was generated at
+	Timestamp time.Time
+
+	// Files is the list of files of the installation, sorted by path
+	Files []Entry
+}
+
+// Report is the result of the verification of an installation against its manifest
+type Report struct {
+	// Missing is the list of files of the manifest that do not exist anymore
+	Missing []string
+
+	// Modified is the list of files whose content does not match the manifest
+	Modified []string
+
+	// Extra is the list of files that are not in the manifest
+	Extra []string
+}
+
+// OK checks whether the installation matches its manifest
+func (r *Report) OK() bool {
+	return len(r.Missing) == 0 && len(r.Modified) == 0 && len(r.Extra) == 0
+}
+
+// String returns a human-readable version of the report
+func (r *Report) String() string {
+	if r.OK() {
+		return "installation matches its manifest"
+	}
+	var lines []string
+	for _, f := range r.Missing {
+		lines = append(lines, "missing: "+f)
+	}
+	for _, f := range r.Modified {
+		lines = append(lines, "modified: "+f)
+	}
+	for _, f := range r.Extra {
+		lines = append(lines, "extra: "+f)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func hashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// listFiles returns the entries of the files of an installation. Symbolic links are followed, links to
+// directories are ignored.
+func listFiles(dir string) ([]Entry, error) {
+	var entries []Entry
+	for _, d := range Dirs {
+		root := filepath.Join(dir, d)
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			if info.Mode()&os.ModeSymlink != 0 {
+				target, err := os.Stat(path)
+				if err != nil || target.IsDir() {
+					return nil
+				}
+			}
+			sum, size, err := hashFile(path)
+			if err != nil {
+				return fmt.Errorf("unable to read %s: %w", path, err)
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, Entry{Path: rel, SHA256: sum, Size: size})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].Path < entries[b].Path
+	})
+	return entries, nil
+}
+
+// Generate creates the manifest of the installation in a given directory
+func Generate(dir string) (*Manifest, error) {
+	files, err := listFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the files of %s: %w", dir, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file found in %s", dir)
+	}
+
+	m := new(Manifest)
+	m.InstallDir = dir
+	m.Timestamp = time.Now()
+	m.Files = files
+	return m, nil
+}
+
+// Write saves a manifest to a file
+func (m *Manifest) Write(path string) error {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode manifest: %w", err)
+	}
+	err = ioutil.WriteFile(path, content, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write to file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Load reads a manifest from a file
+func Load(path string) (*Manifest, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	m := new(Manifest)
+	err = json.Unmarshal(content, m)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Verify compares the installation in a given directory with a manifest
+func (m *Manifest) Verify(dir string) (*Report, error) {
+	files, err := listFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the files of %s: %w", dir, err)
+	}
+
+	current := make(map[string]Entry)
+	for _, f := range files {
+		current[f.Path] = f
+	}
+
+	r := new(Report)
+	for _, expected := range m.Files {
+		f, ok := current[expected.Path]
+		if !ok {
+			r.Missing = append(r.Missing, expected.Path)
+			continue
+		}
+		delete(current, expected.Path)
+		if f.SHA256 != expected.SHA256 || f.Size != expected.Size {
+			r.Modified = append(r.Modified, expected.Path)
+		}
+	}
+	for path := range current {
+		r.Extra = append(r.Extra, path)
+	}
+	sort.Strings(r.Extra)
+	return r, nil
+}
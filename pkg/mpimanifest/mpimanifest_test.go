@@ -0,0 +1,72 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package mpimanifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/testutil"
+)
+
+func TestGenerateAndVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mpimanifest-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	testutil.WriteFile(t, filepath.Join(dir, "bin", "mpirun"), "mpirun", 0755)
+	testutil.WriteFile(t, filepath.Join(dir, "bin", "mpicc"), "mpicc", 0755)
+	testutil.WriteFile(t, filepath.Join(dir, "lib", "libmpi.so.40.30.5"), "libmpi", 0755)
+	testutil.WriteFile(t, filepath.Join(dir, "share", "doc"), "not in the manifest", 0755)
+	err = os.Symlink("libmpi.so.40.30.5", filepath.Join(dir, "lib", "libmpi.so.40"))
+	if err != nil {
+		t.Fatalf("unable to create symbolic link: %s", err)
+	}
+
+	m, err := Generate(dir)
+	if err != nil {
+		t.Fatalf("Generate() failed: %s", err)
+	}
+	if len(m.Files) != 4 {
+		t.Fatalf("Generate() returned %d files instead of 4", len(m.Files))
+	}
+
+	path := filepath.Join(dir, FileName)
+	err = m.Write(path)
+	if err != nil {
+		t.Fatalf("Write() failed: %s", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() failed: %s", err)
+	}
+	r, err := loaded.Verify(dir)
+	if err != nil {
+		t.Fatalf("Verify() failed: %s", err)
+	}
+	if !r.OK() {
+		t.Fatalf("Verify() reported differences on an unmodified installation: %s", r)
+	}
+
+	testutil.WriteFile(t, filepath.Join(dir, "bin", "mpirun"), "tampered mpirun", 0755)
+	testutil.WriteFile(t, filepath.Join(dir, "lib", "libextra.so"), "extra", 0755)
+	os.Remove(filepath.Join(dir, "bin", "mpicc"))
+	r, err = loaded.Verify(dir)
+	if err != nil {
+		t.Fatalf("Verify() failed: %s", err)
+	}
+	if r.OK() {
+		t.Fatalf("Verify() did not detect the modifications")
+	}
+	if strings.Join(r.Missing, " ") != "bin/mpicc" || strings.Join(r.Modified, " ") != "bin/mpirun" || strings.Join(r.Extra, " ") != "lib/libextra.so" {
+		t.Fatalf("Verify() returned an invalid report:\n%s", r)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package placement describes how the ranks of a job are mapped and bound to the resources of the nodes,
+// independently of the MPI implementation used to start them
+package placement
+
+import "fmt"
+
+// Unit is a hardware resource processes can be bound to
+type Unit string
+
+const (
+	// None disables binding
+	None Unit = "none"
+
+	// HWThread binds processes to hardware threads
+	HWThread Unit = "hwthread"
+
+	// Core binds processes to cores
+	Core Unit = "core"
+
+	// Socket binds processes to sockets
+	Socket Unit = "socket"
+
+	// NUMA binds processes to NUMA domains
+	NUMA Unit = "numa"
+)
+
+// Spec specifies the placement of the ranks of a job
+type Spec struct {
+	// RanksPerNode is the number of ranks to start on each node (optional)
+	RanksPerNode int
+
+	// RanksPerSocket is the number of ranks to start on each socket (optional)
+	RanksPerSocket int
+
+	// BindTo is the unit each rank is bound to (optional)
+	BindTo Unit
+
+	// ThreadsPerRank is the number of threads, and therefore of cores, used by each rank (optional)
+	ThreadsPerRank int
+
+	// Oversubscribe allows more ranks than available cores on a node
+	Oversubscribe bool
+}
+
+// IsEmpty checks whether a spec leaves the placement of the ranks to the defaults of the launcher
+func (s *Spec) IsEmpty() bool {
+	return s == nil || *s == Spec{}
+}
+
+// Validate checks that a spec is consistent and compatible with a number of ranks and nodes. np and nnodes are
+// ignored when they are not positive.
+func (s *Spec) Validate(np int, nnodes int) error {
+	if s == nil {
+		return nil
+	}
+
+	if s.RanksPerNode < 0 || s.RanksPerSocket < 0 || s.ThreadsPerRank < 0 {
+		return fmt.Errorf("negative number of ranks or threads")
+	}
+	switch s.BindTo {
+	case "", None, HWThread, Core, Socket, NUMA:
+	default:
+		return fmt.Errorf("unknown binding unit %s", s.BindTo)
+	}
+	if s.RanksPerNode > 0 && s.RanksPerSocket > s.RanksPerNode {
+		return fmt.Errorf("%d ranks per socket exceed the %d ranks per node", s.RanksPerSocket, s.RanksPerNode)
+	}
+
+	if s.RanksPerNode > 0 && np > 0 && nnodes > 0 {
+		if np > s.RanksPerNode*nnodes {
+			return fmt.Errorf("%d ranks cannot be started on %d nodes with %d ranks per node", np, nnodes, s.RanksPerNode)
+		}
+		if np <= s.RanksPerNode*(nnodes-1) {
+			return fmt.Errorf("%d ranks with %d ranks per node do not use all the %d nodes", np, s.RanksPerNode, nnodes)
+		}
+	}
+
+	return nil
+}
+
+// RanksPerNodeFor returns the number of ranks to start on each node: the number specified in the spec, or the
+// number required to spread np ranks evenly across nnodes nodes. It returns 0 if it cannot be figured out.
+func (s *Spec) RanksPerNodeFor(np int, nnodes int) int {
+	if s != nil && s.RanksPerNode > 0 {
+		return s.RanksPerNode
+	}
+	if np <= 0 || nnodes <= 0 {
+		return 0
+	}
+	return (np + nnodes - 1) / nnodes
+}
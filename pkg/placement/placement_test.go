@@ -0,0 +1,55 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package placement
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   *Spec
+		np     int
+		nnodes int
+		valid  bool
+	}{
+		{name: "nil", spec: nil, np: 4, nnodes: 2, valid: true},
+		{name: "even", spec: &Spec{RanksPerNode: 2, BindTo: Core}, np: 4, nnodes: 2, valid: true},
+		{name: "partial last node", spec: &Spec{RanksPerNode: 3}, np: 5, nnodes: 2, valid: true},
+		{name: "unknown node count", spec: &Spec{RanksPerNode: 3}, np: 5, nnodes: 0, valid: true},
+		{name: "too many ranks", spec: &Spec{RanksPerNode: 2}, np: 5, nnodes: 2, valid: false},
+		{name: "idle node", spec: &Spec{RanksPerNode: 4}, np: 4, nnodes: 2, valid: false},
+		{name: "sockets", spec: &Spec{RanksPerNode: 2, RanksPerSocket: 4}, np: 4, nnodes: 2, valid: false},
+		{name: "unit", spec: &Spec{BindTo: "board"}, np: 4, nnodes: 2, valid: false},
+		{name: "negative", spec: &Spec{ThreadsPerRank: -1}, np: 4, nnodes: 2, valid: false},
+	}
+
+	for _, tt := range tests {
+		err := tt.spec.Validate(tt.np, tt.nnodes)
+		if tt.valid && err != nil {
+			t.Fatalf("%s: Validate() failed: %s", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Fatalf("%s: Validate() succeeded with an invalid spec", tt.name)
+		}
+	}
+}
+
+func TestRanksPerNodeFor(t *testing.T) {
+	var s *Spec
+	if n := s.RanksPerNodeFor(5, 2); n != 3 {
+		t.Fatalf("RanksPerNodeFor() returned %d instead of 3", n)
+	}
+	if n := s.RanksPerNodeFor(5, 0); n != 0 {
+		t.Fatalf("RanksPerNodeFor() returned %d instead of 0", n)
+	}
+	s = &Spec{RanksPerNode: 4}
+	if n := s.RanksPerNodeFor(5, 2); n != 4 {
+		t.Fatalf("RanksPerNodeFor() returned %d instead of 4", n)
+	}
+	if !new(Spec).IsEmpty() || s.IsEmpty() {
+		t.Fatalf("IsEmpty() returned an invalid result")
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package rankoutput splits the output of MPI jobs per rank, from the labelled output of the launcher or from the
+// per-rank output files it creates
+package rankoutput
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Label requests the launcher to prefix each line of the output with the rank that printed it
+	Label = "label"
+
+	// Files requests the launcher to write the output of each rank to its own files
+	Files = "files"
+
+	// OpenMPIFormat is the format of the labels of Open MPI (--tag-output), e.g., [1,0]<stdout>:hello
+	OpenMPIFormat = "openmpi"
+
+	// HydraFormat is the format of the labels of Hydra (-prepend-rank), e.g., [0] hello
+	HydraFormat = "hydra"
+
+	// SrunFormat is the format of the labels of srun (--label), e.g., 0: hello
+	SrunFormat = "srun"
+
+	// Unlabelled is the rank of the lines that no rank printed, e.g., messages from the launcher
+	Unlabelled = -1
+
+	// StdoutSuffix is the suffix of the files with the output of a rank, e.g., rank.0.out
+	StdoutSuffix = ".out"
+
+	// StderrSuffix is the suffix of the files with stderr of a rank, e.g., rank.0.err
+	StderrSuffix = ".err"
+
+	// filePrefix is the prefix of the files with the output of a rank
+	filePrefix = "rank."
+)
+
+// labelFormats gives the regular expression matching a labelled line for each format; the first group is the rank
+// and the second one the line as printed by the rank
+var labelFormats = map[string]*regexp.Regexp{
+	OpenMPIFormat: regexp.MustCompile(`^\[\d+,(\d+)\]<std(?:out|err|diag)>: ?(.*)$`),
+	HydraFormat:   regexp.MustCompile(`^\[(\d+)\] ?(.*)$`),
+	SrunFormat:    regexp.MustCompile(`^\s*(\d+): ?(.*)$`),
+}
+
+// Validate checks that a per-rank output mode is valid
+func Validate(mode string) error {
+	switch mode {
+	case "", Label, Files:
+		return nil
+	}
+	return fmt.Errorf("invalid per-rank output mode %s", mode)
+}
+
+// FilePattern returns the pattern of the per-rank output files in a directory, for launchers that let us name them
+// from a placeholder replaced by the rank (e.g., %t with srun, %r with Hydra)
+func FilePattern(dir string, rankPlaceholder string, suffix string) string {
+	return filepath.Join(dir, filePrefix+rankPlaceholder+suffix)
+}
+
+// Parse splits labelled outputs per rank. Lines without label are associated to Unlabelled.
+func Parse(format string, outputs ...string) (map[int][]string, error) {
+	re, ok := labelFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported output format %s", format)
+	}
+
+	ranks := make(map[int][]string)
+	for _, output := range outputs {
+		scanner := bufio.NewScanner(strings.NewReader(output))
+		scanner.Buffer(nil, 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			rank := Unlabelled
+			if m := re.FindStringSubmatch(line); m != nil {
+				// The rank always fits since the expression only matches digits
+				rank, _ = strconv.Atoi(m[1])
+				line = m[2]
+			}
+			ranks[rank] = append(ranks[rank], line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("unable to parse the output: %w", err)
+		}
+	}
+	return ranks, nil
+}
+
+// rankFile is a file with the output of a rank
+type rankFile struct {
+	rank   int
+	stderr bool
+	path   string
+}
+
+// getRankFile checks whether a file of a per-rank output directory has the output of a rank. Files are either named
+// after the rank (e.g., rank.3.out, rank.3.err) or in a directory named after the rank (e.g., Open MPI's
+// 1/rank.3/stdout).
+func getRankFile(path string) (*rankFile, bool) {
+	name := filepath.Base(path)
+	var rankStr string
+	f := &rankFile{path: path}
+	switch {
+	case strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, StdoutSuffix):
+		rankStr = strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), StdoutSuffix)
+	case strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, StderrSuffix):
+		rankStr = strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), StderrSuffix)
+		f.stderr = true
+	case name == "stdout" || name == "stderr":
+		parent := filepath.Base(filepath.Dir(path))
+		if !strings.HasPrefix(parent, filePrefix) {
+			return nil, false
+		}
+		rankStr = strings.TrimPrefix(parent, filePrefix)
+		f.stderr = name == "stderr"
+	default:
+		return nil, false
+	}
+	rank, err := strconv.Atoi(rankStr)
+	if err != nil || rank < 0 {
+		return nil, false
+	}
+	f.rank = rank
+	return f, true
+}
+
+// ParseDir reads the per-rank output files of a directory. The lines of stdout of a rank come before the lines of its
+// stderr.
+func ParseDir(dir string) (map[int][]string, error) {
+	var files []*rankFile
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if f, ok := getRankFile(path); ok {
+			files = append(files, f)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the per-rank output files of %s: %w", dir, err)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].rank != files[j].rank {
+			return files[i].rank < files[j].rank
+		}
+		return !files[i].stderr && files[j].stderr
+	})
+
+	ranks := make(map[int][]string)
+	for _, f := range files {
+		content, err := ioutil.ReadFile(f.path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", f.path, err)
+		}
+		text := strings.TrimSuffix(string(content), "\n")
+		if text == "" {
+			// Ranks that did not print anything are still reported
+			if _, ok := ranks[f.rank]; !ok {
+				ranks[f.rank] = nil
+			}
+			continue
+		}
+		ranks[f.rank] = append(ranks[f.rank], strings.Split(text, "\n")...)
+	}
+	return ranks, nil
+}
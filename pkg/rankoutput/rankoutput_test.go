@@ -0,0 +1,132 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package rankoutput
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		outputs  []string
+		expected map[int][]string
+	}{
+		{
+			name:    "openmpi",
+			format:  OpenMPIFormat,
+			outputs: []string{"[1,0]<stdout>:hello\n[1,1]<stdout>:world\n", "[1,1]<stderr>:oops\nlauncher error\n"},
+			expected: map[int][]string{
+				0:          {"hello"},
+				1:          {"world", "oops"},
+				Unlabelled: {"launcher error"},
+			},
+		},
+		{
+			name:    "hydra",
+			format:  HydraFormat,
+			outputs: []string{"[0] hello\n[12] world\n[0] again"},
+			expected: map[int][]string{
+				0:  {"hello", "again"},
+				12: {"world"},
+			},
+		},
+		{
+			name:    "srun",
+			format:  SrunFormat,
+			outputs: []string{" 0: hello\n10: world\n", "srun: error: node1: task 10: Exited\n"},
+			expected: map[int][]string{
+				0:          {"hello"},
+				10:         {"world"},
+				Unlabelled: {"srun: error: node1: task 10: Exited"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		ranks, err := Parse(tt.format, tt.outputs...)
+		if err != nil {
+			t.Fatalf("%s: Parse() failed: %s", tt.name, err)
+		}
+		if !reflect.DeepEqual(ranks, tt.expected) {
+			t.Fatalf("%s: Parse() returned %v instead of %v", tt.name, ranks, tt.expected)
+		}
+	}
+
+	_, err := Parse("unknown", "")
+	if err == nil {
+		t.Fatalf("Parse() succeeded with an unknown format")
+	}
+}
+
+func TestParseDir(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected map[int][]string
+	}{
+		{
+			name: "rank files",
+			files: map[string]string{
+				"rank.0.out":  "hello\n",
+				"rank.0.err":  "oops\n",
+				"rank.1.out":  "",
+				"rank.1.err":  "",
+				"rank.10.out": "a\nb\n",
+				"other.txt":   "ignored\n",
+			},
+			expected: map[int][]string{
+				0:  {"hello", "oops"},
+				1:  nil,
+				10: {"a", "b"},
+			},
+		},
+		{
+			name: "openmpi",
+			files: map[string]string{
+				"1/rank.0/stdout": "hello\n",
+				"1/rank.1/stdout": "world\n",
+				"1/rank.1/stderr": "oops\n",
+			},
+			expected: map[int][]string{
+				0: {"hello"},
+				1: {"world", "oops"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		dir, err := ioutil.TempDir("", "rankoutput-")
+		if err != nil {
+			t.Fatalf("unable to create temporary directory: %s", err)
+		}
+		defer os.RemoveAll(dir)
+		for name, content := range tt.files {
+			path := filepath.Join(dir, name)
+			err = os.MkdirAll(filepath.Dir(path), 0755)
+			if err != nil {
+				t.Fatalf("unable to create %s: %s", filepath.Dir(path), err)
+			}
+			err = ioutil.WriteFile(path, []byte(content), 0644)
+			if err != nil {
+				t.Fatalf("unable to write %s: %s", path, err)
+			}
+		}
+
+		ranks, err := ParseDir(dir)
+		if err != nil {
+			t.Fatalf("%s: ParseDir() failed: %s", tt.name, err)
+		}
+		if !reflect.DeepEqual(ranks, tt.expected) {
+			t.Fatalf("%s: ParseDir() returned %v instead of %v", tt.name, ranks, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package spack resolves MPI implementations and applications installed with Spack
+package spack
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/app"
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/implem"
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+// mpiPackages gives the ID of the MPI implementations provided by Spack packages
+var mpiPackages = map[string]string{
+	"openmpi":          implem.OMPI,
+	"mpich":            implem.MPICH,
+	"mvapich2":         implem.MVAPICH2,
+	"intel-mpi":        implem.INTELMPI,
+	"intel-oneapi-mpi": implem.INTELMPI,
+	"cray-mpich":       implem.CRAYMPICH,
+	"spectrum-mpi":     implem.SPECTRUMMPI,
+	"hpe-mpt":          implem.HPEMPT,
+}
+
+// Spack is a Spack installation
+type Spack struct {
+	// Cmd is the path to the spack command
+	Cmd string
+}
+
+// Package is an installed Spack package
+type Package struct {
+	// Name is the name of the package (e.g., openmpi)
+	Name string
+
+	// Version is the version of the package
+	Version string
+
+	// Hash is the hash identifying the installation
+	Hash string
+
+	// Prefix is the directory where the package is installed
+	Prefix string
+
+	// Compiler is the compiler used to build the package (e.g., gcc@12.2.0), when known
+	Compiler string
+}
+
+// spec is the subset of the description of a spec given by 'spack find --json' we rely on
+type spec struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Hash     string `json:"hash"`
+	Prefix   string `json:"prefix"`
+	Compiler *struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"compiler"`
+}
+
+// Detect looks for the spack command, from SPACK_ROOT first and then PATH
+func Detect() (*Spack, error) {
+	if root := os.Getenv("SPACK_ROOT"); root != "" {
+		cmd := filepath.Join(root, "bin", "spack")
+		if util.FileExists(cmd) {
+			return &Spack{Cmd: cmd}, nil
+		}
+	}
+	cmd, err := exec.LookPath("spack")
+	if err != nil {
+		return nil, fmt.Errorf("spack not found")
+	}
+	return &Spack{Cmd: cmd}, nil
+}
+
+func (s *Spack) run(args ...string) advexec.Result {
+	var cmd advexec.Advcmd
+	cmd.BinPath = s.Cmd
+	cmd.CmdArgs = args
+	return cmd.Run()
+}
+
+// parseFindOutput parses the output of 'spack find --json'
+func parseFindOutput(output string) ([]Package, error) {
+	var specs []spec
+	err := json.Unmarshal([]byte(output), &specs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output: %w", err)
+	}
+	var pkgs []Package
+	for _, sp := range specs {
+		p := Package{
+			Name:    sp.Name,
+			Version: sp.Version,
+			Hash:    sp.Hash,
+			Prefix:  sp.Prefix,
+		}
+		if sp.Compiler != nil && sp.Compiler.Name != "" {
+			p.Compiler = sp.Compiler.Name
+			if sp.Compiler.Version != "" {
+				p.Compiler += "@" + sp.Compiler.Version
+			}
+		}
+		pkgs = append(pkgs, p)
+	}
+	return pkgs, nil
+}
+
+// Find returns the installed packages matching a spec (e.g., openmpi@4.1.5 %gcc)
+func (s *Spack) Find(specExpr string) ([]Package, error) {
+	args := append([]string{"find", "--json"}, strings.Fields(specExpr)...)
+	res := s.run(args...)
+	if res.Err != nil {
+		// spack find fails when nothing matches
+		if strings.Contains(res.Stdout+res.Stderr, "No package matches") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to execute spack find: %w - stderr: %s", res.Err, res.Stderr)
+	}
+	pkgs, err := parseFindOutput(res.Stdout)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the output of spack find: %w", err)
+	}
+	return pkgs, nil
+}
+
+// Location returns the directory where the package of a given hash is installed
+func (s *Spack) Location(hash string) (string, error) {
+	res := s.run("location", "-i", "/"+hash)
+	if res.Err != nil {
+		return "", fmt.Errorf("unable to execute spack location: %w - stderr: %s", res.Err, res.Stderr)
+	}
+	prefix := strings.TrimSpace(res.Stdout)
+	if prefix == "" {
+		return "", fmt.Errorf("spack location did not return any directory for /%s", hash)
+	}
+	return prefix, nil
+}
+
+// Resolve returns the only installed package matching a spec
+func (s *Spack) Resolve(specExpr string) (*Package, error) {
+	pkgs, err := s.Find(specExpr)
+	if err != nil {
+		return nil, err
+	}
+	switch len(pkgs) {
+	case 0:
+		return nil, fmt.Errorf("no installed package matches %s", specExpr)
+	case 1:
+	default:
+		var candidates []string
+		for _, p := range pkgs {
+			candidates = append(candidates, fmt.Sprintf("%s@%s/%s", p.Name, p.Version, p.Hash))
+		}
+		return nil, fmt.Errorf("%s matches several installed packages: %s", specExpr, strings.Join(candidates, ", "))
+	}
+
+	p := pkgs[0]
+	// Older versions of Spack do not give the prefix of the packages
+	if p.Prefix == "" {
+		p.Prefix, err = s.Location(p.Hash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
+
+// MPI returns the MPI implementation installed by Spack that matches a spec
+func (s *Spack) MPI(specExpr string) (*implem.Info, error) {
+	p, err := s.Resolve(specExpr)
+	if err != nil {
+		return nil, err
+	}
+	id, ok := mpiPackages[p.Name]
+	if !ok {
+		return nil, fmt.Errorf("%s is not a MPI implementation", p.Name)
+	}
+
+	i := &implem.Info{
+		ID:         id,
+		Version:    p.Version,
+		InstallDir: p.Prefix,
+		SpackHash:  p.Hash,
+	}
+	// Intel oneAPI packages install MPI in a sub-directory
+	if p.Name == "intel-oneapi-mpi" {
+		dir := filepath.Join(p.Prefix, "mpi", p.Version)
+		if util.PathExists(dir) {
+			i.InstallDir = dir
+		}
+	}
+	return i, nil
+}
+
+// App returns the application installed by Spack that matches a spec. binName is the name of the binary to run,
+// the name of the package by default.
+func (s *Spack) App(specExpr string, binName string) (*app.Info, error) {
+	p, err := s.Resolve(specExpr)
+	if err != nil {
+		return nil, err
+	}
+	if binName == "" {
+		binName = p.Name
+	}
+	binPath := filepath.Join(p.Prefix, "bin", binName)
+	if !util.FileExists(binPath) {
+		return nil, fmt.Errorf("%s does not exist", binPath)
+	}
+	return &app.Info{
+		Name:      p.Name,
+		BinName:   binName,
+		BinPath:   binPath,
+		SpackHash: p.Hash,
+	}, nil
+}
+
+// ScriptCmds returns the shell commands loading packages in a script from their hashes. The commands do not rely on
+// the shell integration of Spack being set up.
+func (s *Spack) ScriptCmds(hashes []string) string {
+	cmds := ""
+	for _, h := range hashes {
+		cmds += fmt.Sprintf("eval \"$(%s load --sh /%s)\"\n", s.Cmd, h)
+	}
+	return cmds
+}
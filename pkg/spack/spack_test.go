@@ -0,0 +1,108 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package spack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/pkg/implem"
+)
+
+// fakeSpack mimics spack find --json and spack location; the prefix of openmpi is only given by spack location
+const fakeSpack = `#!/bin/sh
+case "$1" in
+find)
+	case "$3" in
+	openmpi*)
+		echo '[{"name": "openmpi", "version": "4.1.5", "hash": "abcdef", "compiler": {"name": "gcc", "version": "12.2.0"}}]'
+		;;
+	osu-micro-benchmarks*)
+		echo '[{"name": "osu-micro-benchmarks", "version": "7.2", "hash": "123456", "prefix": "PREFIX/osu"}]'
+		;;
+	mpich*)
+		echo '[{"name": "mpich", "version": "4.1.2", "hash": "aaaaaa"}, {"name": "mpich", "version": "4.1.2", "hash": "bbbbbb"}]'
+		;;
+	*)
+		echo "==> No package matches the query: $3" >&2
+		exit 1
+		;;
+	esac
+	;;
+location)
+	echo "PREFIX/openmpi"
+	;;
+esac
+`
+
+func TestSpack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spack-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmd := filepath.Join(dir, "spack")
+	err = ioutil.WriteFile(cmd, []byte(strings.ReplaceAll(fakeSpack, "PREFIX", dir)), 0755)
+	if err != nil {
+		t.Fatalf("unable to create %s: %s", cmd, err)
+	}
+	binDir := filepath.Join(dir, "osu", "bin")
+	err = os.MkdirAll(binDir, 0755)
+	if err != nil {
+		t.Fatalf("unable to create %s: %s", binDir, err)
+	}
+	err = ioutil.WriteFile(filepath.Join(binDir, "osu_latency"), nil, 0755)
+	if err != nil {
+		t.Fatalf("unable to create osu_latency: %s", err)
+	}
+	s := &Spack{Cmd: cmd}
+
+	i, err := s.MPI("openmpi@4.1.5 %gcc")
+	if err != nil {
+		t.Fatalf("MPI() failed: %s", err)
+	}
+	if i.ID != implem.OMPI || i.Version != "4.1.5" || i.InstallDir != filepath.Join(dir, "openmpi") || i.SpackHash != "abcdef" {
+		t.Fatalf("MPI() returned %+v", i)
+	}
+
+	p, err := s.Resolve("openmpi")
+	if err != nil {
+		t.Fatalf("Resolve() failed: %s", err)
+	}
+	if p.Compiler != "gcc@12.2.0" {
+		t.Fatalf("Resolve() returned compiler %s instead of gcc@12.2.0", p.Compiler)
+	}
+
+	a, err := s.App("osu-micro-benchmarks@7.2", "osu_latency")
+	if err != nil {
+		t.Fatalf("App() failed: %s", err)
+	}
+	if a.BinPath != filepath.Join(binDir, "osu_latency") || a.SpackHash != "123456" {
+		t.Fatalf("App() returned %+v", a)
+	}
+
+	_, err = s.MPI("osu-micro-benchmarks")
+	if err == nil {
+		t.Fatalf("MPI() succeeded with an application")
+	}
+	_, err = s.Resolve("mpich")
+	if err == nil || !strings.Contains(err.Error(), "aaaaaa") {
+		t.Fatalf("Resolve() did not report the ambiguous spec: %v", err)
+	}
+	_, err = s.Resolve("hdf5")
+	if err == nil || !strings.Contains(err.Error(), "no installed package") {
+		t.Fatalf("Resolve() did not report the missing package: %v", err)
+	}
+
+	expected := "eval \"$(" + cmd + " load --sh /abcdef)\"\n"
+	if s.ScriptCmds([]string{"abcdef"}) != expected {
+		t.Fatalf("ScriptCmds() returned %s instead of %s", s.ScriptCmds([]string{"abcdef"}), expected)
+	}
+}
@@ -0,0 +1,504 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sys
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// EnvPrefix is the prefix of the environment variables that set configuration values, e.g.,
+	// GO_HPC_JOBMGR_SCRATCH_DIR for scratch_dir or GO_HPC_JOBMGR_SLURM_PARTITION for slurm.partition
+	EnvPrefix = "GO_HPC_JOBMGR_"
+
+	// ConfigDirName is the name of the directory of the configuration files
+	ConfigDirName = "go_hpc_jobmgr"
+
+	// SiteLayer is the layer of values coming from the site configuration file
+	SiteLayer = "site"
+
+	// UserLayer is the layer of values coming from the user configuration file
+	UserLayer = "user"
+
+	// EnvLayer is the layer of values coming from the environment
+	EnvLayer = "env"
+
+	// OverrideLayer is the layer of values explicitly specified by the caller (e.g., command line flags)
+	OverrideLayer = "override"
+)
+
+// Backends is the list of job managers that can be configured with their own section
+var Backends = []string{"native", "slurm", "prun", "intel-slurm"}
+
+// DetectedBackends is the list of job managers that are used when they are detected on the system, which the
+// configuration can disable with their enabled key. The other job managers are either the default one or explicitly
+// selected.
+var DetectedBackends = []string{"slurm", "prun"}
+
+// errUnknownKey is the error returned when setting a key that is not part of the configuration
+var errUnknownKey = errors.New("unknown configuration key")
+
+// keyAliases gives the keys that were historically used for some configuration values
+var keyAliases = map[string]string{
+	"slurm_partition": "slurm.partition",
+	"enable_slurm":    "slurm.enabled",
+}
+
+// BackendConfig is the configuration specific to a job manager
+type BackendConfig struct {
+	// Partition is the partition to use when a job does not specify one
+	Partition string
+
+	// Account is the account to charge jobs to (optional)
+	Account string
+
+	// TimeLimit is the time limit of jobs that do not specify one
+	TimeLimit string
+
+	// MPI is the constraint selecting the preferred MPI implementation (e.g., openmpi>=4.1)
+	MPI string
+
+	// Launcher is the launcher to use with MPI implementations providing several of them (e.g., mpiexec.hydra)
+	Launcher string
+
+	// Disabled specifies whether the job manager must not be used even if it is available
+	Disabled bool
+}
+
+// Source describes where a configuration value comes from
+type Source struct {
+	// Layer is the layer the value comes from (SiteLayer, UserLayer, EnvLayer or OverrideLayer)
+	Layer string
+
+	// Location is the file and line or the environment variable the value comes from (optional)
+	Location string
+}
+
+func (s Source) String() string {
+	if s.Location == "" {
+		return s.Layer
+	}
+	return s.Layer + " " + s.Location
+}
+
+// LoadOptions specifies the layers to merge when loading the configuration
+type LoadOptions struct {
+	// SiteFile is the path to the site configuration file (optional, see DefaultSiteFiles)
+	SiteFile string
+
+	// UserFile is the path to the user configuration file (optional, see DefaultUserFiles)
+	UserFile string
+
+	// Env is the environment to get values from (optional, the environment of the process by default)
+	Env []string
+
+	// Overrides gives values that take precedence over all other layers, using the keys of the configuration files
+	// (e.g., slurm.partition)
+	Overrides map[string]string
+}
+
+// Backend returns the configuration of a job manager. It never returns nil.
+func (c *Config) Backend(id string) *BackendConfig {
+	if c == nil || c.Backends == nil || c.Backends[id] == nil {
+		return new(BackendConfig)
+	}
+	return c.Backends[id]
+}
+
+// Provenance returns the list of configuration values that were set, with where they come from
+func (c *Config) Provenance() []string {
+	var keys []string
+	for k := range c.Sources {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var lines []string
+	for _, k := range keys {
+		value, _ := c.get(k)
+		lines = append(lines, fmt.Sprintf("%s = %s (%s)", k, value, c.Sources[k]))
+	}
+	return lines
+}
+
+// backend returns the configuration of a job manager, creating it if needed
+func (c *Config) backend(id string) *BackendConfig {
+	if c.Backends == nil {
+		c.Backends = make(map[string]*BackendConfig)
+	}
+	if c.Backends[id] == nil {
+		c.Backends[id] = new(BackendConfig)
+	}
+	return c.Backends[id]
+}
+
+// field returns a pointer to the string value of a configuration key
+func (c *Config) field(key string) (*string, error) {
+	switch key {
+	case "scratch_dir":
+		return &c.ScratchDir, nil
+	case "persistent":
+		return &c.Persistent, nil
+	case "sysfs_root":
+		return &c.SysfsRoot, nil
+	}
+
+	tokens := strings.SplitN(key, ".", 2)
+	if len(tokens) != 2 || !isBackend(tokens[0]) {
+		return nil, fmt.Errorf("%w %s", errUnknownKey, key)
+	}
+	b := c.backend(tokens[0])
+	switch tokens[1] {
+	case "partition":
+		return &b.Partition, nil
+	case "account":
+		return &b.Account, nil
+	case "time_limit":
+		return &b.TimeLimit, nil
+	case "mpi":
+		return &b.MPI, nil
+	case "launcher":
+		return &b.Launcher, nil
+	}
+	return nil, fmt.Errorf("%w %s", errUnknownKey, key)
+}
+
+func (c *Config) get(key string) (string, error) {
+	if strings.HasSuffix(key, ".enabled") {
+		return strconv.FormatBool(!c.Backend(strings.TrimSuffix(key, ".enabled")).Disabled), nil
+	}
+	f, err := c.field(key)
+	if err != nil {
+		return "", err
+	}
+	return *f, nil
+}
+
+// Set sets a configuration value and records where it comes from
+func (c *Config) Set(key string, value string, src Source) error {
+	if alias, ok := keyAliases[key]; ok {
+		key = alias
+	}
+
+	if strings.HasSuffix(key, ".enabled") && isBackend(strings.TrimSuffix(key, ".enabled")) {
+		if !isDetectedBackend(strings.TrimSuffix(key, ".enabled")) {
+			return fmt.Errorf("%s cannot be set, %s is never detected on the system", key, strings.TrimSuffix(key, ".enabled"))
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value %s for %s: %w", value, key, err)
+		}
+		c.backend(strings.TrimSuffix(key, ".enabled")).Disabled = !enabled
+	} else {
+		f, err := c.field(key)
+		if err != nil {
+			return err
+		}
+		*f = value
+	}
+
+	if c.Sources == nil {
+		c.Sources = make(map[string]Source)
+	}
+	c.Sources[key] = src
+	return nil
+}
+
+func isBackend(name string) bool {
+	for _, b := range Backends {
+		if b == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isDetectedBackend(name string) bool {
+	for _, b := range DetectedBackends {
+		if b == name {
+			return true
+		}
+	}
+	return false
+}
+
+// entry is a value read from a configuration file
+type entry struct {
+	key   string
+	value string
+	line  int
+}
+
+// stripComment removes the comment at the end of a line, ignoring # within quotes
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			// Escaped character within a double-quoted string
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseValue parses a scalar value, which may be quoted
+func parseValue(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+		return strconv.Unquote(value)
+	case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+		return value[1 : len(value)-1], nil
+	case strings.HasPrefix(value, "\"") || strings.HasPrefix(value, "'"):
+		return "", fmt.Errorf("unterminated string %s", value)
+	case strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{"):
+		return "", fmt.Errorf("unsupported value %s", value)
+	}
+	return value, nil
+}
+
+// parseTOML parses the subset of TOML used by the configuration files: sections and key = value pairs
+func parseTOML(content string) ([]entry, error) {
+	var entries []entry
+	section := ""
+	for idx, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid section %s", idx+1, line)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		eq := strings.Index(line, "=")
+		if eq == -1 {
+			return nil, fmt.Errorf("line %d: expected key = value", idx+1)
+		}
+		key := strings.TrimSpace(line[:eq])
+		value, err := parseValue(line[eq+1:])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", idx+1, err)
+		}
+		if section != "" {
+			key = section + "." + key
+		}
+		entries = append(entries, entry{key: key, value: value, line: idx + 1})
+	}
+	return entries, nil
+}
+
+// parseYAML parses the subset of YAML used by the configuration files: key: value pairs, possibly within one level
+// of sections
+func parseYAML(content string) ([]entry, error) {
+	var entries []entry
+	section := ""
+	for idx, rawLine := range strings.Split(content, "\n") {
+		line := strings.TrimRight(stripComment(rawLine), " \t\r")
+		if strings.TrimSpace(line) == "" || line == "---" {
+			continue
+		}
+		indented := line[0] == ' ' || line[0] == '\t'
+		line = strings.TrimSpace(line)
+		colon := strings.Index(line, ":")
+		if colon == -1 {
+			return nil, fmt.Errorf("line %d: expected key: value", idx+1)
+		}
+		key := strings.TrimSpace(line[:colon])
+		rawValue := strings.TrimSpace(line[colon+1:])
+
+		switch {
+		case !indented && rawValue == "":
+			section = key
+			continue
+		case !indented:
+			section = ""
+		case section == "":
+			return nil, fmt.Errorf("line %d: unexpected indentation", idx+1)
+		case rawValue == "":
+			return nil, fmt.Errorf("line %d: nested sections are not supported", idx+1)
+		}
+
+		value, err := parseValue(rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", idx+1, err)
+		}
+		if section != "" {
+			key = section + "." + key
+		}
+		entries = append(entries, entry{key: key, value: value, line: idx + 1})
+	}
+	return entries, nil
+}
+
+// loadFile applies the values of a configuration file, in TOML or YAML based on its extension
+func (c *Config) loadFile(path string, layer string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", path, err)
+	}
+
+	var entries []entry
+	switch filepath.Ext(path) {
+	case ".toml":
+		entries, err = parseTOML(string(content))
+	case ".yaml", ".yml":
+		entries, err = parseYAML(string(content))
+	default:
+		return fmt.Errorf("unsupported configuration file format: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+
+	for _, e := range entries {
+		src := Source{Layer: layer, Location: fmt.Sprintf("%s:%d", path, e.line)}
+		err = c.Set(e.key, e.value, src)
+		if err != nil {
+			return fmt.Errorf("%s: %w", src.Location, err)
+		}
+	}
+	return nil
+}
+
+// envKey converts the name of an environment variable into a configuration key, e.g., GO_HPC_JOBMGR_SLURM_TIME_LIMIT
+// gives slurm.time_limit and GO_HPC_JOBMGR_INTEL_SLURM_PARTITION gives intel-slurm.partition
+func envKey(name string) string {
+	key := strings.ToLower(strings.TrimPrefix(name, EnvPrefix))
+	for _, b := range Backends {
+		prefix := strings.ReplaceAll(b, "-", "_") + "_"
+		if strings.HasPrefix(key, prefix) {
+			return b + "." + strings.TrimPrefix(key, prefix)
+		}
+	}
+	return key
+}
+
+func (c *Config) loadEnv(env []string) error {
+	for _, e := range env {
+		if !strings.HasPrefix(e, EnvPrefix) {
+			continue
+		}
+		idx := strings.Index(e, "=")
+		if idx == -1 {
+			continue
+		}
+		name := e[:idx]
+		err := c.Set(envKey(name), e[idx+1:], Source{Layer: EnvLayer, Location: name})
+		if errors.Is(err, errUnknownKey) {
+			// The environment may define variables for other versions of the package
+			log.Printf("* ignoring %s: %s", name, err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// DefaultSiteFiles returns the site configuration files that are looked for when none is specified
+func DefaultSiteFiles() []string {
+	dir := filepath.Join("/etc", ConfigDirName)
+	return []string{filepath.Join(dir, "config.toml"), filepath.Join(dir, "config.yaml")}
+}
+
+// DefaultUserFiles returns the user configuration files that are looked for when none is specified
+func DefaultUserFiles() []string {
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		configDir = filepath.Join(home, ".config")
+	}
+	dir := filepath.Join(configDir, ConfigDirName)
+	return []string{filepath.Join(dir, "config.toml"), filepath.Join(dir, "config.yaml")}
+}
+
+// findFile returns the first file of a list that exists
+func findFile(files []string) string {
+	for _, f := range files {
+		if _, err := os.Stat(f); err == nil {
+			return f
+		}
+	}
+	return ""
+}
+
+// LoadConfig creates the system configuration by merging, in order of precedence, the site configuration file, the
+// user configuration file, the GO_HPC_JOBMGR_* environment variables and the overrides. Files that are explicitly
+// specified must exist; default files are skipped when missing.
+func LoadConfig(opts *LoadOptions) (*Config, error) {
+	if opts == nil {
+		opts = new(LoadOptions)
+	}
+	cfg := new(Config)
+
+	layers := []struct {
+		layer    string
+		file     string
+		defaults []string
+	}{
+		{layer: SiteLayer, file: opts.SiteFile, defaults: DefaultSiteFiles()},
+		{layer: UserLayer, file: opts.UserFile, defaults: DefaultUserFiles()},
+	}
+	for _, l := range layers {
+		file := l.file
+		if file == "" {
+			file = findFile(l.defaults)
+		}
+		if file == "" {
+			continue
+		}
+		err := cfg.loadFile(file, l.layer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	env := opts.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	err := cfg.loadEnv(env)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for k := range opts.Overrides {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		err := cfg.Set(k, opts.Overrides[k], Source{Layer: OverrideLayer})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sys
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BTMichalowicz/go_hpc_jobmgr/internal/pkg/testutil"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sys-config-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	siteFile := testutil.WriteFile(t, filepath.Join(dir, "site.toml"), `# Site configuration
+scratch_dir = "/scratch"
+slurm_partition = "batch" # legacy key
+
+[slurm]
+account = 'proj01'
+time_limit = "1:00:00"
+mpi = "openmpi>=4.1"
+
+[prun]
+enabled = false
+
+[intel-slurm]
+partition = "knl"
+`, 0644)
+	userFile := testutil.WriteFile(t, filepath.Join(dir, "user.yaml"), `persistent: /home/user/persistent
+slurm:
+  partition: debug
+  launcher: "mpiexec.hydra"
+`, 0644)
+
+	opts := &LoadOptions{
+		SiteFile:  siteFile,
+		UserFile:  userFile,
+		Env:       []string{"GO_HPC_JOBMGR_SLURM_TIME_LIMIT=2:00:00", "GO_HPC_JOBMGR_INTEL_SLURM_ACCOUNT=proj03", "GO_HPC_JOBMGR_UNKNOWN=1", "HOME=/home/user"},
+		Overrides: map[string]string{"slurm.account": "proj02"},
+	}
+	cfg, err := LoadConfig(opts)
+	if err != nil {
+		t.Fatalf("LoadConfig() failed: %s", err)
+	}
+
+	slurm := cfg.Backend("slurm")
+	if cfg.ScratchDir != "/scratch" || cfg.Persistent != "/home/user/persistent" {
+		t.Fatalf("LoadConfig() returned invalid directories: %s and %s", cfg.ScratchDir, cfg.Persistent)
+	}
+	if slurm.Partition != "debug" || slurm.Account != "proj02" || slurm.TimeLimit != "2:00:00" || slurm.MPI != "openmpi>=4.1" || slurm.Launcher != "mpiexec.hydra" {
+		t.Fatalf("LoadConfig() returned an invalid Slurm configuration: %+v", slurm)
+	}
+	intelSlurm := cfg.Backend("intel-slurm")
+	if intelSlurm.Partition != "knl" || intelSlurm.Account != "proj03" {
+		t.Fatalf("LoadConfig() returned an invalid Intel-Slurm configuration: %+v", intelSlurm)
+	}
+	if !cfg.Backend("prun").Disabled || cfg.Backend("native").Disabled {
+		t.Fatalf("LoadConfig() did not disable the expected job managers")
+	}
+
+	expectedSources := map[string]string{
+		"scratch_dir":      SiteLayer + " " + siteFile + ":2",
+		"slurm.partition":  UserLayer + " " + userFile + ":3",
+		"slurm.time_limit": EnvLayer + " GO_HPC_JOBMGR_SLURM_TIME_LIMIT",
+		"slurm.account":    OverrideLayer,
+		"slurm.mpi":        SiteLayer + " " + siteFile + ":8",
+	}
+	for key, expected := range expectedSources {
+		if cfg.Sources[key].String() != expected {
+			t.Fatalf("source of %s is %s instead of %s", key, cfg.Sources[key], expected)
+		}
+	}
+	report := strings.Join(cfg.Provenance(), "\n")
+	if !strings.Contains(report, "prun.enabled = false ("+SiteLayer) {
+		t.Fatalf("Provenance() returned:\n%s", report)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sys-config-")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name string
+		opts LoadOptions
+	}{
+		{
+			name: "unknownKey",
+			opts: LoadOptions{SiteFile: testutil.WriteFile(t, filepath.Join(dir, "unknown.toml"), "[slurm]\nqueue = \"debug\"\n", 0644)},
+		},
+		{
+			name: "invalidTOML",
+			opts: LoadOptions{SiteFile: testutil.WriteFile(t, filepath.Join(dir, "invalid.toml"), "[slurm\npartition = \"debug\"\n", 0644)},
+		},
+		{
+			name: "nestedYAML",
+			opts: LoadOptions{UserFile: testutil.WriteFile(t, filepath.Join(dir, "nested.yaml"), "slurm:\n  partitions:\n    debug: true\n", 0644)},
+		},
+		{
+			name: "unsupportedFormat",
+			opts: LoadOptions{UserFile: testutil.WriteFile(t, filepath.Join(dir, "config.json"), "{}", 0644)},
+		},
+		{
+			name: "missingFile",
+			opts: LoadOptions{SiteFile: filepath.Join(dir, "missing.toml")},
+		},
+		{
+			name: "invalidBool",
+			opts: LoadOptions{Env: []string{"GO_HPC_JOBMGR_SLURM_ENABLED=maybe"}},
+		},
+		{
+			name: "nativeEnabled",
+			opts: LoadOptions{SiteFile: testutil.WriteFile(t, filepath.Join(dir, "native.toml"), "[native]\nenabled = false\n", 0644)},
+		},
+		{
+			name: "unknownOverride",
+			opts: LoadOptions{Overrides: map[string]string{"pbs.partition": "debug"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			// Default files are not looked for
+			if opts.SiteFile == "" {
+				opts.SiteFile = testutil.WriteFile(t, filepath.Join(dir, "empty.toml"), "", 0644)
+			}
+			if opts.UserFile == "" {
+				opts.UserFile = testutil.WriteFile(t, filepath.Join(dir, "empty.yaml"), "", 0644)
+			}
+			if opts.Env == nil {
+				opts.Env = []string{}
+			}
+			_, err := LoadConfig(&opts)
+			if err == nil {
+				t.Fatalf("LoadConfig() succeeded but was expected to fail")
+			}
+		})
+	}
+}
@@ -15,4 +15,13 @@ type Config struct {
 
 	// CurPath is the path to the current directory
 	CurPath string
+
+	// SysfsRoot is the directory under which sysfs is found when discovering network devices (optional, / by default)
+	SysfsRoot string
+
+	// Backends gives the configuration of the job managers, by ID (e.g., slurm) (optional)
+	Backends map[string]*BackendConfig
+
+	// Sources gives where each configuration value that was loaded comes from, by key (e.g., slurm.partition)
+	Sources map[string]Source
 }
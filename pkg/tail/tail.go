@@ -0,0 +1,178 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package tail follows files that other processes write, e.g., the output files of a job
+package tail
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultInterval is the default time to wait for new data once the end of a file is reached
+const DefaultInterval = 500 * time.Millisecond
+
+// DoneFn is a "function pointer" that lets us know whether the process writing a file is done, in which case the
+// file is read until its end and not followed any further
+type DoneFn func() bool
+
+// Follower reads a file as it grows. The file does not need to exist yet and is read again from the start when it
+// is truncated or replaced (e.g., rotated).
+type Follower struct {
+	// Interval is the time to wait for new data once the end of the file is reached
+	Interval time.Duration
+
+	path   string
+	done   DoneFn
+	file   *os.File
+	offset int64
+
+	// mu protects file and offset between Read and Close
+	mu        sync.Mutex
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// File returns a follower for a file. done is optional; without it, the file is followed until the follower is
+// closed.
+func File(path string, done DoneFn) *Follower {
+	return &Follower{
+		Interval: DefaultInterval,
+		path:     path,
+		done:     done,
+		closed:   make(chan struct{}),
+	}
+}
+
+func (f *Follower) isDone() bool {
+	return f.done != nil && f.done()
+}
+
+// wait waits for new data, it returns false when the follower is closed
+func (f *Follower) wait() bool {
+	select {
+	case <-f.closed:
+		return false
+	case <-time.After(f.Interval):
+		return true
+	}
+}
+
+// reopenIfChanged opens the file again when it was replaced, and reads it from the start when it was truncated
+func (f *Follower) reopenIfChanged() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		// The file was removed, new data may only come from a new file
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	current, err := f.file.Stat()
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(info, current) {
+		file, err := os.Open(f.path)
+		if err != nil {
+			return err
+		}
+		f.file.Close()
+		f.file = file
+		f.offset = 0
+		return nil
+	}
+	if info.Size() < f.offset {
+		_, err = f.file.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		f.offset = 0
+	}
+	return nil
+}
+
+// Read reads the next data of the file, waiting for it if necessary. It returns io.EOF once the writer is done and
+// the file is fully read, or once the follower is closed.
+func (f *Follower) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for {
+		select {
+		case <-f.closed:
+			return 0, io.EOF
+		default:
+		}
+
+		// Checking before reading guarantees that we get all the data written before the writer is done
+		done := f.isDone()
+		if f.file == nil {
+			file, err := os.Open(f.path)
+			if err != nil && !os.IsNotExist(err) {
+				return 0, err
+			}
+			f.file = file
+		}
+
+		if f.file != nil {
+			n, err := f.file.Read(p)
+			f.offset += int64(n)
+			if n > 0 {
+				return n, nil
+			}
+			if err != nil && err != io.EOF {
+				return 0, err
+			}
+			if !done {
+				err = f.reopenIfChanged()
+				if err != nil {
+					return 0, err
+				}
+			}
+		}
+
+		if done || !f.wait() {
+			return 0, io.EOF
+		}
+	}
+}
+
+// Close stops following the file
+func (f *Follower) Close() error {
+	f.closeOnce.Do(func() { close(f.closed) })
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// Lines returns a channel receiving the lines of a reader, without their end of line. The channel is closed once the
+// reader returns an error, including io.EOF.
+func Lines(r io.Reader) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			if line != "" {
+				lines <- strings.TrimSuffix(line, "\n")
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return lines
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2001-2023, The Ohio State University. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package tail
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func appendToFile(t *testing.T, path string, content string) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("unable to open %s: %s", path, err)
+	}
+	defer f.Close()
+	_, err = f.WriteString(content)
+	if err != nil {
+		t.Fatalf("unable to write to %s: %s", path, err)
+	}
+}
+
+func expectLine(t *testing.T, lines <-chan string, expected string) {
+	select {
+	case line, ok := <-lines:
+		if !ok {
+			t.Fatalf("the lines ended before %q", expected)
+		}
+		if line != expected {
+			t.Fatalf("got line %q instead of %q", line, expected)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for line %q", expected)
+	}
+}
+
+func TestFollower(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "job.out")
+
+	var done int32
+	f := File(path, func() bool { return atomic.LoadInt32(&done) == 1 })
+	f.Interval = 10 * time.Millisecond
+	defer f.Close()
+	lines := Lines(f)
+
+	// The file does not exist yet
+	time.Sleep(30 * time.Millisecond)
+	appendToFile(t, path, "line 1\n")
+	expectLine(t, lines, "line 1")
+	appendToFile(t, path, "line 2\nline 3\n")
+	expectLine(t, lines, "line 2")
+	expectLine(t, lines, "line 3")
+
+	// Truncation
+	err = ioutil.WriteFile(path, []byte("line 4\n"), 0644)
+	if err != nil {
+		t.Fatalf("unable to truncate %s: %s", path, err)
+	}
+	expectLine(t, lines, "line 4")
+
+	// Rotation
+	err = os.Rename(path, path+".1")
+	if err != nil {
+		t.Fatalf("unable to rotate %s: %s", path, err)
+	}
+	appendToFile(t, path, "line 5\n")
+	expectLine(t, lines, "line 5")
+
+	appendToFile(t, path, "last line")
+	atomic.StoreInt32(&done, 1)
+	expectLine(t, lines, "last line")
+	select {
+	case line, ok := <-lines:
+		if ok {
+			t.Fatalf("got line %q after the writer was done", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the follower did not stop once the writer was done")
+	}
+}
+
+func TestFollowerClose(t *testing.T) {
+	f := File("/nonexistent/job.out", nil)
+	f.Interval = 10 * time.Millisecond
+	lines := Lines(f)
+	f.Close()
+	select {
+	case <-lines:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the follower did not stop once closed")
+	}
+}